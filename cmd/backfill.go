@@ -3,21 +3,22 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/broyeztony/delegated/internal/db"
 	"github.com/broyeztony/delegated/internal/indexer"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/spf13/cobra"
 )
 
 var backfillCmd = &cobra.Command{
 	Use:   "backfill",
 	Short: "Backfill historical delegation data",
-	Long:  `Backfills historical delegations from TzKT API using COPY protocol.`,
+	Long: `Backfills historical delegations from TzKT API using COPY protocol, or with --staking
+historical staking operations.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		log.Println("Backfill command started")
+		slog.Info("Backfill command started")
 
 		// Get database connection string
 		connStr, err := getDatabaseURL()
@@ -26,49 +27,68 @@ var backfillCmd = &cobra.Command{
 		}
 
 		// Initialize database connection
-		dbpool, err := pgxpool.New(context.Background(), connStr)
+		dbpool, err := newPool(context.Background(), connStr)
 		if err != nil {
 			return fmt.Errorf("unable to create connection pool: %w", err)
 		}
 		defer dbpool.Close()
 
+		// Cancel on SIGTERM/SIGINT, aborting the in-flight COPY batch
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
+
+		startMetricsServer(ctx)
+
+		var b backfiller = indexer.NewIndexer(dbpool, cfg.TzKTURL, cfg.Indexer)
+		if backfillStaking {
+			b = indexer.NewStakingIndexer(dbpool, cfg.TzKTURL, cfg.Indexer)
+		}
+
 		// Get min ID in our table - if empty, exit
-		ctx := context.Background()
-		count, minID, err := db.GetMinID(ctx, dbpool)
+		count, minID, err := b.OldestID(ctx)
 		if err != nil {
 			return fmt.Errorf("failed to get min id: %w", err)
 		}
 
 		if count == 0 {
-			log.Println("Delegations table is empty. Run 'index' command first to populate recent delegations, then run backfill.")
-			return fmt.Errorf("cannot backfill: table `delegations` is empty")
+			slog.Error("Nothing indexed yet. Run 'index' command first to populate recent operations, then run backfill.", "indexer", b.Name())
+			return fmt.Errorf("cannot backfill: no %s indexed", b.Name())
 		}
 
 		// Start backfill
-		log.Printf("Starting backfill from cursor: %d (oldest ID in table)\n", minID)
+		slog.Info("Starting backfill from oldest id in table", "indexer", b.Name(), "cursor", minID)
 
-		idx := indexer.NewIndexer(dbpool)
 		startTime := time.Now()
 
-		totalRecords, totalBatches, err := idx.Backfill(ctx, minID)
+		totalRecords, totalBatches, err := b.Backfill(ctx, minID)
 		if err != nil {
 			return err
 		}
 
 		// Print summary
 		totalDuration := time.Since(startTime)
-		log.Printf("\nBackfill Summary:")
-		log.Printf("Total records: %d", totalRecords)
-		log.Printf("Total batches: %d", totalBatches)
-		log.Printf("Total duration: %v", totalDuration)
+		summary := []any{"rows", totalRecords, "batches", totalBatches, "duration", totalDuration}
 		if totalRecords > 0 {
-			log.Printf("Avg records/sec: %.2f", float64(totalRecords)/totalDuration.Seconds())
+			summary = append(summary, "rows_per_sec", float64(totalRecords)/totalDuration.Seconds())
 		}
+		slog.Info("Backfill summary", summary...)
 
 		return nil
 	},
 }
 
+// backfillStaking backfills staking operations instead of delegations
+var backfillStaking bool
+
+// backfiller is an indexer walking back from its oldest indexed operation
+type backfiller interface {
+	Name() string
+	OldestID(ctx context.Context) (count, minID int64, err error)
+	Backfill(ctx context.Context, startCursor int64) (totalRecords int, totalBatches int, err error)
+}
+
 func init() {
 	rootCmd.AddCommand(backfillCmd)
+	addMetricsFlag(backfillCmd)
+	backfillCmd.Flags().BoolVar(&backfillStaking, "staking", false, "Backfill staking operations instead of delegations")
 }
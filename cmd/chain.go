@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/broyeztony/delegated/internal/indexer"
+	"github.com/broyeztony/delegated/internal/tzkt"
+	"github.com/spf13/cobra"
+)
+
+var chainCmd = &cobra.Command{
+	Use:   "chain",
+	Short: "Manage the cycles and protocols delegations are attributed to",
+}
+
+var chainSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Copy the cycles and protocols of TzKT into the database",
+	Long: `Copy the cycles and protocols of TzKT into the database, for the cycle and protocol of
+delegations and the cycle= and protocol= filters of /xtz/delegations.
+
+The indexer syncs them on its first poll and then once per cycle, a manual sync is only needed
+when delegations are backfilled or imported without running the indexer.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		connStr, err := getDatabaseURL()
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		dbpool, err := newPool(ctx, connStr)
+		if err != nil {
+			return fmt.Errorf("unable to create connection pool: %w", err)
+		}
+		defer dbpool.Close()
+
+		head, err := tzkt.FetchHead(ctx, http.DefaultClient, tzkt.HeadURL(cfg.TzKTURL))
+		if err != nil {
+			return fmt.Errorf("failed to fetch TzKT head: %w", err)
+		}
+
+		return indexer.NewIndexer(dbpool, cfg.TzKTURL, cfg.Indexer).SyncChain(ctx, head.Level)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(chainCmd)
+	chainCmd.AddCommand(chainSyncCmd)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
+)
+
+// configKeyAnnotation marks a flag with the config key it overrides
+const configKeyAnnotation = "config-key"
+
+// bindFlag maps a flag to a config key. The binding is applied by bindFlags when the command runs,
+// so that commands sharing a key (e.g. index and run --interval) each override it with their own flag.
+func bindFlag(flags *pflag.FlagSet, name, key string) {
+	if err := flags.SetAnnotation(name, configKeyAnnotation, []string{key}); err != nil {
+		panic(err)
+	}
+}
+
+// bindFlags binds the flags of the running command, including inherited ones, to their config keys
+func bindFlags(cmd *cobra.Command) error {
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if keys, ok := f.Annotations[configKeyAnnotation]; ok && err == nil {
+			err = viper.BindPFlag(keys[0], f)
+		}
+	})
+	return err
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration",
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print the effective configuration",
+	Long: `Print the configuration resolved from flags, DELEGATED_* environment variables,
+the config file and defaults, as YAML usable as a config file. The database password is redacted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, err := yaml.Marshal(cfg.Settings())
+		if err != nil {
+			return fmt.Errorf("failed to encode config: %w", err)
+		}
+
+		fmt.Print(string(out))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configPrintCmd)
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var currentDelegationsCmd = &cobra.Command{
+	Use:   "current-delegations",
+	Short: "Manage the current delegation of each delegator",
+}
+
+var currentDelegationsRebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "Recompute the current delegations from the delegations table",
+	Long: `Recompute the current_delegations table (the latest delegation of each delegator) from the
+delegation events, in one transaction. Inserts into the delegations table wait until it is done.
+
+The table is updated with each insert, a rebuild is only needed after loading the schema on
+existing data or writing to the delegations table outside of delegated.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		connStr, err := getDatabaseURL()
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		dbpool, err := newPool(ctx, connStr)
+		if err != nil {
+			return fmt.Errorf("unable to create connection pool: %w", err)
+		}
+		defer dbpool.Close()
+
+		start := time.Now()
+		delegators, err := db.RebuildCurrentDelegations(ctx, dbpool)
+		if err != nil {
+			return fmt.Errorf("failed to rebuild current delegations: %w", err)
+		}
+
+		slog.Info("Rebuilt current delegations", "delegators", delegators, "duration", time.Since(start))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(currentDelegationsCmd)
+	currentDelegationsCmd.AddCommand(currentDelegationsRebuildCmd)
+}
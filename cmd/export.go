@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/snapshot"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportFormat    string
+	exportFrom      string
+	exportTo        string
+	exportOut       string
+	exportPartition bool
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export delegations to CSV, NDJSON or Parquet files",
+	Long: `Export the delegations with --from <= timestamp < --to, ordered by id. CSV and NDJSON are
+streamed by Postgres (COPY TO), Parquet files have typed columns (int64 mutez, timestamp, strings).
+
+--out is a file ("-" for stdout, CSV and NDJSON only). With --partition it is a directory receiving
+one file per month under hive-style year=YYYY/month=MM directories. Files are written under a
+temporary name and renamed when complete.`,
+	Example: `  delegated export --format parquet --out delegations.parquet
+  delegated export --format csv --from 2024-01-01 --to 2025-01-01 --out - | gzip > 2024.csv.gz
+  delegated export --format parquet --from 2024-03-05 --to 2024-03-06 --partition --out /data/lake/delegations`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := snapshot.ValidateFormat(exportFormat); err != nil {
+			return err
+		}
+		r, err := parseRange(exportFrom, exportTo)
+		if err != nil {
+			return err
+		}
+		if exportOut == "-" && (exportPartition || exportFormat == snapshot.FormatParquet) {
+			return fmt.Errorf("--out - is only supported for unpartitioned CSV and NDJSON")
+		}
+
+		connStr, err := getDatabaseURL()
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		dbpool, err := newPool(ctx, connStr)
+		if err != nil {
+			return fmt.Errorf("unable to create connection pool: %w", err)
+		}
+		defer dbpool.Close()
+
+		start := time.Now()
+		var total int64
+		if exportPartition {
+			total, err = exportPartitions(ctx, dbpool, r)
+		} else {
+			total, err = exportFile(ctx, dbpool, exportOut, r)
+		}
+		if err != nil {
+			return err
+		}
+
+		slog.Info("Export summary", "rows", total, "duration", time.Since(start))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.Flags().StringVar(&exportFormat, "format", snapshot.FormatCSV, "Output format: csv, ndjson or parquet")
+	exportCmd.Flags().StringVar(&exportFrom, "from", "", "Export delegations from this date or RFC 3339 time, included (default: the first one)")
+	exportCmd.Flags().StringVar(&exportTo, "to", "", "Export delegations until this date or RFC 3339 time, excluded (default: all)")
+	exportCmd.Flags().StringVar(&exportOut, "out", "", "Output file, \"-\" for stdout, or directory with --partition")
+	exportCmd.Flags().BoolVar(&exportPartition, "partition", false, "Write one file per month under year=YYYY/month=MM directories")
+	exportCmd.MarkFlagRequired("out")
+}
+
+// parseRange parses --from and --to, as dates (YYYY-MM-DD) or RFC 3339 times
+func parseRange(from, to string) (snapshot.Range, error) {
+	var r snapshot.Range
+	var err error
+	if from != "" {
+		if r.From, err = parseTime(from); err != nil {
+			return r, fmt.Errorf("invalid --from: %w", err)
+		}
+	}
+	if to != "" {
+		if r.To, err = parseTime(to); err != nil {
+			return r, fmt.Errorf("invalid --to: %w", err)
+		}
+	}
+	if !r.From.IsZero() && !r.To.IsZero() && !r.To.After(r.From) {
+		return r, fmt.Errorf("--to must be after --from")
+	}
+	return r, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// exportFile exports r to path, or stdout when path is "-"
+func exportFile(ctx context.Context, dbpool *pgxpool.Pool, path string, r snapshot.Range) (int64, error) {
+	if path == "-" {
+		return snapshot.Export(ctx, dbpool, os.Stdout, exportFormat, r)
+	}
+
+	var rows int64
+	err := writeAtomically(path, func(w io.Writer) error {
+		var err error
+		rows, err = snapshot.Export(ctx, dbpool, w, exportFormat, r)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	slog.Info("Exported file", "path", path, "rows", rows)
+	return rows, nil
+}
+
+// exportPartitions exports r month by month. Open bounds are closed with the range of the table,
+// and months without delegations produce no file.
+func exportPartitions(ctx context.Context, dbpool *pgxpool.Pool, r snapshot.Range) (int64, error) {
+	summary, err := db.GetSummary(ctx, dbpool)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get summary: %w", err)
+	}
+	if summary.Count == 0 {
+		slog.Info("Table `delegations` is empty, nothing to export")
+		return 0, nil
+	}
+	if r.From.IsZero() {
+		r.From = summary.MinTimestamp.Truncate(24 * time.Hour)
+	}
+	if r.To.IsZero() {
+		r.To = summary.MaxTimestamp.Add(time.Second)
+	}
+
+	var total int64
+	for _, month := range r.Months() {
+		path := snapshot.PartitionPath(exportOut, month, exportFormat)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return total, fmt.Errorf("failed to create partition directory: %w", err)
+		}
+
+		var rows int64
+		err := writeAtomically(path, func(w io.Writer) error {
+			var err error
+			rows, err = snapshot.Export(ctx, dbpool, w, exportFormat, month)
+			if err == nil && rows == 0 {
+				err = errEmptyPartition
+			}
+			return err
+		})
+		if errors.Is(err, errEmptyPartition) {
+			continue
+		}
+		if err != nil {
+			return total, err
+		}
+
+		total += rows
+		slog.Info("Exported partition", "path", path, "rows", rows)
+	}
+	return total, nil
+}
+
+// errEmptyPartition discards the file of a month without delegations
+var errEmptyPartition = errors.New("empty partition")
+
+// writeAtomically writes path through a temporary file in the same directory, renamed once write succeeded,
+// so that readers never see a partial file. Nothing is left behind when write fails.
+func writeAtomically(path string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := write(tmp); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	// CreateTemp makes the file private
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to set output file permissions: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename output file: %w", err)
+	}
+	return nil
+}
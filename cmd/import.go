@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/snapshot"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importFormat     string
+	importOnConflict string
+	importBatchSize  int
+	importMaxInvalid int64
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import PATH",
+	Short: "Import delegations from CSV, NDJSON or Parquet files",
+	Long: `Import delegations from a file written by the export command (or any file with the same
+columns) using the COPY protocol, to seed a database without crawling TzKT.
+
+Rows are validated before insertion. Invalid rows are logged and skipped, and the import stops when
+there are more than --max-invalid of them. --on-conflict decides what happens to delegations already
+in the table: skip keeps the existing row, update overwrites it, error aborts the import (fastest,
+for empty tables). Batches written before an error are kept.
+
+CSV files need a header row, columns can be in any order and baker is optional. Timestamps are
+RFC 3339 or Postgres text, in UTC when they have no offset.`,
+	Example: `  delegated import --format parquet delegations.parquet
+  delegated import --format csv --on-conflict update --max-invalid 100 2024.csv`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := snapshot.ValidateFormat(importFormat); err != nil {
+			return err
+		}
+		switch importOnConflict {
+		case snapshot.ConflictSkip, snapshot.ConflictUpdate, snapshot.ConflictError:
+		default:
+			return fmt.Errorf("invalid --on-conflict %q, expected %s, %s or %s", importOnConflict,
+				snapshot.ConflictSkip, snapshot.ConflictUpdate, snapshot.ConflictError)
+		}
+		if importBatchSize <= 0 {
+			return fmt.Errorf("--batch-size must be positive")
+		}
+		if importMaxInvalid < 0 {
+			return fmt.Errorf("--max-invalid must not be negative")
+		}
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to open input file: %w", err)
+		}
+		defer f.Close()
+
+		r, err := snapshot.NewReader(f, importFormat)
+		if err != nil {
+			return err
+		}
+
+		connStr, err := getDatabaseURL()
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		dbpool, err := newPool(ctx, connStr)
+		if err != nil {
+			return fmt.Errorf("unable to create connection pool: %w", err)
+		}
+		defer dbpool.Close()
+
+		start := time.Now()
+		logStats := func(msg string, stats snapshot.ImportStats) {
+			elapsed := time.Since(start)
+			slog.Info(msg,
+				"read", stats.Read,
+				"written", stats.Written,
+				"unchanged", stats.Unchanged,
+				"invalid", stats.Invalid,
+				"progress", fmt.Sprintf("%.1f%%", stats.Progress*100),
+				"rows_per_sec", float64(stats.Read)/elapsed.Seconds(),
+				"duration", elapsed)
+		}
+
+		stats, err := snapshot.Import(ctx, dbpool, r, snapshot.ImportOptions{
+			OnConflict: importOnConflict,
+			BatchSize:  importBatchSize,
+			MaxInvalid: importMaxInvalid,
+			OnBatch: func(stats snapshot.ImportStats) {
+				logStats("Imported batch", stats)
+			},
+		})
+		if err != nil {
+			logStats("Import aborted", stats)
+			return err
+		}
+
+		logStats("Import summary", stats)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().StringVar(&importFormat, "format", snapshot.FormatCSV, "Input format: csv, ndjson or parquet")
+	importCmd.Flags().StringVar(&importOnConflict, "on-conflict", snapshot.ConflictSkip, "Existing delegations: skip, update or error")
+	importCmd.Flags().IntVar(&importBatchSize, "batch-size", 10000, "Number of rows per COPY batch")
+	importCmd.Flags().Int64Var(&importMaxInvalid, "max-invalid", 0, "Number of invalid rows skipped before aborting")
+}
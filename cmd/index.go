@@ -2,25 +2,30 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/broyeztony/delegated/internal/config"
 	"github.com/broyeztony/delegated/internal/indexer"
+	"github.com/broyeztony/delegated/internal/leader"
+	"github.com/broyeztony/delegated/internal/models"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/spf13/cobra"
 )
 
-var (
-	pollingInterval int
-)
-
 var indexCmd = &cobra.Command{
 	Use:   "index",
 	Short: "Start indexing delegations",
-	Long:  `Continuously poll and index new Tezos delegations from tzkt.io API.`,
+	Long: `Continuously poll and index new Tezos delegations from tzkt.io API, and with --staking
+staking operations too.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		log.Println("Index command started")
+		slog.Info("Index command started")
 
 		// Get database connection string
 		connStr, err := getDatabaseURL()
@@ -29,41 +34,148 @@ var indexCmd = &cobra.Command{
 		}
 
 		// Initialize database connection
-		dbpool, err := pgxpool.New(context.Background(), connStr)
+		dbpool, err := newPool(context.Background(), connStr)
 		if err != nil {
 			return fmt.Errorf("unable to create connection pool: %w", err)
 		}
 		defer dbpool.Close()
 
-		// Create indexer
-		idx := indexer.NewIndexer(dbpool)
-
-		// Initialize cursor
-		ctx := context.Background()
-		if err := idx.Initialize(ctx); err != nil {
-			return fmt.Errorf("failed to initialize: %w", err)
-		}
+		// Cancel on SIGTERM/SIGINT
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
 
-		// Start polling loop (every 60s)
-		ticker := time.NewTicker(time.Duration(pollingInterval) * time.Second)
-		defer ticker.Stop()
+		startMetricsServer(ctx)
 
-		// Initial poll
-		if err := idx.Poll(ctx); err != nil {
-			log.Printf("Error in initial poll: %v\n", err)
-		}
+		// Create indexer
+		idx := indexer.NewIndexer(dbpool, cfg.TzKTURL, cfg.Indexer)
 
-		for range ticker.C {
-			if err := idx.Poll(ctx); err != nil {
-				log.Printf("Error polling: %v\n", err)
-			}
+		// Publish committed delegations to the configured sinks
+		publishers, err := newSinkPublishers(dbpool, idx)
+		if err != nil {
+			return err
 		}
+		runSinkPublishers(ctx, publishers)
 
-		return nil
+		// Poll every interval until a shutdown signal is received, only while leader
+		return runIndexers(ctx, dbpool, liveIndexers(dbpool, idx)...)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(indexCmd)
-	indexCmd.Flags().IntVarP(&pollingInterval, "interval", "i", 60, "Polling interval in seconds")
+	addIntervalFlag(indexCmd)
+	addShutdownTimeoutFlag(indexCmd, "Time given to an in-flight batch to finish on shutdown")
+	addLeaderElectionFlags(indexCmd)
+	addStakingFlag(indexCmd)
+	addMetricsFlag(indexCmd)
+}
+
+func addIntervalFlag(cmd *cobra.Command) {
+	interval := secondsDuration(config.Default().Indexer.Interval)
+	cmd.Flags().VarP(&interval, "interval", "i", "Polling interval, e.g. 30s, or a number of seconds")
+	bindFlag(cmd.Flags(), "interval", "indexer.interval")
+}
+
+// secondsDuration is a duration flag that also accepts a bare number of seconds,
+// as --interval did before it took durations
+type secondsDuration time.Duration
+
+func (d *secondsDuration) String() string {
+	return time.Duration(*d).String()
+}
+
+func (d *secondsDuration) Set(value string) error {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		*d = secondsDuration(time.Duration(seconds) * time.Second)
+		return nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = secondsDuration(duration)
+	return nil
+}
+
+// Type is reported as duration so that viper decodes the value like other duration flags
+func (d *secondsDuration) Type() string {
+	return "duration"
+}
+
+func addShutdownTimeoutFlag(cmd *cobra.Command, usage string) {
+	cmd.Flags().Duration("shutdown-timeout", config.Default().ShutdownTimeout, usage)
+	bindFlag(cmd.Flags(), "shutdown-timeout", "shutdown-timeout")
+}
+
+func addStakingFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("staking", config.Default().Indexer.Staking, "Also index staking operations (stake, unstake, finalize)")
+	bindFlag(cmd.Flags(), "staking", "indexer.staking")
+}
+
+func addLeaderElectionFlags(cmd *cobra.Command) {
+	defaults := config.Default().Indexer
+	cmd.Flags().Bool("leader-election", defaults.LeaderElection, "Only poll while holding the indexer lease, so several replicas can run")
+	cmd.Flags().String("instance-id", "", "Identity of this replica in the indexer lease (default hostname-pid)")
+	cmd.Flags().Duration("lease-ttl", defaults.LeaseTTL, "Indexer lease duration, a standby takes over within about 4/3 of it")
+	bindFlag(cmd.Flags(), "leader-election", "indexer.leader-election")
+	bindFlag(cmd.Flags(), "instance-id", "indexer.instance-id")
+	bindFlag(cmd.Flags(), "lease-ttl", "indexer.lease-ttl")
+}
+
+// liveIndexer is an indexer run by runIndexers, whatever the operations it indexes
+type liveIndexer interface {
+	Name() string
+	Initialize(ctx context.Context) error
+	Run(ctx context.Context, interval, shutdownTimeout time.Duration) error
+}
+
+// liveIndexers returns the delegations indexer idx, followed by the staking operations indexer when enabled
+func liveIndexers(dbpool *pgxpool.Pool, idx *indexer.Indexer[models.Delegation]) []liveIndexer {
+	indexers := []liveIndexer{idx}
+	if cfg.Indexer.Staking {
+		indexers = append(indexers, indexer.NewStakingIndexer(dbpool, cfg.TzKTURL, cfg.Indexer))
+	}
+	return indexers
+}
+
+// runIndexers initializes the cursors and polls until ctx is cancelled, each indexer on its own goroutine.
+// With leader election enabled, this only happens while this replica holds the indexer lease,
+// and the cursors are reloaded from the database every time leadership is acquired.
+func runIndexers(ctx context.Context, dbpool *pgxpool.Pool, indexers ...liveIndexer) error {
+	lead := func(ctx context.Context) error {
+		// An indexer failing to initialize stops the others, so that they restart together
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var wg sync.WaitGroup
+		errs := make([]error, len(indexers))
+		for n, idx := range indexers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := idx.Initialize(ctx); err != nil {
+					if ctx.Err() == nil {
+						errs[n] = fmt.Errorf("failed to initialize %s indexer: %w", idx.Name(), err)
+						cancel()
+					}
+					return
+				}
+				errs[n] = idx.Run(ctx, cfg.Indexer.Interval, cfg.ShutdownTimeout)
+			}()
+		}
+		wg.Wait()
+		return errors.Join(errs...)
+	}
+
+	if !cfg.Indexer.LeaderElection {
+		return lead(ctx)
+	}
+
+	instanceID := cfg.Indexer.InstanceID
+	if instanceID == "" {
+		instanceID = leader.DefaultHolder()
+	}
+
+	slog.Info("Leader election enabled", "lease", leader.IndexerLease, "holder", instanceID)
+	return leader.New(dbpool, leader.IndexerLease, instanceID, cfg.Indexer.LeaseTTL).Run(ctx, lead)
 }
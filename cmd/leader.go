@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/leader"
+	"github.com/spf13/cobra"
+)
+
+var leaderCmd = &cobra.Command{
+	Use:   "leader",
+	Short: "Show which indexer replica is currently leader",
+	Long:  `Print the holder of the indexer lease, i.e. the replica currently polling TzKT.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Get database connection string
+		connStr, err := getDatabaseURL()
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		dbpool, err := newPool(ctx, connStr)
+		if err != nil {
+			return fmt.Errorf("unable to create connection pool: %w", err)
+		}
+		defer dbpool.Close()
+
+		lease, err := db.GetLease(ctx, dbpool, leader.IndexerLease)
+		if err != nil {
+			return fmt.Errorf("failed to get lease: %w", err)
+		}
+
+		if lease == nil {
+			fmt.Println("No leader: the indexer lease is not held")
+			return nil
+		}
+
+		state := "active"
+		if lease.ExpiresAt.Before(time.Now()) {
+			state = "expired"
+		}
+
+		fmt.Printf("Leader:      %s (%s)\n", lease.Holder, state)
+		fmt.Printf("Acquired at: %s\n", lease.AcquiredAt.Format(time.RFC3339))
+		fmt.Printf("Renewed at:  %s\n", lease.RenewedAt.Format(time.RFC3339))
+		fmt.Printf("Expires at:  %s\n", lease.ExpiresAt.Format(time.RFC3339))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(leaderCmd)
+}
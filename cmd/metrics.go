@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/api"
+	"github.com/broyeztony/delegated/internal/metrics"
+	"github.com/spf13/cobra"
+)
+
+func addMetricsFlag(cmd *cobra.Command) {
+	cmd.Flags().String("metrics-addr", "", "Serve Prometheus metrics on this address, e.g. :9090 (disabled when empty)")
+	bindFlag(cmd.Flags(), "metrics-addr", "metrics-addr")
+}
+
+// startMetricsServer serves /metrics on the configured metrics address in the background until ctx is cancelled
+func startMetricsServer(ctx context.Context) {
+	metricsAddr := cfg.MetricsAddr
+	if metricsAddr == "" {
+		return
+	}
+
+	go func() {
+		if err := api.Serve(ctx, metricsAddr, metrics.Handler(), 5*time.Second); err != nil {
+			slog.Error("Metrics server error", "error", err)
+		}
+	}()
+}
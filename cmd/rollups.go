@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var rollupsCmd = &cobra.Command{
+	Use:   "rollups",
+	Short: "Manage the daily rollups behind the stats API",
+}
+
+var rollupsRebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "Recompute the daily rollups from the delegations table",
+	Long: `Recompute the daily rollups read by /xtz/delegations/stats from scratch, in one transaction.
+Inserts into the delegations table wait until it is done.
+
+The rollups are updated with each insert, a rebuild is only needed after loading the schema on
+existing data or writing to the delegations table outside of delegated.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		connStr, err := getDatabaseURL()
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		dbpool, err := newPool(ctx, connStr)
+		if err != nil {
+			return fmt.Errorf("unable to create connection pool: %w", err)
+		}
+		defer dbpool.Close()
+
+		start := time.Now()
+		rollups, err := db.RebuildRollups(ctx, dbpool)
+		if err != nil {
+			return fmt.Errorf("failed to rebuild rollups: %w", err)
+		}
+
+		slog.Info("Rebuilt rollups", "rollups", rollups, "duration", time.Since(start))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rollupsCmd)
+	rollupsCmd.AddCommand(rollupsRebuildCmd)
+}
@@ -1,22 +1,62 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/broyeztony/delegated/internal/config"
+	"github.com/broyeztony/delegated/internal/logging"
+	"github.com/broyeztony/delegated/internal/telemetry"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var (
 	cfgFile string
-	dbURL   string
+
+	// cfg is the validated configuration, loaded before any command runs
+	cfg *config.Config
+
+	shutdownTracing func(context.Context) error
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "delegated",
 	Short: "Tezos delegation indexer and API server",
 	Long:  `A simple service to index and serve Tezos delegation data.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := bindFlags(cmd); err != nil {
+			return err
+		}
+
+		loaded, err := config.Load(viper.GetViper())
+		if err != nil {
+			return err
+		}
+		cfg = loaded
+
+		if err := logging.Setup(cfg.Log.Format, cfg.Log.Level); err != nil {
+			return err
+		}
+
+		shutdown, err := telemetry.Setup(context.Background(), "delegated-"+cmd.Name(), cfg.Trace.Exporter)
+		if err != nil {
+			return err
+		}
+		shutdownTracing = shutdown
+		return nil
+	},
+	PersistentPostRunE: func(cmd *cobra.Command, args []string) error {
+		// Flush pending spans
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return shutdownTracing(ctx)
+	},
 }
 
 func Execute() {
@@ -29,12 +69,28 @@ func Execute() {
 func init() {
 	cobra.OnInitialize(initConfig)
 
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.solution1.yaml)")
-	rootCmd.PersistentFlags().StringVar(&dbURL, "db-url", "", "database connection URL (default from DB_URL env var)")
-
-	viper.BindPFlag("db-url", rootCmd.PersistentFlags().Lookup("db-url"))
-	viper.SetEnvPrefix("")
+	defaults := config.Default()
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&cfgFile, "config", "", "config file (default is $HOME/.delegated.yaml)")
+	flags.String("db-url", "", "database connection URL (default from DB_URL env var)")
+	flags.String("tzkt-url", defaults.TzKTURL, "TzKT delegations endpoint, also set by the TZ_API_URL env var")
+	flags.String("log-level", defaults.Log.Level, "log level: debug, info, warn or error")
+	flags.String("log-format", defaults.Log.Format, "log format: text or json")
+	flags.String("trace-exporter", defaults.Trace.Exporter, "OpenTelemetry trace exporter: none, stdout or otlp (configured via OTEL_EXPORTER_OTLP_* env vars)")
+	bindFlag(flags, "db-url", "db-url")
+	bindFlag(flags, "tzkt-url", "tzkt-url")
+	bindFlag(flags, "log-level", "log.level")
+	bindFlag(flags, "log-format", "log.format")
+	bindFlag(flags, "trace-exporter", "trace.exporter")
+
+	config.SetDefaults(viper.GetViper())
+
+	// Every key can be set as DELEGATED_<KEY>, e.g. DELEGATED_INDEXER_POLL_PAGE_SIZE;
+	// the historical DB_URL and TZ_API_URL variables are kept
+	viper.SetEnvPrefix("delegated")
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
 	viper.BindEnv("db-url", "DB_URL")
+	viper.BindEnv("tzkt-url", "TZ_API_URL")
 }
 
 func initConfig() {
@@ -51,22 +107,35 @@ func initConfig() {
 
 	viper.AutomaticEnv()
 
-	if err := viper.ReadInConfig(); err == nil {
+	err := viper.ReadInConfig()
+	if err == nil {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+		return
+	}
+
+	// A missing default config file is fine, an unreadable or explicitly requested one is not
+	var notFound viper.ConfigFileNotFoundError
+	if cfgFile != "" || !errors.As(err, &notFound) {
+		cobra.CheckErr(fmt.Errorf("failed to read config file: %w", err))
 	}
 }
 
-// getDatabaseURL retrieves the database connection string from flag, env var, or returns error if not set
+// getDatabaseURL returns the configured database connection string, or an error if not set
 func getDatabaseURL() (string, error) {
-	connStr := viper.GetString("db-url")
-
-	if connStr == "" {
-		connStr = os.Getenv("DB_URL")
+	if cfg.DatabaseURL == "" {
+		return "", fmt.Errorf("database URL is required: set --db-url flag, DB_URL environment variable or db-url in the config file")
 	}
 
-	if connStr == "" {
-		return "", fmt.Errorf("database URL is required: set --db-url flag or DB_URL environment variable")
+	return cfg.DatabaseURL, nil
+}
+
+// newPool creates a connection pool whose queries are traced with OpenTelemetry
+func newPool(ctx context.Context, connStr string) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		return nil, err
 	}
+	config.ConnConfig.Tracer = telemetry.PgxTracer{}
 
-	return connStr, nil
+	return pgxpool.NewWithConfig(ctx, config)
 }
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/api"
+	"github.com/broyeztony/delegated/internal/indexer"
+	"github.com/broyeztony/delegated/internal/stream"
+	"github.com/broyeztony/delegated/internal/supervisor"
+	"github.com/broyeztony/delegated/internal/webhook"
+	"github.com/spf13/cobra"
+)
+
+var runBackfill bool
+
+var runCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run the indexer and the API server in one process",
+	Long: `Run the live indexer, an optional background backfill and the HTTP API in a single process
+sharing one connection pool. Failed components are restarted with exponential backoff.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		slog.Info("Run command started")
+
+		// Get database connection string
+		connStr, err := getDatabaseURL()
+		if err != nil {
+			return err
+		}
+
+		// Initialize database connection, shared by all components
+		dbpool, err := newPool(context.Background(), connStr)
+		if err != nil {
+			return fmt.Errorf("unable to create connection pool: %w", err)
+		}
+		defer dbpool.Close()
+
+		// Cancel on SIGTERM/SIGINT
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
+
+		idx := indexer.NewIndexer(dbpool, cfg.TzKTURL, cfg.Indexer)
+		indexers := liveIndexers(dbpool, idx)
+
+		// The live feed follows commits through LISTEN/NOTIFY, as under serve: this replica's
+		// indexer may be a standby while another replica holds the lease and indexes
+		hub := newHub(ctx)
+
+		publishers, err := newSinkPublishers(dbpool, idx)
+		if err != nil {
+			return err
+		}
+
+		components := []supervisor.Component{
+			{
+				Name: "indexer",
+				Run: func(ctx context.Context) error {
+					return runIndexers(ctx, dbpool, indexers...)
+				},
+			},
+			{
+				Name: "api",
+				Run: func(ctx context.Context) error {
+					return api.Serve(ctx, cfg.API.Addr, api.NewRouter(dbpool, newHealth(dbpool), hub, cfg.Webhooks.AdminToken), cfg.ShutdownTimeout)
+				},
+			},
+			{
+				Name: "stream",
+				Run: func(ctx context.Context) error {
+					stream.NewListener(dbpool, hub, cfg.API.StreamCheckInterval).Run(ctx)
+					return nil
+				},
+			},
+			{
+				Name: "webhooks",
+				Run:  webhook.NewDispatcher(dbpool, cfg.Webhooks).Run,
+			},
+		}
+
+		for i, p := range publishers {
+			components = append(components, supervisor.Component{
+				Name: "sink " + cfg.Sinks[i].Name,
+				Run:  p.Run,
+			})
+		}
+
+		if runBackfill {
+			backfillers := []backfiller{idx}
+			if cfg.Indexer.Staking {
+				backfillers = append(backfillers, indexer.NewStakingIndexer(dbpool, cfg.TzKTURL, cfg.Indexer))
+			}
+			for _, b := range backfillers {
+				components = append(components, supervisor.Component{
+					Name: "backfill " + b.Name(),
+					Run: func(ctx context.Context) error {
+						// Fails (and is retried) until the indexer has inserted its first operation
+						count, minID, err := b.OldestID(ctx)
+						if err != nil {
+							return fmt.Errorf("failed to get min id: %w", err)
+						}
+						if count == 0 {
+							return fmt.Errorf("no %s indexed yet, waiting for the indexer", b.Name())
+						}
+
+						totalRecords, totalBatches, err := b.Backfill(ctx, minID)
+						if err != nil {
+							return err
+						}
+						slog.InfoContext(ctx, "Backfill summary", "indexer", b.Name(), "rows", totalRecords, "batches", totalBatches)
+						return nil
+					},
+				})
+			}
+		}
+
+		supervisor.New(time.Second, time.Minute, components...).Run(ctx)
+
+		slog.Info("All components stopped")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+	addIntervalFlag(runCmd)
+	addShutdownTimeoutFlag(runCmd, "Time given to in-flight batches and requests to finish on shutdown")
+	addLeaderElectionFlags(runCmd)
+	addStakingFlag(runCmd)
+	addAPIFlags(runCmd)
+	runCmd.Flags().BoolVar(&runBackfill, "backfill", false, "Backfill historical delegations (and staking operations with --staking) in the background")
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/broyeztony/delegated/internal/seed"
+	"github.com/spf13/cobra"
+)
+
+var (
+	seedRows      int
+	seedFrom      string
+	seedTo        string
+	seedOut       string
+	seedBatchSize int
+	seedRandSeed  int64
+)
+
+var seedCmd = &cobra.Command{
+	Use:   "seed",
+	Short: "Generate synthetic delegations",
+	Long: `Generate realistic synthetic delegations for load testing, either into the delegations table
+(using COPY protocol) or into a CSV file with --out.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		slog.Info("Seed command started")
+
+		from, err := time.Parse(time.DateOnly, seedFrom)
+		if err != nil {
+			return fmt.Errorf("invalid --from date: %w", err)
+		}
+		to := time.Now().UTC()
+		if seedTo != "" {
+			if to, err = time.Parse(time.DateOnly, seedTo); err != nil {
+				return fmt.Errorf("invalid --to date: %w", err)
+			}
+		}
+		if !to.After(from) {
+			return fmt.Errorf("--to must be after --from")
+		}
+		if seedRows <= 0 {
+			return fmt.Errorf("--rows must be positive")
+		}
+		if seedBatchSize <= 0 {
+			return fmt.Errorf("--batch-size must be positive")
+		}
+
+		opts := seed.Options{
+			Rows: seedRows,
+			From: from,
+			To:   to,
+			Seed: seedRandSeed,
+		}
+
+		var write func([]models.Delegation) error
+		ctx := context.Background()
+
+		if seedOut != "" {
+			f, err := os.Create(seedOut)
+			if err != nil {
+				return fmt.Errorf("failed to create output file: %w", err)
+			}
+			defer f.Close()
+
+			w := csv.NewWriter(f)
+			if err := w.Write([]string{"id", "delegator", "timestamp", "amount", "level", "baker", "prev_baker"}); err != nil {
+				return err
+			}
+			write = func(batch []models.Delegation) error {
+				for _, d := range batch {
+					record := []string{
+						strconv.FormatInt(d.ID, 10),
+						d.Delegator,
+						d.Timestamp.Format(time.RFC3339),
+						strconv.FormatInt(d.Amount, 10),
+						strconv.FormatInt(int64(d.Level), 10),
+						d.Baker,
+						d.PrevBaker,
+					}
+					if err := w.Write(record); err != nil {
+						return err
+					}
+				}
+				w.Flush()
+				return w.Error()
+			}
+		} else {
+			connStr, err := getDatabaseURL()
+			if err != nil {
+				return err
+			}
+
+			dbpool, err := newPool(ctx, connStr)
+			if err != nil {
+				return fmt.Errorf("unable to create connection pool: %w", err)
+			}
+			defer dbpool.Close()
+
+			// Start after existing rows so COPY does not hit primary key conflicts, and so the
+			// generated delegations do not go back in time and level
+			latest, err := db.GetLatestDelegation(ctx, dbpool)
+			if err != nil {
+				return fmt.Errorf("failed to get latest delegation: %w", err)
+			}
+			if latest != nil {
+				if err := opts.StartAfter(latest.ID, latest.Level, latest.Timestamp); err != nil {
+					return fmt.Errorf("table is not empty, --to must be after its latest delegation: %w", err)
+				}
+				slog.Info("Table is not empty, generating delegations after the latest one",
+					"cursor", latest.ID, "level", latest.Level, "timestamp", latest.Timestamp, "from", opts.From)
+			}
+
+			write = func(batch []models.Delegation) error {
+				return db.CopyInsertDelegations(ctx, dbpool, batch)
+			}
+		}
+
+		gen := seed.NewGenerator(opts)
+		batch := make([]models.Delegation, 0, seedBatchSize)
+		startTime := time.Now()
+		total := 0
+
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			if err := write(batch); err != nil {
+				return fmt.Errorf("failed to write batch: %w", err)
+			}
+			total += len(batch)
+			batch = batch[:0]
+			slog.Info("Wrote batch", "rows", total, "total_rows", seedRows)
+			return nil
+		}
+
+		for {
+			d, ok := gen.Next()
+			if !ok {
+				break
+			}
+			batch = append(batch, d)
+			if len(batch) == seedBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+
+		totalDuration := time.Since(startTime)
+		slog.Info("Seed summary", "rows", total, "duration", totalDuration, "rows_per_sec", float64(total)/totalDuration.Seconds())
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(seedCmd)
+	seedCmd.Flags().IntVar(&seedRows, "rows", 100000, "Number of delegations to generate")
+	seedCmd.Flags().StringVar(&seedFrom, "from", "2018-06-30", "Date of the first delegation (YYYY-MM-DD)")
+	seedCmd.Flags().StringVar(&seedTo, "to", "", "Approximate date of the last delegation (YYYY-MM-DD, default today)")
+	seedCmd.Flags().StringVar(&seedOut, "out", "", "Write a CSV file instead of inserting into the database")
+	seedCmd.Flags().IntVar(&seedBatchSize, "batch-size", 10000, "Number of rows per COPY batch")
+	seedCmd.Flags().Int64Var(&seedRandSeed, "seed", 1, "Random seed")
+}
@@ -3,15 +3,15 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"log"
-	"net/http"
-	"os"
+	"log/slog"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/broyeztony/delegated/internal/api"
-	"github.com/gin-gonic/gin"
+	"github.com/broyeztony/delegated/internal/config"
+	"github.com/broyeztony/delegated/internal/stream"
+	"github.com/broyeztony/delegated/internal/tzkt"
+	"github.com/broyeztony/delegated/internal/webhook"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/spf13/cobra"
 )
@@ -22,7 +22,7 @@ var serveCmd = &cobra.Command{
 	Long:  `Start the HTTP server to serve delegation data via REST API.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 
-		log.Println("Serve command started")
+		slog.Info("Serve command started")
 
 		// Get database connection string
 		connStr, err := getDatabaseURL()
@@ -31,52 +31,50 @@ var serveCmd = &cobra.Command{
 		}
 
 		// Initialize database connection
-		dbpool, err := pgxpool.New(context.Background(), connStr)
+		dbpool, err := newPool(context.Background(), connStr)
 		if err != nil {
 			return fmt.Errorf("unable to create connection pool: %w", err)
 		}
 		defer dbpool.Close()
 
-		gin.SetMode(gin.ReleaseMode)
-		r := gin.Default()
-		r.GET("/xtz/delegations", api.GetDelegations(dbpool))
+		// Cancel on SIGTERM/SIGINT
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+		defer stop()
 
-		// Create HTTP server with graceful shutdown
-		server := &http.Server{
-			Addr:    ":8080",
-			Handler: r,
-		}
-
-		// Channel to listen for interrupt signals
-		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
-
-		// Start server in a goroutine
-		log.Println("Server starting on :8080")
-		go func() {
-			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				log.Printf("server error: %v", err)
-			}
-		}()
+		// The indexer runs in another process: learn about its commits through LISTEN/NOTIFY
+		hub := newHub(ctx)
+		go stream.NewListener(dbpool, hub, cfg.API.StreamCheckInterval).Run(ctx)
 
-		// Wait for shutdown signal
-		<-sigChan
-		log.Println("Shutdown signal received, gracefully shutting down...")
-
-		// Create a context with 30-second timeout for graceful shutdown
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
-
-		// Shutdown the server
-		if err := server.Shutdown(ctx); err != nil {
-			return fmt.Errorf("server shutdown error: %w", err)
-		}
+		// Deliver the webhooks queued by the indexer
+		go webhook.NewDispatcher(dbpool, cfg.Webhooks).Run(ctx)
 
-		log.Println("Server stopped")
-		return nil
+		// Serve until a shutdown signal is received, then give in-flight requests the shutdown timeout to finish
+		return api.Serve(ctx, cfg.API.Addr, api.NewRouter(dbpool, newHealth(dbpool), hub, cfg.Webhooks.AdminToken), cfg.ShutdownTimeout)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(serveCmd)
+	addAPIFlags(serveCmd)
+	addShutdownTimeoutFlag(serveCmd, "Time given to in-flight requests to finish on shutdown")
+}
+
+func addAPIFlags(cmd *cobra.Command) {
+	defaults := config.Default().API
+	cmd.Flags().String("addr", defaults.Addr, "Address the API listens on")
+	cmd.Flags().Duration("max-staleness", defaults.MaxStaleness, "Report not ready when the indexer has not polled successfully, or caught up with a head, for this long (0 disables the check)")
+	bindFlag(cmd.Flags(), "addr", "api.addr")
+	bindFlag(cmd.Flags(), "max-staleness", "api.max-staleness")
+}
+
+// newHub creates the live feed hub, closed when ctx is cancelled so that open streams end on shutdown
+func newHub(ctx context.Context) *stream.Hub {
+	hub := stream.NewHub()
+	context.AfterFunc(ctx, hub.Close)
+	return hub
+}
+
+// newHealth creates the health endpoints, measuring lag against the head of the TzKT API the indexer reads from
+func newHealth(dbpool *pgxpool.Pool) *api.Health {
+	return api.NewHealth(dbpool, tzkt.HeadURL(cfg.TzKTURL), cfg.API.MaxStaleness)
 }
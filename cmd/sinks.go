@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/broyeztony/delegated/internal/config"
+	"github.com/broyeztony/delegated/internal/indexer"
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/broyeztony/delegated/internal/sink"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// newSinkPublishers opens the configured sinks and returns their publishers, woken up by every commit of idx
+func newSinkPublishers(dbpool *pgxpool.Pool, idx *indexer.Indexer[models.Delegation]) ([]*sink.Publisher, error) {
+	publishers := make([]*sink.Publisher, 0, len(cfg.Sinks))
+	var sinks []sink.Sink
+	for _, c := range cfg.Sinks {
+		s, err := openSink(c)
+		if err != nil {
+			for _, opened := range sinks {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("failed to open sink %s: %w", c.Name, err)
+		}
+		sinks = append(sinks, s)
+
+		p := sink.NewPublisher(dbpool, c.Name, s)
+		idx.OnCommit(func([]models.Delegation) { p.Notify() })
+		publishers = append(publishers, p)
+	}
+	return publishers, nil
+}
+
+func openSink(c config.Sink) (sink.Sink, error) {
+	switch c.Type {
+	case sink.TypeNDJSON:
+		return sink.NewNDJSON(c.Path)
+	case sink.TypeNATS:
+		return sink.NewNATS(c.URL, c.Topic)
+	case sink.TypeRedis:
+		return sink.NewRedis(c.URL, c.Topic, c.MaxLen)
+	default:
+		return nil, errors.New("unknown sink type " + c.Type)
+	}
+}
+
+// runSinkPublishers runs the publishers in the background until ctx is cancelled
+func runSinkPublishers(ctx context.Context, publishers []*sink.Publisher) {
+	for _, p := range publishers {
+		go p.Run(ctx)
+	}
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/tzkt"
+	"github.com/spf13/cobra"
+)
+
+var (
+	gapThreshold time.Duration
+	maxGaps      int
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show what has been indexed and how far behind the chain head it is",
+	Long: `Print the delegations row count, id and timestamp ranges, the indexer cursor and last ingest time,
+the position of each event sink, coverage gaps (periods without any delegation longer than --gap-threshold)
+and the lag behind the TzKT head.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Get database connection string
+		connStr, err := getDatabaseURL()
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		dbpool, err := newPool(ctx, connStr)
+		if err != nil {
+			return fmt.Errorf("unable to create connection pool: %w", err)
+		}
+		defer dbpool.Close()
+
+		summary, err := db.GetSummary(ctx, dbpool)
+		if err != nil {
+			return fmt.Errorf("failed to get summary: %w", err)
+		}
+		state, err := db.GetIndexerState(ctx, dbpool, db.DelegationsIndexer)
+		if err != nil {
+			return fmt.Errorf("failed to get indexer state: %w", err)
+		}
+		stakingState, err := db.GetIndexerState(ctx, dbpool, db.StakingIndexer)
+		if err != nil {
+			return fmt.Errorf("failed to get staking indexer state: %w", err)
+		}
+		gaps, err := db.FindCoverageGaps(ctx, dbpool, gapThreshold, maxGaps)
+		if err != nil {
+			return fmt.Errorf("failed to find coverage gaps: %w", err)
+		}
+		sinks, err := db.ListSinkCursors(ctx, dbpool)
+		if err != nil {
+			return fmt.Errorf("failed to list sink cursors: %w", err)
+		}
+
+		fmt.Printf("Delegations:  %d rows\n", summary.Count)
+		if summary.Count > 0 {
+			fmt.Printf("IDs:          %d - %d\n", *summary.MinID, *summary.MaxID)
+			fmt.Printf("Timestamps:   %s - %s\n", summary.MinTimestamp.Format(time.RFC3339), summary.MaxTimestamp.Format(time.RFC3339))
+		}
+
+		if state == nil {
+			fmt.Println("Indexer:      never polled")
+		} else {
+			fmt.Printf("Cursor:       %d\n", state.Cursor)
+			fmt.Printf("Last poll:    %s\n", formatAgo(&state.LastPollAt))
+			fmt.Printf("Last ingest:  %s\n", formatAgo(state.LastIngestAt))
+		}
+		if stakingState != nil {
+			fmt.Printf("Staking:      cursor %d, last poll %s, last ingest %s\n",
+				stakingState.Cursor, formatAgo(&stakingState.LastPollAt), formatAgo(stakingState.LastIngestAt))
+		}
+
+		for _, sc := range sinks {
+			behind := int64(0)
+			if summary.MaxID != nil {
+				behind = *summary.MaxID - sc.Cursor
+			}
+			fmt.Printf("Sink %-8s cursor %d (%d ids behind), last publish %s\n", sc.Name+":", sc.Cursor, behind, formatAgo(sc.PublishedAt))
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		head, err := tzkt.FetchHead(ctx, http.DefaultClient, tzkt.HeadURL(cfg.TzKTURL))
+		switch {
+		case err != nil:
+			fmt.Printf("Chain head:   unavailable (%v)\n", err)
+		default:
+			fmt.Printf("Chain head:   level %d at %s\n", head.Level, head.Timestamp.Format(time.RFC3339))
+			// Measured from the last head the indexer caught up with, not the latest delegation
+			if state != nil && state.SyncedLevel != nil && state.SyncedTimestamp != nil {
+				fmt.Printf("Lag:          %d levels, %s\n", head.Level-*state.SyncedLevel, head.Timestamp.Sub(*state.SyncedTimestamp).Round(time.Second))
+			}
+		}
+
+		if len(gaps) == 0 {
+			fmt.Printf("Gaps:         none longer than %s\n", gapThreshold)
+			return nil
+		}
+		fmt.Printf("Gaps:         %d longest periods without delegations over %s\n", len(gaps), gapThreshold)
+		for _, g := range gaps {
+			fmt.Printf("              %s - %s (%s)\n", g.From.Format(time.RFC3339), g.To.Format(time.RFC3339), g.To.Sub(g.From))
+		}
+		return nil
+	},
+}
+
+// formatAgo prints a time with how long ago it was
+func formatAgo(t *time.Time) string {
+	if t == nil {
+		return "never"
+	}
+	return fmt.Sprintf("%s (%s ago)", t.Format(time.RFC3339), time.Since(*t).Round(time.Second))
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().DurationVar(&gapThreshold, "gap-threshold", 6*time.Hour, "Report periods without any delegation longer than this")
+	statusCmd.Flags().IntVar(&maxGaps, "max-gaps", 10, "Maximum number of gaps to report, longest first")
+}
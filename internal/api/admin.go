@@ -0,0 +1,242 @@
+package api
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/broyeztony/delegated/internal/webhook"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CreateWebhookRequest is the body of POST /admin/webhooks. At least one filter is required.
+type CreateWebhookRequest struct {
+	URL string `json:"url"`
+	// Secret signs the deliveries; generated when empty
+	Secret    string  `json:"secret"`
+	Delegator *string `json:"delegator"`
+	Baker     *string `json:"baker"`
+	MinAmount *int64  `json:"min_amount"`
+}
+
+// WebhookResponse describes a webhook. The secret is only returned when the webhook is created.
+type WebhookResponse struct {
+	ID        int64   `json:"id"`
+	URL       string  `json:"url"`
+	Secret    string  `json:"secret,omitempty"`
+	Delegator *string `json:"delegator,omitempty"`
+	Baker     *string `json:"baker,omitempty"`
+	MinAmount *int64  `json:"min_amount,omitempty"`
+	CreatedAt string  `json:"created_at"`
+}
+
+// DeadLetterResponse describes a delivery that was given up on
+type DeadLetterResponse struct {
+	ID           int64  `json:"id"`
+	DelegationID int64  `json:"delegation_id"`
+	Attempts     int    `json:"attempts"`
+	LastError    string `json:"last_error"`
+	CreatedAt    string `json:"created_at"`
+	FailedAt     string `json:"failed_at"`
+}
+
+// adminAuth rejects requests without the admin bearer token, compared in constant time
+func adminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing admin token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// validate checks the request and turns it into a webhook, generating the secret if needed
+func (r CreateWebhookRequest) validate() (db.Webhook, error) {
+	u, err := url.Parse(r.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return db.Webhook{}, fmt.Errorf("url must be an http(s) URL")
+	}
+	if r.Delegator == nil && r.Baker == nil && r.MinAmount == nil {
+		return db.Webhook{}, fmt.Errorf("at least one of delegator, baker or min_amount is required")
+	}
+	if r.Delegator != nil && !models.IsAddress(*r.Delegator) {
+		return db.Webhook{}, fmt.Errorf("delegator must be a tz1, tz2, tz3, tz4 or KT1 address")
+	}
+	if r.Baker != nil && !models.IsAddress(*r.Baker) {
+		return db.Webhook{}, fmt.Errorf("baker must be a tz1, tz2, tz3, tz4 or KT1 address")
+	}
+	if r.MinAmount != nil && *r.MinAmount < 0 {
+		return db.Webhook{}, fmt.Errorf("min_amount must be a non-negative integer (mutez)")
+	}
+
+	secret := r.Secret
+	if secret == "" {
+		secret = webhook.NewSecret()
+	}
+
+	return db.Webhook{
+		URL:       r.URL,
+		Secret:    secret,
+		Delegator: r.Delegator,
+		Baker:     r.Baker,
+		MinAmount: r.MinAmount,
+	}, nil
+}
+
+func toWebhookResponse(w db.Webhook) WebhookResponse {
+	return WebhookResponse{
+		ID:        w.ID,
+		URL:       w.URL,
+		Delegator: w.Delegator,
+		Baker:     w.Baker,
+		MinAmount: w.MinAmount,
+		CreatedAt: w.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// pathID parses an id path parameter, answering 400 when invalid
+func pathID(c *gin.Context, param string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(param), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be a positive integer"})
+		return 0, false
+	}
+	return id, true
+}
+
+// CreateWebhook registers a webhook and returns it with its secret, which is not shown again
+func CreateWebhook(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request CreateWebhookRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON body"})
+			return
+		}
+		w, err := request.validate()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		created, err := db.CreateWebhook(c.Request.Context(), pool, w)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to create webhook", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		slog.InfoContext(c.Request.Context(), "Webhook created", "webhook_id", created.ID, "url", created.URL)
+
+		response := toWebhookResponse(*created)
+		response.Secret = created.Secret
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+// ListWebhooks returns every webhook, without their secrets
+func ListWebhooks(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		webhooks, err := db.ListWebhooks(c.Request.Context(), pool)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to list webhooks", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		responseData := make([]WebhookResponse, 0, len(webhooks))
+		for _, w := range webhooks {
+			responseData = append(responseData, toWebhookResponse(w))
+		}
+		c.JSON(http.StatusOK, gin.H{"data": responseData})
+	}
+}
+
+// DeleteWebhook deletes a webhook along with its pending deliveries and dead letters
+func DeleteWebhook(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := pathID(c, "id")
+		if !ok {
+			return
+		}
+
+		deleted, err := db.DeleteWebhook(c.Request.Context(), pool, id)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to delete webhook", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !deleted {
+			c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+			return
+		}
+		slog.InfoContext(c.Request.Context(), "Webhook deleted", "webhook_id", id)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// ListDeadLetters returns the deliveries of a webhook that were given up on
+func ListDeadLetters(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := pathID(c, "id")
+		if !ok {
+			return
+		}
+
+		deadLetters, err := db.ListDeadLetters(c.Request.Context(), pool, id)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to list dead letters", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		responseData := make([]DeadLetterResponse, 0, len(deadLetters))
+		for _, d := range deadLetters {
+			responseData = append(responseData, DeadLetterResponse{
+				ID:           d.ID,
+				DelegationID: d.DelegationID,
+				Attempts:     d.Attempts,
+				LastError:    d.LastError,
+				CreatedAt:    d.CreatedAt.Format(time.RFC3339),
+				FailedAt:     d.FailedAt.Format(time.RFC3339),
+			})
+		}
+		c.JSON(http.StatusOK, gin.H{"data": responseData})
+	}
+}
+
+// RetryDeadLetter queues a dead letter for immediate redelivery
+func RetryDeadLetter(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := pathID(c, "id")
+		if !ok {
+			return
+		}
+		deadLetterID, ok := pathID(c, "dead_letter_id")
+		if !ok {
+			return
+		}
+
+		err := db.RetryDeadLetter(c.Request.Context(), pool, id, deadLetterID)
+		if errors.Is(err, db.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "dead letter not found"})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to retry dead letter", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Status(http.StatusAccepted)
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminAuth(t *testing.T) {
+	r := NewRouter(nil, nil, nil, "token")
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{name: "missing token", want: http.StatusUnauthorized},
+		{name: "wrong token", authorization: "Bearer nope", want: http.StatusUnauthorized},
+		{name: "not a bearer token", authorization: "token", want: http.StatusUnauthorized},
+		// The token is accepted: the request reaches validation
+		{name: "valid token", authorization: "Bearer token", want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/admin/webhooks", strings.NewReader("{}"))
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdminDisabledWithoutToken(t *testing.T) {
+	r := NewRouter(nil, nil, nil, "")
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/webhooks", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 when no admin token is configured", w.Code)
+	}
+}
+
+func TestCreateWebhookRequest_Validate(t *testing.T) {
+	delegator := "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo"
+	short := "tz1short"
+	negative := int64(-1)
+	amount := int64(1000000)
+
+	tests := []struct {
+		name    string
+		request CreateWebhookRequest
+		wantErr string
+	}{
+		{name: "delegator filter", request: CreateWebhookRequest{URL: "https://example.com/hook", Delegator: &delegator}},
+		{name: "amount filter", request: CreateWebhookRequest{URL: "http://example.com", MinAmount: &amount}},
+		{name: "no filter", request: CreateWebhookRequest{URL: "https://example.com/hook"}, wantErr: "at least one"},
+		{name: "relative url", request: CreateWebhookRequest{URL: "/hook", Delegator: &delegator}, wantErr: "url"},
+		{name: "ftp url", request: CreateWebhookRequest{URL: "ftp://example.com", Delegator: &delegator}, wantErr: "url"},
+		{name: "invalid baker", request: CreateWebhookRequest{URL: "https://example.com", Baker: &short}, wantErr: "baker"},
+		{name: "negative amount", request: CreateWebhookRequest{URL: "https://example.com", MinAmount: &negative}, wantErr: "min_amount"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := tt.request.validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validate() error = %v", err)
+				}
+				if len(w.Secret) != 64 {
+					t.Errorf("secret = %q, want a generated 32-byte hex secret", w.Secret)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validate() error = %v, want mention of %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Page sizes of the baker endpoints
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// BakerResponse is a baker with its current delegators. Amounts are mutez strings.
+type BakerResponse struct {
+	Address         string `json:"address"`
+	Delegators      int64  `json:"delegators"`
+	DelegatedAmount string `json:"delegated_amount"`
+}
+
+// DelegatorResponse is a current delegator of a baker
+type DelegatorResponse struct {
+	Delegator string `json:"delegator"`
+	Amount    string `json:"amount"`
+	Since     string `json:"since"`
+	Level     string `json:"level"`
+	PrevBaker string `json:"prev_baker"`
+}
+
+// BakerHistoryResponse counts the joins and leaves of a baker in one interval
+type BakerHistoryResponse struct {
+	Bucket              string `json:"bucket"`
+	Joins               int64  `json:"joins"`
+	Leaves              int64  `json:"leaves"`
+	JoinedAmount        string `json:"joined_amount"`
+	LeftAmount          string `json:"left_amount"`
+	NetAmount           string `json:"net_amount"`
+	CumulativeNetAmount string `json:"cumulative_net_amount"`
+}
+
+// parseLimit validates the optional limit parameter
+func parseLimit(c *gin.Context) (int, error) {
+	value := c.Query("limit")
+	if value == "" {
+		return defaultLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxLimit {
+		return 0, fmt.Errorf("limit must be a number between 1 and %d", maxLimit)
+	}
+	return limit, nil
+}
+
+// addressParam validates the address path parameter
+func addressParam(c *gin.Context) (string, error) {
+	address := c.Param("address")
+	if !models.IsAddress(address) {
+		return "", fmt.Errorf("address must be a Tezos address")
+	}
+	return address, nil
+}
+
+// ListBakers returns the bakers with the most current delegators, i.e. delegators whose latest
+// delegation is to the baker
+func ListBakers(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, err := parseLimit(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		bakers, err := db.ListBakers(c.Request.Context(), pool, limit)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to list bakers", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		responseData := make([]BakerResponse, 0, len(bakers))
+		for _, b := range bakers {
+			responseData = append(responseData, BakerResponse{
+				Address:         b.Address,
+				Delegators:      b.Delegators,
+				DelegatedAmount: strconv.FormatInt(b.DelegatedAmount, 10),
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": responseData})
+	}
+}
+
+// ListBakerDelegators returns the current delegators of a baker, largest balance first, or
+// its delegators after the block at level, or at a time, when either is given
+func ListBakerDelegators(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		address, err := addressParam(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		limit, err := parseLimit(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		at, past, err := parsePointInTime(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var delegators []db.Delegator
+		if past {
+			delegators, err = db.ListBakerDelegatorsAt(c.Request.Context(), pool, address, at, limit)
+		} else {
+			delegators, err = db.ListBakerDelegators(c.Request.Context(), pool, address, limit)
+		}
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to list delegators", "baker", address, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		responseData := make([]DelegatorResponse, 0, len(delegators))
+		for _, d := range delegators {
+			responseData = append(responseData, DelegatorResponse{
+				Delegator: d.Address,
+				Amount:    strconv.FormatInt(d.Amount, 10),
+				Since:     d.Since.Format(time.RFC3339),
+				Level:     strconv.FormatInt(int64(d.Level), 10),
+				PrevBaker: d.PrevBaker,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": responseData})
+	}
+}
+
+// GetBakerHistory returns the delegators joining and leaving a baker by day, week, month or year
+func GetBakerHistory(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		address, err := addressParam(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		interval, from, to, err := parseTimeSeries(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		points, err := db.GetBakerHistory(c.Request.Context(), pool, address, interval, from, to)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to get baker history", "baker", address, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		responseData := make([]BakerHistoryResponse, 0, len(points))
+		for _, p := range points {
+			responseData = append(responseData, BakerHistoryResponse{
+				Bucket:              p.Start.Format(time.RFC3339),
+				Joins:               p.Joins,
+				Leaves:              p.Leaves,
+				JoinedAmount:        strconv.FormatInt(p.JoinedAmount, 10),
+				LeftAmount:          strconv.FormatInt(p.LeftAmount, 10),
+				NetAmount:           strconv.FormatInt(p.NetAmount, 10),
+				CumulativeNetAmount: strconv.FormatInt(p.CumulativeNetAmount, 10),
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"interval": interval,
+			"data":     responseData,
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBakers_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "limit zero", path: "/xtz/bakers?limit=0"},
+		{name: "limit too large", path: "/xtz/bakers?limit=1001"},
+		{name: "non numeric limit", path: "/xtz/bakers?limit=all"},
+		{name: "invalid baker address", path: "/xtz/bakers/everstake/delegators"},
+		{name: "invalid delegators limit", path: "/xtz/bakers/" + testDelegator + "/delegators?limit=-5"},
+		{name: "missing interval", path: "/xtz/bakers/" + testDelegator + "/history"},
+		{name: "invalid history address", path: "/xtz/bakers/everstake/history?interval=day"},
+		{name: "invalid from", path: "/xtz/bakers/" + testDelegator + "/history?interval=day&from=yesterday"},
+		{name: "invalid delegator address", path: "/xtz/delegators/alice"},
+		{name: "delegation at without point", path: "/xtz/delegators/" + testDelegator + "/at"},
+		{name: "delegation at invalid level", path: "/xtz/delegators/" + testDelegator + "/at?level=-1"},
+		{name: "delegators at invalid time", path: "/xtz/bakers/" + testDelegator + "/delegators?at=last-year"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/xtz/delegators/:address", GetCurrentDelegation(nil))
+	r.GET("/xtz/delegators/:address/at", GetDelegationAt(nil))
+	r.GET("/xtz/bakers", ListBakers(nil))
+	r.GET("/xtz/bakers/:address/delegators", ListBakerDelegators(nil))
+	r.GET("/xtz/bakers/:address/history", GetBakerHistory(nil))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", w.Code)
+			}
+		})
+	}
+}
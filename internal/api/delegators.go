@@ -0,0 +1,116 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CurrentDelegationResponse is the latest delegation of a delegator.
+// Baker is empty after an undelegation.
+type CurrentDelegationResponse struct {
+	Delegator string `json:"delegator"`
+	Baker     string `json:"baker"`
+	Since     string `json:"since"`
+	Level     string `json:"level"`
+	Amount    string `json:"amount"`
+}
+
+// parsePointInTime validates the level (a block level) and at (a date or an RFC 3339 time)
+// parameters, of which at most one can be set. ok is false when neither is.
+func parsePointInTime(c *gin.Context) (at db.PointInTime, ok bool, err error) {
+	level, timestamp := c.Query("level"), c.Query("at")
+	switch {
+	case level != "" && timestamp != "":
+		return at, false, fmt.Errorf("level and at are mutually exclusive")
+	case level != "":
+		n, err := strconv.ParseInt(level, 10, 32)
+		if err != nil || n < 1 {
+			return at, false, fmt.Errorf("level must be a positive number")
+		}
+		at.Level = int32(n)
+	case timestamp != "":
+		if at.Timestamp, err = time.Parse(time.DateOnly, timestamp); err != nil {
+			if at.Timestamp, err = time.Parse(time.RFC3339, timestamp); err != nil {
+				return at, false, fmt.Errorf("at must be YYYY-MM-DD or an RFC 3339 time")
+			}
+		}
+	default:
+		return at, false, nil
+	}
+	return at, true, nil
+}
+
+// toCurrentDelegationResponse formats the latest delegation of a delegator
+func toCurrentDelegationResponse(d *db.CurrentDelegation) CurrentDelegationResponse {
+	return CurrentDelegationResponse{
+		Delegator: d.Delegator,
+		Baker:     d.Baker,
+		Since:     d.Timestamp.Format(time.RFC3339),
+		Level:     strconv.FormatInt(int64(d.Level), 10),
+		Amount:    strconv.FormatInt(d.Amount, 10),
+	}
+}
+
+// GetCurrentDelegation returns the baker a delegator is currently delegating to, 404 if it never delegated
+func GetCurrentDelegation(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		address, err := addressParam(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		current, err := db.GetCurrentDelegation(c.Request.Context(), pool, address)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to get current delegation", "delegator", address, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if current == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no delegation from this address"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": toCurrentDelegationResponse(current)})
+	}
+}
+
+// GetDelegationAt returns the baker a delegator was delegating to after the block at level, or at
+// a time, reconstructed from the delegation events. 404 if it had not delegated yet.
+func GetDelegationAt(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		address, err := addressParam(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		at, ok, err := parsePointInTime(c)
+		if err == nil && !ok {
+			err = fmt.Errorf("level or at is required")
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		delegation, err := db.GetDelegationAt(c.Request.Context(), pool, address, at)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to get delegation", "delegator", address, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if delegation == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no delegation from this address at that point"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": toCurrentDelegationResponse(delegation)})
+	}
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestParsePointInTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    db.PointInTime
+		wantOK  bool
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "level", query: "level=2000000", want: db.PointInTime{Level: 2000000}, wantOK: true},
+		{name: "date", query: "at=2023-01-01", want: db.PointInTime{Timestamp: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}, wantOK: true},
+		{name: "time", query: "at=2023-01-01T12:30:00Z", want: db.PointInTime{Timestamp: time.Date(2023, 1, 1, 12, 30, 0, 0, time.UTC)}, wantOK: true},
+		{name: "both", query: "level=1&at=2023-01-01", wantErr: true},
+		{name: "level zero", query: "level=0", wantErr: true},
+		{name: "level not a number", query: "level=head", wantErr: true},
+		{name: "invalid at", query: "at=01/01/2023", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+
+			got, ok, err := parsePointInTime(c)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parsePointInTime() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil || ok != tt.wantOK || got.Level != tt.want.Level || !got.Timestamp.Equal(tt.want.Timestamp) {
+				t.Errorf("parsePointInTime() = %+v, %v, %v, want %+v, %v", got, ok, err, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
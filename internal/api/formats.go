@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+// Response formats of the delegations endpoint
+const (
+	formatJSON   = "json"
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+)
+
+const (
+	mimeJSON   = "application/json"
+	mimeCSV    = "text/csv"
+	mimeNDJSON = "application/x-ndjson"
+)
+
+var formatsByMIME = map[string]string{
+	mimeJSON:   formatJSON,
+	mimeCSV:    formatCSV,
+	mimeNDJSON: formatNDJSON,
+}
+
+// errNotAcceptable is returned by negotiateFormat when the Accept header allows no supported format
+var errNotAcceptable = fmt.Errorf("Accept must allow %s, %s or %s", mimeJSON, mimeCSV, mimeNDJSON)
+
+// negotiateFormat picks the response format from the format query parameter or else the Accept header,
+// JSON by default. It fails when the format parameter is unknown or no Accept type is supported.
+func negotiateFormat(c *gin.Context) (string, error) {
+	if format := c.Query("format"); format != "" {
+		switch format {
+		case formatJSON, formatCSV, formatNDJSON:
+			return format, nil
+		}
+		return "", fmt.Errorf("format must be %s, %s or %s", formatJSON, formatCSV, formatNDJSON)
+	}
+
+	mime := c.NegotiateFormat(mimeJSON, mimeCSV, mimeNDJSON)
+	if mime == "" {
+		return "", errNotAcceptable
+	}
+	return formatsByMIME[mime], nil
+}
+
+// rowEncoder writes delegations one at a time, so that a response streams from the database
+// without being held in memory
+type rowEncoder interface {
+	ContentType() string
+	Encode(d db.AttributedDelegation) error
+	// Flush writes buffered rows to the underlying writer
+	Flush() error
+}
+
+// newRowEncoder returns the encoder of a streaming format, writing the header row if any
+func newRowEncoder(w io.Writer, format string) (rowEncoder, error) {
+	switch format {
+	case formatCSV:
+		e := &csvEncoder{w: csv.NewWriter(w)}
+		return e, e.w.Write([]string{"timestamp", "amount", "delegator", "level", "cycle", "protocol"})
+	case formatNDJSON:
+		return &ndjsonEncoder{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("format %s cannot be streamed", format)
+	}
+}
+
+// csvEncoder writes the columns of DelegationResponse, with a header row. Unknown cycles and
+// protocols are empty.
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) ContentType() string { return mimeCSV + "; charset=utf-8" }
+
+func (e *csvEncoder) Encode(d db.AttributedDelegation) error {
+	r := toAttributedResponse(d)
+	var cycle, protocol string
+	if r.Cycle != nil {
+		cycle = *r.Cycle
+	}
+	if r.Protocol != nil {
+		protocol = *r.Protocol
+	}
+	return e.w.Write([]string{r.Timestamp, r.Amount, r.Delegator, r.Level, cycle, protocol})
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// ndjsonEncoder writes one DelegationResponse object per line
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) ContentType() string { return mimeNDJSON }
+
+func (e *ndjsonEncoder) Encode(d db.AttributedDelegation) error {
+	return e.enc.Encode(toAttributedResponse(d))
+}
+
+func (e *ndjsonEncoder) Flush() error { return nil }
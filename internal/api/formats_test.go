@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		accept  string
+		want    string
+		wantErr error
+	}{
+		{name: "default", want: formatJSON},
+		{name: "any", accept: "*/*", want: formatJSON},
+		{name: "csv accept", accept: "text/csv", want: formatCSV},
+		{name: "ndjson accept", accept: "application/x-ndjson", want: formatNDJSON},
+		{name: "browser accept", accept: "text/html,application/xhtml+xml,*/*;q=0.8", want: formatJSON},
+		{name: "parameter overrides accept", query: "format=ndjson", accept: "text/csv", want: formatNDJSON},
+		{name: "unknown parameter", query: "format=xml"},
+		{name: "unsupported accept", accept: "application/xml", wantErr: errNotAcceptable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/xtz/delegations?"+tt.query, nil)
+			if tt.accept != "" {
+				c.Request.Header.Set("Accept", tt.accept)
+			}
+
+			got, err := negotiateFormat(c)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("negotiateFormat() = %q, want an error", got)
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("negotiateFormat() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("negotiateFormat() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRowEncoders(t *testing.T) {
+	cycle, protocol := int32(515), "PtJakart2xVj7pYXJBXrqHgd82rdkLey5ZeeGikgRRZWcwDYGmZ"
+	delegations := []db.AttributedDelegation{
+		{
+			Delegation: models.Delegation{ID: 1, Delegator: "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo", Timestamp: time.Date(2022, 5, 5, 6, 29, 14, 0, time.UTC), Amount: 125896, Level: 2338084},
+			Cycle:      &cycle,
+			Protocol:   &protocol,
+		},
+		{
+			// Not attributed yet: the cycles and protocols are not synced
+			Delegation: models.Delegation{ID: 2, Delegator: "tz1QMwsi5onCV9yR2VJsSCRBDsrSfzrViMss", Timestamp: time.Date(2022, 5, 5, 6, 30, 0, 0, time.UTC), Amount: 1, Level: 2338085},
+		},
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: formatCSV,
+			want: "timestamp,amount,delegator,level,cycle,protocol\n" +
+				"2022-05-05T06:29:14Z,125896,tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo,2338084,515,PtJakart2xVj7pYXJBXrqHgd82rdkLey5ZeeGikgRRZWcwDYGmZ\n" +
+				"2022-05-05T06:30:00Z,1,tz1QMwsi5onCV9yR2VJsSCRBDsrSfzrViMss,2338085,,\n",
+		},
+		{
+			format: formatNDJSON,
+			want: `{"timestamp":"2022-05-05T06:29:14Z","amount":"125896","delegator":"tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo","level":"2338084","cycle":"515","protocol":"PtJakart2xVj7pYXJBXrqHgd82rdkLey5ZeeGikgRRZWcwDYGmZ"}` + "\n" +
+				`{"timestamp":"2022-05-05T06:30:00Z","amount":"1","delegator":"tz1QMwsi5onCV9yR2VJsSCRBDsrSfzrViMss","level":"2338085"}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			encoder, err := newRowEncoder(&buf, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range delegations {
+				if err := encoder.Encode(d); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := encoder.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("output =\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+
+	if _, err := newRowEncoder(&bytes.Buffer{}, formatJSON); err == nil {
+		t.Errorf("newRowEncoder(json) error = nil, want an error: JSON is not streamed")
+	}
+}
@@ -1,22 +1,53 @@
 package api
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/broyeztony/delegated/internal/db"
 	"github.com/broyeztony/delegated/internal/models"
+	"github.com/broyeztony/delegated/internal/telemetry"
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// DelegationResponse is a delegation in API responses. Cycle and Protocol are only set by the
+// delegations endpoint, once the cycles and protocols covering the delegation are synced.
 type DelegationResponse struct {
-	Timestamp string `json:"timestamp"`
-	Amount    string `json:"amount"`
-	Delegator string `json:"delegator"`
-	Level     string `json:"level"`
+	Timestamp string  `json:"timestamp"`
+	Amount    string  `json:"amount"`
+	Delegator string  `json:"delegator"`
+	Level     string  `json:"level"`
+	Cycle     *string `json:"cycle,omitempty"`
+	Protocol  *string `json:"protocol,omitempty"`
+}
+
+// toResponse formats a delegation for API responses
+func toResponse(d models.Delegation) DelegationResponse {
+	return DelegationResponse{
+		Timestamp: d.Timestamp.Format(time.RFC3339),
+		Amount:    strconv.FormatInt(d.Amount, 10),
+		Delegator: d.Delegator,
+		Level:     strconv.FormatInt(int64(d.Level), 10),
+	}
+}
+
+// toAttributedResponse formats a delegation with its cycle and protocol for API responses
+func toAttributedResponse(d db.AttributedDelegation) DelegationResponse {
+	r := toResponse(d.Delegation)
+	if d.Cycle != nil {
+		cycle := strconv.FormatInt(int64(*d.Cycle), 10)
+		r.Cycle = &cycle
+	}
+	r.Protocol = d.Protocol
+	return r
 }
 
 // validateYear validates the year parameter
@@ -37,47 +68,99 @@ func validateYear(yearParam string) (int, error) {
 	return year, nil
 }
 
-func GetDelegations(db *pgxpool.Pool) gin.HandlerFunc {
+// validateCycle validates the cycle parameter
+func validateCycle(cycleParam string) (int32, error) {
+	cycle, err := strconv.ParseInt(cycleParam, 10, 32)
+	if err != nil || cycle < 0 {
+		return 0, fmt.Errorf("cycle must be a non-negative integer")
+	}
+	return int32(cycle), nil
+}
+
+// GetDelegations returns the delegations, most recent first, optionally for one year, cycle or protocol.
+// The response is a JSON envelope by default; CSV and NDJSON (negotiated through the format
+// parameter or the Accept header) are streamed row by row from the database.
+func GetDelegations(pool *pgxpool.Pool) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get optional year parameter
-		yearParam := c.Query("year")
+		format, err := negotiateFormat(c)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errNotAcceptable) {
+				status = http.StatusNotAcceptable
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
 
-		query := "SELECT id, delegator, timestamp, amount, level FROM delegations"
+		query := db.AttributedDelegationsSQL
+		var conditions []string
 		args := []interface{}{}
+		filename := "delegations"
 
-		if yearParam != "" {
+		// Get optional year, cycle and protocol parameters
+		if yearParam := c.Query("year"); yearParam != "" {
 			year, err := validateYear(yearParam)
 			if err != nil {
 				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 				return
 			}
-			query += " WHERE EXTRACT(YEAR FROM timestamp) = $1"
 			args = append(args, year)
+			conditions = append(conditions, fmt.Sprintf("EXTRACT(YEAR FROM d.timestamp) = $%d", len(args)))
+			filename += "-" + yearParam
+		}
+
+		if cycleParam := c.Query("cycle"); cycleParam != "" {
+			cycle, err := validateCycle(cycleParam)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			args = append(args, cycle)
+			conditions = append(conditions, db.CycleCondition(fmt.Sprintf("$%d", len(args))))
+			filename += "-cycle-" + cycleParam
+		}
+
+		if protocol := c.Query("protocol"); protocol != "" {
+			if !models.IsProtocolHash(protocol) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "protocol must be a protocol hash"})
+				return
+			}
+			args = append(args, protocol)
+			conditions = append(conditions, db.ProtocolCondition(fmt.Sprintf("$%d", len(args))))
+			filename += "-" + protocol
 		}
 
-		query += " ORDER BY timestamp DESC"
+		if len(conditions) > 0 {
+			query += " WHERE " + strings.Join(conditions, " AND ")
+		}
+		query += " ORDER BY d.timestamp DESC"
 
-		rows, err := db.Query(c.Request.Context(), query, args...)
+		rows, err := pool.Query(c.Request.Context(), query, args...)
 		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to query delegations", "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 		defer rows.Close()
 
-		delegations, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Delegation])
+		if format != formatJSON {
+			streamRows(c, rows, format, filename)
+			return
+		}
+
+		delegations, err := pgx.CollectRows(rows, pgx.RowToStructByName[db.AttributedDelegation])
 		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to read delegations", "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
+		_, span := telemetry.Tracer().Start(c.Request.Context(), "json.encode")
+		defer span.End()
+
 		responseData := make([]DelegationResponse, 0, len(delegations))
 		for _, d := range delegations {
-			responseData = append(responseData, DelegationResponse{
-				Timestamp: d.Timestamp.Format(time.RFC3339),
-				Amount:    strconv.FormatInt(d.Amount, 10),
-				Delegator: d.Delegator,
-				Level:     strconv.FormatInt(int64(d.Level), 10),
-			})
+			responseData = append(responseData, toAttributedResponse(d))
 		}
 
 		c.JSON(http.StatusOK, gin.H{
@@ -85,3 +168,53 @@ func GetDelegations(db *pgxpool.Pool) gin.HandlerFunc {
 		})
 	}
 }
+
+// streamRows writes rows in a streaming format as they are read. Once the first row is read
+// the status is sent, so a later database error can only end the response early.
+func streamRows(c *gin.Context, rows pgx.Rows, format, filename string) {
+	ctx := c.Request.Context()
+
+	hasRow := rows.Next()
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(ctx, "Failed to read delegations", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	buf := bufio.NewWriterSize(c.Writer, 32<<10)
+	encoder, err := newRowEncoder(buf, format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", encoder.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, format))
+	c.Status(http.StatusOK)
+
+	count := 0
+	for ; hasRow; hasRow = rows.Next() {
+		d, err := pgx.RowToStructByName[db.AttributedDelegation](rows)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to read delegation", "error", err)
+			break
+		}
+		if err := encoder.Encode(d); err != nil {
+			// The client went away
+			slog.WarnContext(ctx, "Failed to write delegation", "rows", count, "error", err)
+			return
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(ctx, "Failed to read delegations, response truncated", "rows", count, "error", err)
+	}
+
+	err = encoder.Flush()
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to write delegations", "rows", count, "error", err)
+	}
+}
@@ -1,6 +1,12 @@
 package api
 
-import "testing"
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
 
 func TestValidateYear(t *testing.T) {
 	tests := []struct {
@@ -85,3 +91,54 @@ func TestValidateYear(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateCycle(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int32
+		wantErr bool
+	}{
+		{input: "745", want: 745},
+		{input: "0", want: 0},
+		{input: "-1", wantErr: true},
+		{input: "current", wantErr: true},
+		{input: "3000000000", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := validateCycle(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateCycle() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("validateCycle() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetDelegations_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "invalid year", path: "/xtz/delegations?year=1999"},
+		{name: "invalid cycle", path: "/xtz/delegations?cycle=-3"},
+		{name: "invalid protocol", path: "/xtz/delegations?protocol=paris"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/xtz/delegations", GetDelegations(nil))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/tzkt"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// headCacheTTL bounds how often /status queries the TzKT head
+const headCacheTTL = 10 * time.Second
+
+// Health serves the liveness, readiness and status endpoints
+type Health struct {
+	// maxStaleness is how long after the last successful indexer poll, or after the timestamp of
+	// the last head the indexer caught up with, the instance reports itself not ready. Zero
+	// disables the check.
+	maxStaleness time.Duration
+
+	ping          func(ctx context.Context) error
+	schemaVersion func(ctx context.Context) (int, error)
+	indexerState  func(ctx context.Context) (*db.IndexerState, error)
+	latest        func(ctx context.Context) (*db.LatestDelegation, error)
+	head          func(ctx context.Context) (*tzkt.Head, error)
+
+	mu          sync.Mutex
+	cachedHead  *tzkt.Head
+	headFetched time.Time
+}
+
+// NewHealth creates the health endpoints. headURL is the TzKT /v1/head endpoint; when empty,
+// /status reports no head and no lag.
+func NewHealth(pool *pgxpool.Pool, headURL string, maxStaleness time.Duration) *Health {
+	h := &Health{
+		maxStaleness: maxStaleness,
+		ping:         pool.Ping,
+		schemaVersion: func(ctx context.Context) (int, error) {
+			return db.GetSchemaVersion(ctx, pool)
+		},
+		indexerState: func(ctx context.Context) (*db.IndexerState, error) {
+			return db.GetIndexerState(ctx, pool, db.DelegationsIndexer)
+		},
+		latest: func(ctx context.Context) (*db.LatestDelegation, error) {
+			return db.GetLatestDelegation(ctx, pool)
+		},
+	}
+
+	if headURL != "" {
+		client := &http.Client{
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+			Timeout:   5 * time.Second,
+		}
+		h.head = func(ctx context.Context) (*tzkt.Head, error) {
+			return tzkt.FetchHead(ctx, client, headURL)
+		}
+	}
+
+	return h
+}
+
+// Liveness answers 200 as long as the process serves HTTP
+func (h *Health) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness answers 503 when the database is unreachable, the schema version does not
+// match this binary, or the indexer data is stale: no successful poll within maxStaleness,
+// or the last head it caught up with older than maxStaleness
+func (h *Health) Readiness(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if err := h.check(ctx); err != nil {
+		slog.WarnContext(ctx, "Not ready", "error", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
+func (h *Health) check(ctx context.Context) error {
+	if err := h.ping(ctx); err != nil {
+		return fmt.Errorf("database unreachable: %w", err)
+	}
+
+	version, err := h.schemaVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if version != db.SchemaVersion {
+		return fmt.Errorf("schema version is %d, want %d", version, db.SchemaVersion)
+	}
+
+	if h.maxStaleness == 0 {
+		return nil
+	}
+
+	state, err := h.indexerState(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read indexer state: %w", err)
+	}
+	return h.staleness(state)
+}
+
+// staleness returns why the indexed data is stale: the last successful poll, or the timestamp of
+// the last head the indexer caught up with, is older than maxStaleness. An indexer polling
+// successfully but behind by more than maxStaleness is stale too.
+func (h *Health) staleness(state *db.IndexerState) error {
+	if h.maxStaleness == 0 {
+		return nil
+	}
+	if state == nil || time.Since(state.LastPollAt) > h.maxStaleness {
+		return fmt.Errorf("indexer has not polled successfully for more than %s", h.maxStaleness)
+	}
+	if state.SyncedTimestamp == nil {
+		return fmt.Errorf("indexer has not caught up with the chain head yet")
+	}
+	if age := time.Since(*state.SyncedTimestamp); age > h.maxStaleness {
+		return fmt.Errorf("indexed data is stale: the last head the indexer caught up with is %s old, more than %s", age.Round(time.Second), h.maxStaleness)
+	}
+	return nil
+}
+
+// StatusResponse is the body of /status
+type StatusResponse struct {
+	Latest  *LatestStatus  `json:"latest"`
+	Indexer *IndexerStatus `json:"indexer"`
+	Head    *tzkt.Head     `json:"head"`
+	// LagLevels and LagSeconds measure how far the last head the indexer caught up with is behind the head
+	LagLevels  *int32   `json:"lag_levels"`
+	LagSeconds *float64 `json:"lag_seconds"`
+	Stale      bool     `json:"stale"`
+}
+
+// LatestStatus describes the most recent indexed delegation, for information: it does not measure lag
+type LatestStatus struct {
+	ID        int64     `json:"id"`
+	Level     int32     `json:"level"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// IndexerStatus is the progress recorded by the live indexer
+type IndexerStatus struct {
+	Cursor          int64      `json:"cursor"`
+	SyncedLevel     *int32     `json:"synced_level"`
+	SyncedTimestamp *time.Time `json:"synced_timestamp"`
+	LastPollAt      time.Time  `json:"last_poll_at"`
+	LastIngestAt    *time.Time `json:"last_ingest_at"`
+}
+
+// Status reports the latest indexed delegation, the indexer progress and the lag behind the chain head
+func (h *Health) Status(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	latest, err := h.latest(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get latest delegation", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get latest delegation"})
+		return
+	}
+
+	state, err := h.indexerState(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get indexer state", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get indexer state"})
+		return
+	}
+
+	response := StatusResponse{
+		Head:  h.chainHead(ctx),
+		Stale: h.staleness(state) != nil,
+	}
+	if latest != nil {
+		response.Latest = &LatestStatus{ID: latest.ID, Level: latest.Level, Timestamp: latest.Timestamp}
+	}
+	if state != nil {
+		response.Indexer = &IndexerStatus{
+			Cursor:          state.Cursor,
+			SyncedLevel:     state.SyncedLevel,
+			SyncedTimestamp: state.SyncedTimestamp,
+			LastPollAt:      state.LastPollAt,
+			LastIngestAt:    state.LastIngestAt,
+		}
+	}
+	// Lag is measured from the last head the indexer caught up with: on a quiet chain the
+	// latest delegation can be far behind a fully synced indexer
+	if state != nil && state.SyncedLevel != nil && state.SyncedTimestamp != nil && response.Head != nil {
+		levels := max(response.Head.Level-*state.SyncedLevel, 0)
+		seconds := max(response.Head.Timestamp.Sub(*state.SyncedTimestamp).Seconds(), 0)
+		response.LagLevels = &levels
+		response.LagSeconds = &seconds
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// chainHead returns the TzKT head, cached for headCacheTTL. A failed fetch falls back
+// to the previously cached head, so /status keeps answering while TzKT is down.
+func (h *Health) chainHead(ctx context.Context) *tzkt.Head {
+	if h.head == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cachedHead != nil && time.Since(h.headFetched) < headCacheTTL {
+		return h.cachedHead
+	}
+
+	head, err := h.head(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to fetch chain head", "error", err)
+		return h.cachedHead
+	}
+
+	h.cachedHead = head
+	h.headFetched = time.Now()
+	return head
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/tzkt"
+	"github.com/gin-gonic/gin"
+)
+
+// newTestHealth returns a Health backed by in-memory values instead of a database
+func newTestHealth(maxStaleness time.Duration, state *db.IndexerState) *Health {
+	return &Health{
+		maxStaleness:  maxStaleness,
+		ping:          func(ctx context.Context) error { return nil },
+		schemaVersion: func(ctx context.Context) (int, error) { return db.SchemaVersion, nil },
+		indexerState:  func(ctx context.Context) (*db.IndexerState, error) { return state, nil },
+		latest: func(ctx context.Context) (*db.LatestDelegation, error) {
+			return &db.LatestDelegation{ID: 100, Level: 1000, Timestamp: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}, nil
+		},
+	}
+}
+
+func serveHealth(h *Health, path string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/healthz", h.Liveness)
+	r.GET("/readyz", h.Readiness)
+	r.GET("/status", h.Status)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+func TestReadiness(t *testing.T) {
+	recent := time.Now().Add(-time.Minute)
+	fresh := &db.IndexerState{LastPollAt: recent, SyncedTimestamp: &recent}
+	old := &db.IndexerState{LastPollAt: time.Now().Add(-time.Hour), SyncedTimestamp: &recent}
+	// Polling every interval, but still catching up with a head from an hour ago
+	behindHead := time.Now().Add(-time.Hour)
+	behind := &db.IndexerState{LastPollAt: recent, SyncedTimestamp: &behindHead}
+	neverSynced := &db.IndexerState{LastPollAt: recent}
+
+	tests := []struct {
+		name       string
+		health     *Health
+		wantStatus int
+	}{
+		{name: "ready", health: newTestHealth(5*time.Minute, fresh), wantStatus: http.StatusOK},
+		{name: "stale", health: newTestHealth(5*time.Minute, old), wantStatus: http.StatusServiceUnavailable},
+		{name: "never polled", health: newTestHealth(5*time.Minute, nil), wantStatus: http.StatusServiceUnavailable},
+		{name: "behind the head", health: newTestHealth(5*time.Minute, behind), wantStatus: http.StatusServiceUnavailable},
+		{name: "never synced", health: newTestHealth(5*time.Minute, neverSynced), wantStatus: http.StatusServiceUnavailable},
+		{name: "staleness check disabled", health: newTestHealth(0, nil), wantStatus: http.StatusOK},
+		{
+			name: "database down",
+			health: func() *Health {
+				h := newTestHealth(0, nil)
+				h.ping = func(ctx context.Context) error { return errors.New("connection refused") }
+				return h
+			}(),
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "schema mismatch",
+			health: func() *Health {
+				h := newTestHealth(0, nil)
+				h.schemaVersion = func(ctx context.Context) (int, error) { return db.SchemaVersion - 1, nil }
+				return h
+			}(),
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serveHealth(tt.health, "/readyz"); w.Code != tt.wantStatus {
+				t.Errorf("/readyz = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w := serveHealth(tt.health, "/healthz"); w.Code != http.StatusOK {
+				t.Errorf("/healthz = %d, want 200", w.Code)
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	// The indexer caught up with level 1008 while the latest delegation is at level 1000
+	syncedLevel := int32(1008)
+	headTimestamp := time.Now().UTC().Truncate(time.Second)
+	syncedTimestamp := headTimestamp.Add(-16 * time.Second)
+	h := newTestHealth(5*time.Minute, &db.IndexerState{Cursor: 100, SyncedLevel: &syncedLevel, SyncedTimestamp: &syncedTimestamp, LastPollAt: time.Now()})
+
+	fetches := 0
+	h.head = func(ctx context.Context) (*tzkt.Head, error) {
+		fetches++
+		return &tzkt.Head{Level: 1010, Timestamp: headTimestamp}, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		w := serveHealth(h, "/status")
+		if w.Code != http.StatusOK {
+			t.Fatalf("/status = %d: %s", w.Code, w.Body.String())
+		}
+
+		var status StatusResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+			t.Fatal(err)
+		}
+		if status.Latest == nil || status.Latest.ID != 100 || status.Indexer == nil || status.Indexer.Cursor != 100 {
+			t.Errorf("status = %+v, want latest id and cursor 100", status)
+		}
+		if status.LagLevels == nil || *status.LagLevels != 2 || status.LagSeconds == nil || *status.LagSeconds != 16 {
+			t.Errorf("lag = %v levels, %v seconds, want 2 and 16 from the synced head", status.LagLevels, status.LagSeconds)
+		}
+		if status.Stale {
+			t.Error("status reported stale after a fresh poll and sync")
+		}
+	}
+
+	// The head is cached between requests
+	if fetches != 1 {
+		t.Errorf("head fetched %d times, want 1", fetches)
+	}
+}
+
+func TestStatus_LagUnknownBeforeSync(t *testing.T) {
+	h := newTestHealth(5*time.Minute, &db.IndexerState{Cursor: 100, LastPollAt: time.Now()})
+	h.head = func(ctx context.Context) (*tzkt.Head, error) {
+		return &tzkt.Head{Level: 1010, Timestamp: time.Date(2025, 1, 1, 0, 1, 0, 0, time.UTC)}, nil
+	}
+
+	var status StatusResponse
+	if err := json.Unmarshal(serveHealth(h, "/status").Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.LagLevels != nil || status.LagSeconds != nil {
+		t.Errorf("lag = %v levels, %v seconds, want none before the indexer caught up with a head", status.LagLevels, status.LagSeconds)
+	}
+}
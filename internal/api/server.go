@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/logging"
+	"github.com/broyeztony/delegated/internal/metrics"
+	"github.com/broyeztony/delegated/internal/stream"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewRouter creates the gin engine serving all API routes.
+// The /admin routes require adminToken as bearer token, and are not served when it is empty.
+func NewRouter(db *pgxpool.Pool, health *Health, hub *stream.Hub, adminToken string) *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.Use(otelgin.Middleware("delegated-api"))
+	r.Use(requestLogger())
+	r.Use(gin.CustomRecoveryWithWriter(io.Discard, recoverPanic))
+	r.Use(metricsMiddleware())
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	r.GET("/healthz", health.Liveness)
+	r.GET("/readyz", health.Readiness)
+	r.GET("/status", health.Status)
+	r.GET("/xtz/delegations", GetDelegations(db))
+	r.GET("/xtz/delegations/stream", StreamDelegations(db, hub))
+	r.GET("/xtz/delegations/stats", GetDelegationStats(db))
+	r.GET("/xtz/delegators/:address", GetCurrentDelegation(db))
+	r.GET("/xtz/delegators/:address/at", GetDelegationAt(db))
+	r.GET("/xtz/bakers", ListBakers(db))
+	r.GET("/xtz/bakers/:address/delegators", ListBakerDelegators(db))
+	r.GET("/xtz/bakers/:address/history", GetBakerHistory(db))
+	r.GET("/xtz/staking", ListStakingOps(db))
+
+	if adminToken != "" {
+		admin := r.Group("/admin", adminAuth(adminToken))
+		admin.POST("/webhooks", CreateWebhook(db))
+		admin.GET("/webhooks", ListWebhooks(db))
+		admin.DELETE("/webhooks/:id", DeleteWebhook(db))
+		admin.GET("/webhooks/:id/dead-letters", ListDeadLetters(db))
+		admin.POST("/webhooks/:id/dead-letters/:dead_letter_id/retry", RetryDeadLetter(db))
+	}
+	return r
+}
+
+// requestLogger assigns a request_id (from the X-Request-ID header or a random one),
+// attaches it to the request context for handler logs, and logs one line per request
+func requestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader("X-Request-ID")
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		c.Header("X-Request-ID", requestID)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.HasTraceID() {
+			attrs = append(attrs, slog.String("trace_id", sc.TraceID().String()))
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(c.Request.Context(), level, "Request", attrs...)
+	}
+}
+
+// recoverPanic logs a handler panic and answers 500
+func recoverPanic(c *gin.Context, err any) {
+	slog.ErrorContext(c.Request.Context(), "Handler panic", "error", err)
+	c.AbortWithStatus(http.StatusInternalServerError)
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// metricsMiddleware records request counts and latencies per route template
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.APIRequests.WithLabelValues(route, c.Request.Method, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.APIRequestDuration.WithLabelValues(route, c.Request.Method).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Serve runs an HTTP server on addr until ctx is cancelled,
+// then shuts it down gracefully, waiting up to shutdownTimeout for in-flight requests
+func Serve(ctx context.Context, addr string, handler http.Handler, shutdownTimeout time.Duration) error {
+	server := &http.Server{
+		Addr:    addr,
+		Handler: handler,
+	}
+
+	// Start server in a goroutine
+	slog.Info("Server starting", "addr", addr)
+	errChan := make(chan error, 1)
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errChan <- err
+		}
+		close(errChan)
+	}()
+
+	// Wait for shutdown or server failure
+	select {
+	case err := <-errChan:
+		return fmt.Errorf("server error: %w", err)
+	case <-ctx.Done():
+	}
+	slog.Info("Shutdown signal received, gracefully shutting down", "addr", addr, "timeout", shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("server shutdown error: %w", err)
+	}
+
+	slog.Info("Server stopped", "addr", addr)
+	return nil
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/broyeztony/delegated/internal/logging"
+	"github.com/broyeztony/delegated/internal/metrics"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(metricsMiddleware())
+	r.GET("/things/:id", func(c *gin.Context) { c.Status(http.StatusTeapot) })
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	for i := 0; i < 2; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/things/42", nil))
+	}
+
+	// Requests are labelled by route template, not by raw path
+	if got := testutil.ToFloat64(metrics.APIRequests.WithLabelValues("/things/:id", "GET", "418")); got != 2 {
+		t.Errorf("requests_total = %v, want 2", got)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(w.Body.String(), `delegated_api_request_duration_seconds_count{method="GET",route="/things/:id"} 2`) {
+		t.Errorf("/metrics does not expose the request duration histogram")
+	}
+}
+
+func TestRequestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.FormatJSON, "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(requestLogger())
+	r.GET("/things/:id", func(c *gin.Context) {
+		slog.InfoContext(c.Request.Context(), "handler log")
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/things/42", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if got := w.Header().Get("X-Request-ID"); got != "req-1" {
+		t.Errorf("X-Request-ID = %q, want req-1", got)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2: %s", len(lines), buf.String())
+	}
+	for _, line := range lines {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("log line is not JSON: %v", err)
+		}
+		if record["request_id"] != "req-1" {
+			t.Errorf("record %v has no request_id", record)
+		}
+	}
+
+	var access map[string]any
+	json.Unmarshal([]byte(lines[1]), &access)
+	if access["route"] != "/things/:id" || access["status"] != float64(200) {
+		t.Errorf("access log = %v, want route and status fields", access)
+	}
+}
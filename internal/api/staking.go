@@ -0,0 +1,92 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// StakingResponse is a staking operation. The id pages through older operations with before.
+type StakingResponse struct {
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Staker    string `json:"staker"`
+	Baker     string `json:"baker"`
+	Action    string `json:"action"`
+	Amount    string `json:"amount"`
+	Level     string `json:"level"`
+}
+
+// parseStakingQuery validates the staker, baker, action, before and limit parameters
+func parseStakingQuery(c *gin.Context) (db.StakingQuery, error) {
+	q := db.StakingQuery{
+		Staker: c.Query("staker"),
+		Baker:  c.Query("baker"),
+		Action: c.Query("action"),
+	}
+	if q.Staker != "" && !models.IsAddress(q.Staker) {
+		return q, fmt.Errorf("staker must be a Tezos address")
+	}
+	if q.Baker != "" && !models.IsAddress(q.Baker) {
+		return q, fmt.Errorf("baker must be a Tezos address")
+	}
+	if q.Action != "" && !models.IsStakingAction(q.Action) {
+		return q, fmt.Errorf("action must be one of %s, %s or %s",
+			models.StakingActionStake, models.StakingActionUnstake, models.StakingActionFinalize)
+	}
+	if value := c.Query("before"); value != "" {
+		before, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || before < 1 {
+			return q, fmt.Errorf("before must be a positive operation id")
+		}
+		q.BeforeID = before
+	}
+
+	limit, err := parseLimit(c)
+	if err != nil {
+		return q, err
+	}
+	q.Limit = limit
+	return q, nil
+}
+
+// ListStakingOps returns the latest staking operations, optionally filtered by staker, baker
+// and action. Pass the id of the last operation as before to get the next page.
+func ListStakingOps(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q, err := parseStakingQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ops, err := db.ListStakingOps(c.Request.Context(), pool, q)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to list staking operations", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		responseData := make([]StakingResponse, 0, len(ops))
+		for _, o := range ops {
+			responseData = append(responseData, StakingResponse{
+				ID:        strconv.FormatInt(o.ID, 10),
+				Timestamp: o.Timestamp.Format(time.RFC3339),
+				Staker:    o.Staker,
+				Baker:     o.Baker,
+				Action:    o.Action,
+				Amount:    strconv.FormatInt(o.Amount, 10),
+				Level:     strconv.FormatInt(int64(o.Level), 10),
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"data": responseData})
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestListStakingOps_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "invalid staker", path: "/xtz/staking?staker=alice"},
+		{name: "invalid baker", path: "/xtz/staking?baker=everstake"},
+		{name: "invalid action", path: "/xtz/staking?action=delegate"},
+		{name: "non numeric before", path: "/xtz/staking?before=latest"},
+		{name: "zero before", path: "/xtz/staking?before=0"},
+		{name: "limit too large", path: "/xtz/staking?limit=1001"},
+		{name: "valid staker, invalid limit", path: "/xtz/staking?staker=" + testDelegator + "&limit=0"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/xtz/staking", ListStakingOps(nil))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// StatsResponse aggregates the delegations of one bucket. Amounts are mutez strings, like in
+// DelegationResponse. Baker is only set when grouping by baker, and empty for undelegations.
+type StatsResponse struct {
+	Bucket        string  `json:"bucket"`
+	Baker         *string `json:"baker,omitempty"`
+	Count         int64   `json:"count"`
+	Delegators    int64   `json:"delegators"`
+	TotalAmount   string  `json:"total_amount"`
+	AverageAmount string  `json:"average_amount"`
+	MedianAmount  string  `json:"median_amount"`
+	MaxAmount     string  `json:"max_amount"`
+}
+
+// parseStatsQuery validates the interval, from, to and group_by parameters
+func parseStatsQuery(c *gin.Context) (db.StatsQuery, error) {
+	var q db.StatsQuery
+	var err error
+	if q.Interval, q.From, q.To, err = parseTimeSeries(c); err != nil {
+		return q, err
+	}
+
+	switch groupBy := c.Query("group_by"); groupBy {
+	case "":
+	case "baker":
+		q.GroupByBaker = true
+	default:
+		return q, fmt.Errorf("invalid group_by %q, expected baker", groupBy)
+	}
+
+	return q, nil
+}
+
+// parseTimeSeries validates the interval (required), from and to (optional dates) parameters of time series
+func parseTimeSeries(c *gin.Context) (interval string, from, to time.Time, err error) {
+	interval = c.Query("interval")
+	switch interval {
+	case db.IntervalDay, db.IntervalWeek, db.IntervalMonth, db.IntervalYear:
+	case "":
+		return "", from, to, fmt.Errorf("interval is required: day, week, month or year")
+	default:
+		return "", from, to, fmt.Errorf("invalid interval %q, expected day, week, month or year", interval)
+	}
+
+	if from, err = parseDateParam(c.Query("from")); err != nil {
+		return "", from, to, fmt.Errorf("invalid from: %w", err)
+	}
+	if to, err = parseDateParam(c.Query("to")); err != nil {
+		return "", from, to, fmt.Errorf("invalid to: %w", err)
+	}
+	if !from.IsZero() && !to.IsZero() && !to.After(from) {
+		return "", from, to, fmt.Errorf("to must be after from")
+	}
+
+	return interval, from, to, nil
+}
+
+// parseDateParam parses a date (YYYY-MM-DD). Empty values give the zero time.
+func parseDateParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected YYYY-MM-DD")
+	}
+	return t, nil
+}
+
+// GetDelegationStats aggregates the delegations of the days from <= day < to by day, week
+// (starting on Monday), month or year, optionally per baker. It reads the daily rollups.
+func GetDelegationStats(pool *pgxpool.Pool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		q, err := parseStatsQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		buckets, err := db.GetDelegationStats(c.Request.Context(), pool, q)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to aggregate delegations", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		responseData := make([]StatsResponse, 0, len(buckets))
+		for _, b := range buckets {
+			s := StatsResponse{
+				Bucket:        b.Start.Format(time.RFC3339),
+				Count:         b.Count,
+				Delegators:    b.Delegators,
+				TotalAmount:   strconv.FormatInt(b.TotalAmount, 10),
+				AverageAmount: strconv.FormatInt(b.AverageAmount, 10),
+				MedianAmount:  strconv.FormatInt(b.MedianAmount, 10),
+				MaxAmount:     strconv.FormatInt(b.MaxAmount, 10),
+			}
+			if q.GroupByBaker {
+				s.Baker = &b.Baker
+			}
+			responseData = append(responseData, s)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"interval": q.Interval,
+			"data":     responseData,
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseStatsQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    db.StatsQuery
+		wantErr bool
+	}{
+		{name: "interval only", query: "interval=week", want: db.StatsQuery{Interval: db.IntervalWeek}},
+		{
+			name:  "dates and group by baker",
+			query: "interval=day&from=2024-03-01&to=2024-04-01&group_by=baker",
+			want: db.StatsQuery{
+				Interval:     db.IntervalDay,
+				From:         time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+				To:           time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+				GroupByBaker: true,
+			},
+		},
+		{name: "time instead of a date", query: "interval=month&from=2024-03-01T02:00:00Z", wantErr: true},
+		{name: "missing interval", query: "from=2024-03-01", wantErr: true},
+		{name: "unknown interval", query: "interval=hour", wantErr: true},
+		{name: "invalid from", query: "interval=day&from=03/01/2024", wantErr: true},
+		{name: "to before from", query: "interval=day&from=2024-04-01&to=2024-03-01", wantErr: true},
+		{name: "unknown group by", query: "interval=day&group_by=delegator", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/xtz/delegations/stats?"+tt.query, nil)
+
+			got, err := parseStatsQuery(c)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseStatsQuery() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Interval != tt.want.Interval || !got.From.Equal(tt.want.From) || !got.To.Equal(tt.want.To) || got.GroupByBaker != tt.want.GroupByBaker {
+				t.Errorf("parseStatsQuery() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
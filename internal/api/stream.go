@@ -0,0 +1,161 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/broyeztony/delegated/internal/stream"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// heartbeatInterval keeps idle streams open through proxies
+	heartbeatInterval = 15 * time.Second
+	// replayPageSize is the number of rows read per query when resuming from Last-Event-ID
+	replayPageSize = 1000
+)
+
+// parseStreamFilter validates the delegator and min_amount query parameters
+func parseStreamFilter(c *gin.Context) (stream.Filter, error) {
+	var filter stream.Filter
+
+	if delegator := c.Query("delegator"); delegator != "" {
+		if !models.IsAddress(delegator) {
+			return filter, fmt.Errorf("delegator must be a tz1, tz2, tz3, tz4 or KT1 address")
+		}
+		filter.Delegator = delegator
+	}
+
+	if minAmount := c.Query("min_amount"); minAmount != "" {
+		amount, err := strconv.ParseInt(minAmount, 10, 64)
+		if err != nil || amount < 0 {
+			return filter, fmt.Errorf("min_amount must be a non-negative integer (mutez)")
+		}
+		filter.MinAmount = amount
+	}
+
+	return filter, nil
+}
+
+// lastEventID reads the resume position from the Last-Event-ID header, sent by EventSource on reconnect,
+// or the last_event_id query parameter for the first connection
+func lastEventID(c *gin.Context) (int64, bool, error) {
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("last_event_id")
+	}
+	if value == "" {
+		return 0, false, nil
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, false, fmt.Errorf("Last-Event-ID must be a delegation id")
+	}
+	return id, true, nil
+}
+
+// StreamDelegations sends newly indexed delegations as Server-Sent Events, one event per delegation
+// with its id as event id. A client resuming with Last-Event-ID first receives the delegations
+// indexed since that id, then the live feed.
+func StreamDelegations(pool *pgxpool.Pool, hub *stream.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		filter, err := parseStreamFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		lastID, resume, err := lastEventID(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Subscribe before replaying so that nothing inserted meanwhile is missed
+		updates, unsubscribe := hub.Subscribe()
+		defer unsubscribe()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+
+		// send writes the delegations newer than lastID that match the filter
+		send := func(delegations []models.Delegation) error {
+			for _, d := range delegations {
+				if d.ID <= lastID {
+					continue
+				}
+				lastID = d.ID
+				if !filter.Match(d) {
+					continue
+				}
+				if err := writeEvent(c, d); err != nil {
+					return err
+				}
+			}
+			c.Writer.Flush()
+			return nil
+		}
+
+		if resume {
+			for {
+				delegations, err := db.GetDelegationsAfter(ctx, pool, lastID, replayPageSize)
+				if err != nil {
+					slog.ErrorContext(ctx, "Failed to replay delegations", "cursor", lastID, "error", err)
+					return
+				}
+				if err := send(delegations); err != nil {
+					return
+				}
+				if len(delegations) < replayPageSize {
+					break
+				}
+			}
+		}
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case delegations, ok := <-updates:
+				if !ok {
+					// Dropped for lagging behind, or shutting down: the client reconnects with Last-Event-ID
+					return
+				}
+				if err := send(delegations); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(c.Writer, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				c.Writer.Flush()
+			}
+		}
+	}
+}
+
+// writeEvent writes a delegation as an SSE event
+func writeEvent(c *gin.Context, d models.Delegation) error {
+	data, err := json.Marshal(toResponse(d))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: delegation\ndata: %s\n\n", d.ID, data)
+	return err
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/broyeztony/delegated/internal/stream"
+	"github.com/gin-gonic/gin"
+)
+
+const testDelegator = "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo"
+
+func TestStreamDelegations_Validation(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		id    string
+	}{
+		{name: "invalid delegator", query: "?delegator=alice"},
+		{name: "negative min_amount", query: "?min_amount=-1"},
+		{name: "non numeric min_amount", query: "?min_amount=lots"},
+		{name: "invalid Last-Event-ID", id: "abc"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/stream", StreamDelegations(nil, stream.NewHub()))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/stream"+tt.query, nil)
+			if tt.id != "" {
+				req.Header.Set("Last-Event-ID", tt.id)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", w.Code)
+			}
+		})
+	}
+}
+
+func TestStreamDelegations_Live(t *testing.T) {
+	hub := stream.NewHub()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/stream", StreamDelegations(nil, hub))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/stream?delegator="+testDelegator+"&min_amount=100", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	// Wait for the handler to subscribe
+	for hub.Subscribers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ts := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	hub.Publish([]models.Delegation{
+		{ID: 10, Delegator: testDelegator, Amount: 50, Level: 1, Timestamp: ts},
+		{ID: 11, Delegator: "tz1QMwsi5onCV9yR2VJsSCRBDsrSfzrViMss", Amount: 500, Level: 1, Timestamp: ts},
+		{ID: 12, Delegator: testDelegator, Amount: 500, Level: 2, Timestamp: ts},
+	})
+	hub.Close()
+
+	var events []string
+	scanner := bufio.NewScanner(resp.Body)
+	var event []string
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			event = append(event, line)
+			continue
+		}
+		events = append(events, strings.Join(event, "\n"))
+		event = nil
+	}
+
+	want := `id: 12
+event: delegation
+data: {"timestamp":"2025-01-01T00:00:00Z","amount":"500","delegator":"` + testDelegator + `","level":"2"}`
+	if len(events) != 1 || events[0] != want {
+		t.Errorf("events = %q, want only\n%s", events, want)
+	}
+}
@@ -0,0 +1,320 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/logging"
+	"github.com/broyeztony/delegated/internal/sink"
+	"github.com/broyeztony/delegated/internal/telemetry"
+	"github.com/spf13/viper"
+)
+
+// maxPageSize is the largest limit accepted by the TzKT API
+const maxPageSize = 10000
+
+// Config is the effective configuration of every command, loaded by Load from
+// (in order of precedence) flags, environment variables, the config file and defaults
+type Config struct {
+	// DatabaseURL is required by the commands that open a connection pool
+	DatabaseURL string `mapstructure:"db-url"`
+	// TzKTURL is the TzKT delegations endpoint; the chain head is read from the sibling /v1/head
+	TzKTURL         string        `mapstructure:"tzkt-url"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown-timeout"`
+	// MetricsAddr serves Prometheus metrics for index and backfill (disabled when empty)
+	MetricsAddr string `mapstructure:"metrics-addr"`
+
+	Log      Log      `mapstructure:"log"`
+	Trace    Trace    `mapstructure:"trace"`
+	Indexer  Indexer  `mapstructure:"indexer"`
+	API      API      `mapstructure:"api"`
+	Webhooks Webhooks `mapstructure:"webhooks"`
+	// Sinks receive the delegations committed by the live indexer (config file only)
+	Sinks []Sink `mapstructure:"sinks"`
+}
+
+// Log configures the slog logger
+type Log struct {
+	Level  string `mapstructure:"level"`
+	Format string `mapstructure:"format"`
+}
+
+// Trace configures OpenTelemetry tracing
+type Trace struct {
+	Exporter string `mapstructure:"exporter"`
+}
+
+// Indexer configures the live indexer and the backfill
+type Indexer struct {
+	Interval         time.Duration `mapstructure:"interval"`
+	PollPageSize     int           `mapstructure:"poll-page-size"`
+	BackfillPageSize int           `mapstructure:"backfill-page-size"`
+	// BackfillSleep is the pause between backfill batches, to stay under the TzKT rate limit
+	BackfillSleep  time.Duration `mapstructure:"backfill-sleep"`
+	LeaderElection bool          `mapstructure:"leader-election"`
+	// InstanceID identifies this replica in the indexer lease (hostname-pid when empty)
+	InstanceID string        `mapstructure:"instance-id"`
+	LeaseTTL   time.Duration `mapstructure:"lease-ttl"`
+	// Staking also indexes staking operations (stake, unstake, finalize) under the same lease
+	Staking bool `mapstructure:"staking"`
+}
+
+// API configures the HTTP server
+type API struct {
+	Addr string `mapstructure:"addr"`
+	// MaxStaleness is how long after the last successful indexer poll, or the timestamp of the last
+	// head the indexer caught up with, /readyz starts failing (0 disables the check)
+	MaxStaleness time.Duration `mapstructure:"max-staleness"`
+	// StreamCheckInterval is how long serve waits for a notification from the indexer before
+	// checking the max id, in case notifications were missed
+	StreamCheckInterval time.Duration `mapstructure:"stream-check-interval"`
+}
+
+// Webhooks configures the webhook admin API and the delivery of webhooks
+type Webhooks struct {
+	// AdminToken is the bearer token of the /admin API (the admin API is disabled when empty)
+	AdminToken string `mapstructure:"admin-token"`
+	// MaxAttempts is the number of failed deliveries after which a delivery is dead-lettered
+	MaxAttempts int `mapstructure:"max-attempts"`
+	// MinBackoff is the delay before the first retry, doubled on each failure up to MaxBackoff
+	MinBackoff   time.Duration `mapstructure:"min-backoff"`
+	MaxBackoff   time.Duration `mapstructure:"max-backoff"`
+	Timeout      time.Duration `mapstructure:"timeout"`
+	PollInterval time.Duration `mapstructure:"poll-interval"`
+	// BatchSize is the number of deliveries claimed and sent concurrently
+	BatchSize int `mapstructure:"batch-size"`
+}
+
+// Sink configures a destination of the delegations committed by the live indexer
+type Sink struct {
+	// Name identifies the sink and its cursor: renaming a sink makes it start over from the latest delegation
+	Name string `mapstructure:"name"`
+	Type string `mapstructure:"type"`
+	// Path is the NDJSON file appended to, "-" for stdout
+	Path string `mapstructure:"path"`
+	// URL of the NATS server or Redis
+	URL string `mapstructure:"url"`
+	// Topic is the NATS subject or the Redis stream key
+	Topic string `mapstructure:"topic"`
+	// MaxLen approximately caps the length of the Redis stream (0 keeps everything)
+	MaxLen int64 `mapstructure:"max-len"`
+}
+
+// Default returns the configuration used when nothing is set
+func Default() Config {
+	return Config{
+		TzKTURL:         "https://api.tzkt.io/v1/operations/delegations",
+		ShutdownTimeout: 30 * time.Second,
+		Log: Log{
+			Level:  "info",
+			Format: logging.FormatText,
+		},
+		Trace: Trace{
+			Exporter: telemetry.ExporterNone,
+		},
+		Indexer: Indexer{
+			Interval:         60 * time.Second,
+			PollPageSize:     100,
+			BackfillPageSize: maxPageSize,
+			BackfillSleep:    100 * time.Millisecond,
+			LeaderElection:   true,
+			LeaseTTL:         6 * time.Second,
+		},
+		API: API{
+			Addr:                ":8080",
+			StreamCheckInterval: 30 * time.Second,
+		},
+		Webhooks: Webhooks{
+			MaxAttempts:  8,
+			MinBackoff:   5 * time.Second,
+			MaxBackoff:   time.Hour,
+			Timeout:      10 * time.Second,
+			PollInterval: time.Second,
+			BatchSize:    20,
+		},
+	}
+}
+
+// SetDefaults registers the defaults, so that every key is known to v
+func SetDefaults(v *viper.Viper) {
+	d := Default()
+	v.SetDefault("db-url", d.DatabaseURL)
+	v.SetDefault("tzkt-url", d.TzKTURL)
+	v.SetDefault("shutdown-timeout", d.ShutdownTimeout)
+	v.SetDefault("metrics-addr", d.MetricsAddr)
+	v.SetDefault("log.level", d.Log.Level)
+	v.SetDefault("log.format", d.Log.Format)
+	v.SetDefault("trace.exporter", d.Trace.Exporter)
+	v.SetDefault("indexer.interval", d.Indexer.Interval)
+	v.SetDefault("indexer.poll-page-size", d.Indexer.PollPageSize)
+	v.SetDefault("indexer.backfill-page-size", d.Indexer.BackfillPageSize)
+	v.SetDefault("indexer.backfill-sleep", d.Indexer.BackfillSleep)
+	v.SetDefault("indexer.leader-election", d.Indexer.LeaderElection)
+	v.SetDefault("indexer.instance-id", d.Indexer.InstanceID)
+	v.SetDefault("indexer.lease-ttl", d.Indexer.LeaseTTL)
+	v.SetDefault("indexer.staking", d.Indexer.Staking)
+	v.SetDefault("api.addr", d.API.Addr)
+	v.SetDefault("api.max-staleness", d.API.MaxStaleness)
+	v.SetDefault("api.stream-check-interval", d.API.StreamCheckInterval)
+	v.SetDefault("webhooks.admin-token", d.Webhooks.AdminToken)
+	v.SetDefault("webhooks.max-attempts", d.Webhooks.MaxAttempts)
+	v.SetDefault("webhooks.min-backoff", d.Webhooks.MinBackoff)
+	v.SetDefault("webhooks.max-backoff", d.Webhooks.MaxBackoff)
+	v.SetDefault("webhooks.timeout", d.Webhooks.Timeout)
+	v.SetDefault("webhooks.poll-interval", d.Webhooks.PollInterval)
+	v.SetDefault("webhooks.batch-size", d.Webhooks.BatchSize)
+}
+
+// Load decodes and validates the configuration held by v
+func Load(v *viper.Viper) (*Config, error) {
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+
+	if u, err := url.Parse(c.TzKTURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("tzkt-url %q must be an http(s) URL", c.TzKTURL))
+	}
+	if c.ShutdownTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdown-timeout must be positive"))
+	}
+	if _, err := logging.New(io.Discard, c.Log.Format, c.Log.Level); err != nil {
+		errs = append(errs, err)
+	}
+	switch c.Trace.Exporter {
+	case telemetry.ExporterNone, telemetry.ExporterStdout, telemetry.ExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("trace.exporter %q must be %s, %s or %s",
+			c.Trace.Exporter, telemetry.ExporterNone, telemetry.ExporterStdout, telemetry.ExporterOTLP))
+	}
+
+	if c.Indexer.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("indexer.interval must be positive"))
+	}
+	if c.Indexer.PollPageSize < 1 || c.Indexer.PollPageSize > maxPageSize {
+		errs = append(errs, fmt.Errorf("indexer.poll-page-size must be between 1 and %d", maxPageSize))
+	}
+	if c.Indexer.BackfillPageSize < 1 || c.Indexer.BackfillPageSize > maxPageSize {
+		errs = append(errs, fmt.Errorf("indexer.backfill-page-size must be between 1 and %d", maxPageSize))
+	}
+	if c.Indexer.BackfillSleep < 0 {
+		errs = append(errs, fmt.Errorf("indexer.backfill-sleep must not be negative"))
+	}
+	if c.Indexer.LeaseTTL < time.Second {
+		errs = append(errs, fmt.Errorf("indexer.lease-ttl must be at least 1s"))
+	}
+
+	if c.API.Addr == "" {
+		errs = append(errs, fmt.Errorf("api.addr must not be empty"))
+	}
+	if c.API.MaxStaleness < 0 {
+		errs = append(errs, fmt.Errorf("api.max-staleness must not be negative"))
+	}
+	if c.API.StreamCheckInterval <= 0 {
+		errs = append(errs, fmt.Errorf("api.stream-check-interval must be positive"))
+	}
+
+	if c.Webhooks.MaxAttempts < 1 {
+		errs = append(errs, fmt.Errorf("webhooks.max-attempts must be at least 1"))
+	}
+	if c.Webhooks.MinBackoff <= 0 || c.Webhooks.MaxBackoff < c.Webhooks.MinBackoff {
+		errs = append(errs, fmt.Errorf("webhooks.min-backoff must be positive and at most webhooks.max-backoff"))
+	}
+	if c.Webhooks.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("webhooks.timeout must be positive"))
+	}
+	if c.Webhooks.PollInterval <= 0 {
+		errs = append(errs, fmt.Errorf("webhooks.poll-interval must be positive"))
+	}
+	if c.Webhooks.BatchSize < 1 {
+		errs = append(errs, fmt.Errorf("webhooks.batch-size must be at least 1"))
+	}
+
+	names := make(map[string]bool, len(c.Sinks))
+	for i, s := range c.Sinks {
+		if s.Name == "" || names[s.Name] {
+			errs = append(errs, fmt.Errorf("sinks[%d].name must be set and unique", i))
+		}
+		names[s.Name] = true
+
+		switch s.Type {
+		case sink.TypeNDJSON:
+			if s.Path == "" {
+				errs = append(errs, fmt.Errorf("sinks[%d].path must be set (- for stdout)", i))
+			}
+		case sink.TypeNATS, sink.TypeRedis:
+			if s.URL == "" || s.Topic == "" {
+				errs = append(errs, fmt.Errorf("sinks[%d].url and sinks[%d].topic must be set", i, i))
+			}
+			if s.MaxLen < 0 {
+				errs = append(errs, fmt.Errorf("sinks[%d].max-len must not be negative", i))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("sinks[%d].type %q must be %s, %s or %s", i, s.Type, sink.TypeNDJSON, sink.TypeNATS, sink.TypeRedis))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Settings returns the configuration as nested maps keyed like the config file, with durations
+// formatted as strings and passwords and the admin token redacted, for display
+func (c Config) Settings() map[string]any {
+	settings := settingsOf(reflect.ValueOf(c))
+	if c.DatabaseURL != "" {
+		settings["db-url"] = redactURL(c.DatabaseURL)
+	}
+	for i, s := range c.Sinks {
+		settings["sinks"].([]any)[i].(map[string]any)["url"] = redactURL(s.URL)
+	}
+	if c.Webhooks.AdminToken != "" {
+		settings["webhooks"].(map[string]any)["admin-token"] = "xxxxx"
+	}
+	return settings
+}
+
+func settingsOf(v reflect.Value) map[string]any {
+	settings := make(map[string]any, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		key := v.Type().Field(i).Tag.Get("mapstructure")
+		switch field := v.Field(i); {
+		case field.Kind() == reflect.Struct:
+			settings[key] = settingsOf(field)
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct:
+			items := make([]any, field.Len())
+			for j := range items {
+				items[j] = settingsOf(field.Index(j))
+			}
+			settings[key] = items
+		case field.Type() == reflect.TypeOf(time.Duration(0)):
+			settings[key] = time.Duration(field.Int()).String()
+		default:
+			settings[key] = field.Interface()
+		}
+	}
+	return settings
+}
+
+// redactURL hides the password of a connection URL
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	return u.Redacted()
+}
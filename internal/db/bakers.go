@@ -0,0 +1,173 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Baker is a baker with its current delegators
+type Baker struct {
+	Address    string
+	Delegators int64
+	// DelegatedAmount is the sum of the delegators' balances at their latest delegation, in mutez
+	DelegatedAmount int64
+}
+
+// Delegator is a delegator of a baker, as of its latest delegation
+type Delegator struct {
+	Address   string
+	Amount    int64
+	Since     time.Time
+	Level     int32
+	PrevBaker string
+}
+
+// BakerHistoryPoint counts the delegators joining and leaving a baker in one interval.
+// Amounts are the delegators' balances at the time of the delegation, in mutez.
+type BakerHistoryPoint struct {
+	Start        time.Time
+	Joins        int64
+	Leaves       int64
+	JoinedAmount int64
+	// LeftAmount is the amount the leaving delegators were counted with, at their previous delegation
+	LeftAmount int64
+	// NetAmount is the joined minus the left amount, plus the changes of the amounts of delegators
+	// redelegating to the baker
+	NetAmount int64
+	// CumulativeNetAmount is the sum of the net amounts since the first delegation
+	CumulativeNetAmount int64
+}
+
+// ListBakers returns up to limit bakers by current delegators, then delegated amount. A delegator
+// is current when its latest delegation is to the baker.
+func ListBakers(ctx context.Context, pool *pgxpool.Pool, limit int) ([]Baker, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT baker, COUNT(*), SUM(amount)::BIGINT
+		FROM current_delegations
+		WHERE baker <> ''
+		GROUP BY baker
+		ORDER BY 2 DESC, 3 DESC, 1
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Baker, error) {
+		var b Baker
+		err := row.Scan(&b.Address, &b.Delegators, &b.DelegatedAmount)
+		return b, err
+	})
+}
+
+// ListBakerDelegators returns up to limit current delegators of baker, largest amount first
+func ListBakerDelegators(ctx context.Context, pool *pgxpool.Pool, baker string, limit int) ([]Delegator, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT c.delegator, c.amount, c.timestamp, c.level, d.prev_baker
+		FROM current_delegations c
+		JOIN delegations d ON d.id = c.delegation_id
+		WHERE c.baker = $1
+		ORDER BY c.amount DESC, c.delegator
+		LIMIT $2`, baker, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Delegator, error) {
+		var d Delegator
+		err := row.Scan(&d.Address, &d.Amount, &d.Since, &d.Level, &d.PrevBaker)
+		return d, err
+	})
+}
+
+// ListBakerDelegatorsAt returns up to limit delegators of baker at a point in history, largest
+// amount first: those whose latest delegation up to at was to baker. It reconstructs the state
+// from the delegations to baker up to at and the latest delegation of each of their delegators.
+func ListBakerDelegatorsAt(ctx context.Context, pool *pgxpool.Pool, baker string, at PointInTime, limit int) ([]Delegator, error) {
+	joined, arg := at.condition("j", 2)
+	latest, _ := at.condition("d", 2)
+
+	rows, err := pool.Query(ctx, `
+		SELECT l.delegator, l.amount, l.timestamp, l.level, l.prev_baker
+		FROM (SELECT DISTINCT delegator FROM delegations j WHERE j.baker = $1 AND `+joined+`) candidates
+		CROSS JOIN LATERAL (
+			SELECT delegator, amount, timestamp, level, baker, prev_baker FROM delegations d
+			WHERE d.delegator = candidates.delegator AND `+latest+`
+			ORDER BY d.id DESC
+			LIMIT 1
+		) l
+		WHERE l.baker = $1
+		ORDER BY l.amount DESC, l.delegator
+		LIMIT $3`, baker, arg, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Delegator, error) {
+		var d Delegator
+		err := row.Scan(&d.Address, &d.Amount, &d.Since, &d.Level, &d.PrevBaker)
+		return d, err
+	})
+}
+
+// GetBakerHistory counts the joins (delegations to baker) and leaves (delegations from baker to
+// another baker or undelegations) of baker by interval, for the intervals starting in
+// [from, to) that have any, or a redelegation to the baker. Zero from and to leave the range open.
+// A leave removes the amount the delegator was counted with, i.e. the amount of its previous
+// delegation, so that the cumulative net amount is the sum of the amounts of the baker's
+// delegators at their latest delegation. Redelegations to the same baker update that amount.
+func GetBakerHistory(ctx context.Context, pool *pgxpool.Pool, baker, interval string, from, to time.Time) ([]BakerHistoryPoint, error) {
+	switch interval {
+	case IntervalDay, IntervalWeek, IntervalMonth, IntervalYear:
+	default:
+		return nil, fmt.Errorf("unknown interval %q", interval)
+	}
+
+	// The cumulative amount covers the whole history, the range only selects the points returned
+	rows, err := pool.Query(ctx, `
+		WITH events AS (
+			SELECT timestamp, amount, baker, prev_baker,
+				LAG(baker) OVER w AS counted_baker,
+				LAG(amount) OVER w AS counted_amount
+			FROM delegations
+			WHERE delegator IN (SELECT delegator FROM delegations WHERE baker = $1)
+			WINDOW w AS (PARTITION BY delegator ORDER BY id)
+		), changes AS (
+			SELECT date_trunc($2, timestamp) AS start, amount,
+				baker = $1 AND prev_baker <> $1 AS joined,
+				prev_baker = $1 AND baker <> $1 AS left_baker,
+				CASE WHEN counted_baker = $1 THEN counted_amount ELSE 0 END AS removed_amount,
+				CASE WHEN baker = $1 THEN amount ELSE 0 END AS added_amount
+			FROM events
+			WHERE baker = $1 OR prev_baker = $1 OR counted_baker = $1
+		)
+		SELECT start, joins, leaves, joined_amount, left_amount, net_amount::BIGINT, cumulative_net_amount
+		FROM (
+			SELECT *, SUM(net_amount) OVER (ORDER BY start)::BIGINT AS cumulative_net_amount
+			FROM (
+				SELECT start,
+					COUNT(*) FILTER (WHERE joined) AS joins,
+					COUNT(*) FILTER (WHERE left_baker) AS leaves,
+					COALESCE(SUM(amount) FILTER (WHERE joined), 0)::BIGINT AS joined_amount,
+					COALESCE(SUM(removed_amount) FILTER (WHERE left_baker), 0)::BIGINT AS left_amount,
+					SUM(added_amount - removed_amount) AS net_amount
+				FROM changes
+				GROUP BY 1
+			) points
+		) history
+		WHERE ($3::TIMESTAMP IS NULL OR start >= $3) AND ($4::TIMESTAMP IS NULL OR start < $4)
+		ORDER BY start`,
+		baker, interval, nullTime(from), nullTime(to))
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (BakerHistoryPoint, error) {
+		var p BakerHistoryPoint
+		err := row.Scan(&p.Start, &p.Joins, &p.Leaves, &p.JoinedAmount, &p.LeftAmount, &p.NetAmount, &p.CumulativeNetAmount)
+		return p, err
+	})
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/dbtest"
+	"github.com/broyeztony/delegated/internal/models"
+)
+
+func TestIntegration_Bakers(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+
+	const (
+		alice  = "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo"
+		bob    = "tz1QMwsi5onCV9yR2VJsSCRBDsrSfzrViMss"
+		carol  = "tz1a1SAaXRt9yoGMx29rh9FsBF4UzmvojdTL"
+		baker1 = "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM"
+		baker2 = "tz3RDC3Jdn4j15J7bBHZd29EUee9gVB1CxD9"
+	)
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC) }
+
+	// alice and bob join baker1, alice moves to baker2, carol joins baker2 then undelegates
+	if err := CopyInsertDelegations(ctx, pool, []models.Delegation{
+		{ID: 1, Delegator: alice, Baker: baker1, Timestamp: day(1), Amount: 100, Level: 1},
+		{ID: 2, Delegator: bob, Baker: baker1, Timestamp: day(1), Amount: 50, Level: 2},
+		{ID: 3, Delegator: carol, Baker: baker2, Timestamp: day(2), Amount: 10, Level: 3},
+		{ID: 4, Delegator: alice, Baker: baker2, PrevBaker: baker1, Timestamp: day(3), Amount: 120, Level: 4},
+		{ID: 5, Delegator: carol, PrevBaker: baker2, Timestamp: day(4), Amount: 11, Level: 5},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	bakers, err := ListBakers(ctx, pool, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBakers := []Baker{{Address: baker2, Delegators: 1, DelegatedAmount: 120}, {Address: baker1, Delegators: 1, DelegatedAmount: 50}}
+	if len(bakers) != len(wantBakers) || bakers[0] != wantBakers[0] || bakers[1] != wantBakers[1] {
+		t.Errorf("ListBakers() = %+v, want %+v", bakers, wantBakers)
+	}
+
+	delegators, err := ListBakerDelegators(ctx, pool, baker2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(delegators) != 1 || delegators[0].Address != alice || delegators[0].PrevBaker != baker1 || !delegators[0].Since.Equal(day(3)) {
+		t.Errorf("ListBakerDelegators() = %+v, want alice since day 3", delegators)
+	}
+
+	// Before alice moved, at level 3 and at the time of delegation 3 (included)
+	for _, at := range []PointInTime{{Level: 3}, {Timestamp: day(2)}} {
+		past, err := ListBakerDelegatorsAt(ctx, pool, baker1, at, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(past) != 2 || past[0].Address != alice || past[1].Address != bob {
+			t.Errorf("ListBakerDelegatorsAt(%+v) = %+v, want alice and bob", at, past)
+		}
+
+		delegation, err := GetDelegationAt(ctx, pool, carol, at)
+		if err != nil || delegation == nil || delegation.Baker != baker2 || delegation.DelegationID != 3 {
+			t.Errorf("GetDelegationAt(carol, %+v) = %+v, %v, want delegation 3", at, delegation, err)
+		}
+	}
+	if delegation, err := GetDelegationAt(ctx, pool, carol, PointInTime{Level: 2}); err != nil || delegation != nil {
+		t.Errorf("GetDelegationAt(carol, level 2) = %+v, %v, want nil", delegation, err)
+	}
+	if delegation, err := GetDelegationAt(ctx, pool, carol, PointInTime{Level: 5}); err != nil || delegation == nil || delegation.Baker != "" {
+		t.Errorf("GetDelegationAt(carol, level 5) = %+v, %v, want an undelegation", delegation, err)
+	}
+
+	history, err := GetBakerHistory(ctx, pool, baker1, IntervalDay, day(2).Truncate(24*time.Hour), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Day 1 is out of the range but counts in the cumulative amount. Alice leaves with the 100 she
+	// joined with, though her balance grew to 120: bob's 50 remain
+	want := BakerHistoryPoint{Start: time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC), Leaves: 1, LeftAmount: 100, NetAmount: -100, CumulativeNetAmount: 50}
+	if len(history) == 1 && history[0].Start.Equal(want.Start) {
+		history[0].Start = want.Start
+	}
+	if len(history) != 1 || history[0] != want {
+		t.Errorf("GetBakerHistory() = %+v, want [%+v]", history, want)
+	}
+}
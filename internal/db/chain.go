@@ -0,0 +1,86 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AttributedDelegation is a delegation with the cycle and protocol it belongs to, nil until the
+// cycles and protocols covering its level are synced
+type AttributedDelegation struct {
+	models.Delegation
+	Cycle    *int32  `db:"cycle"`
+	Protocol *string `db:"protocol"`
+}
+
+// AttributedDelegationsSQL selects the delegations d with their cycle and protocol, scanned by
+// pgx.RowToStructByName[AttributedDelegation]. Conditions on the delegations follow.
+const AttributedDelegationsSQL = `
+	SELECT d.id, d.delegator, d.timestamp, d.amount, d.level, d.baker, d.prev_baker, c.cycle, p.hash AS protocol
+	FROM delegations d
+	LEFT JOIN LATERAL (
+		SELECT cycle FROM cycles WHERE first_level <= d.level AND last_level >= d.level ORDER BY first_level DESC LIMIT 1
+	) c ON TRUE
+	LEFT JOIN LATERAL (
+		SELECT hash FROM protocols WHERE first_level <= d.level ORDER BY first_level DESC LIMIT 1
+	) p ON TRUE`
+
+// CycleCondition restricts the delegations d of AttributedDelegationsSQL to the cycle given by the
+// parameter arg (e.g. "$1"). No delegation matches a cycle that is not synced.
+func CycleCondition(arg string) string {
+	return fmt.Sprintf("d.level BETWEEN (SELECT first_level FROM cycles WHERE cycle = %[1]s) AND (SELECT last_level FROM cycles WHERE cycle = %[1]s)", arg)
+}
+
+// ProtocolCondition restricts the delegations d of AttributedDelegationsSQL to the protocol whose
+// hash is given by the parameter arg. No delegation matches a protocol that is not synced.
+func ProtocolCondition(arg string) string {
+	return fmt.Sprintf("d.level BETWEEN (SELECT first_level FROM protocols WHERE hash = %[1]s) AND (SELECT COALESCE(last_level, 2147483647) FROM protocols WHERE hash = %[1]s)", arg)
+}
+
+// SyncChain upserts the protocols and cycles fetched from TzKT in one transaction. Cycles after the
+// last one fetched are deleted: upcoming cycles move when a protocol changes the cycle length.
+func SyncChain(ctx context.Context, pool *pgxpool.Pool, protocols []models.Protocol, cycles []models.Cycle) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for _, p := range protocols {
+		batch.Queue(`
+			INSERT INTO protocols (code, hash, alias, first_level, last_level, first_cycle, blocks_per_cycle)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (code) DO UPDATE SET hash = EXCLUDED.hash, alias = EXCLUDED.alias,
+				first_level = EXCLUDED.first_level, last_level = EXCLUDED.last_level,
+				first_cycle = EXCLUDED.first_cycle, blocks_per_cycle = EXCLUDED.blocks_per_cycle`,
+			p.Code, p.Hash, p.Alias, p.FirstLevel, p.LastLevel, p.FirstCycle, p.BlocksPerCycle)
+	}
+	for _, c := range cycles {
+		batch.Queue(`
+			INSERT INTO cycles (cycle, first_level, last_level, start_time, end_time)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (cycle) DO UPDATE SET first_level = EXCLUDED.first_level, last_level = EXCLUDED.last_level,
+				start_time = EXCLUDED.start_time, end_time = EXCLUDED.end_time`,
+			c.Index, c.FirstLevel, c.LastLevel, c.StartTime.UTC(), c.EndTime.UTC())
+	}
+
+	if len(cycles) > 0 {
+		batch.Queue("DELETE FROM cycles WHERE cycle > $1", cycles[len(cycles)-1].Index)
+	}
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to upsert protocols and cycles: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+// GetChainLevel returns the last level of the latest synced cycle, 0 when none is synced
+func GetChainLevel(ctx context.Context, pool *pgxpool.Pool) (level int32, err error) {
+	err = pool.QueryRow(ctx, "SELECT COALESCE(MAX(last_level), 0) FROM cycles").Scan(&level)
+	return
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/dbtest"
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestIntegration_ChainAttribution(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+
+	const (
+		delegator = "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo"
+		oxford    = "ProxfordYmVfjWnRcgjWH36fW6PArwqykTFzotUxRs6gmTcZDuH"
+		paris     = "PtParisBxoLz5gzMmn3d9WBQNoPSZakgnkMC2VNuQ3KXfUtUQeZ"
+	)
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	at := func(level int32) time.Time {
+		return start.Add(time.Duration(level) * 10 * time.Second)
+	}
+
+	// Cycles of 10 blocks under oxford, then of 20 blocks from level 21 under paris
+	oxfordLast := int32(20)
+	protocols := []models.Protocol{
+		{Code: 18, Hash: oxford, Alias: "Oxford 2", FirstLevel: 1, LastLevel: &oxfordLast, FirstCycle: 0, BlocksPerCycle: 10},
+		{Code: 19, Hash: paris, Alias: "Paris B", FirstLevel: 21, FirstCycle: 2, BlocksPerCycle: 20},
+	}
+	cycle := func(index, first, last int32) models.Cycle {
+		return models.Cycle{Index: index, FirstLevel: first, LastLevel: last, StartTime: at(first), EndTime: at(last)}
+	}
+	cycles := []models.Cycle{cycle(0, 1, 10), cycle(1, 11, 20), cycle(2, 21, 40), cycle(3, 41, 60), cycle(4, 61, 80)}
+	if err := SyncChain(ctx, pool, protocols, cycles); err != nil {
+		t.Fatalf("SyncChain() error = %v", err)
+	}
+	// A second sync updates rows and drops the cycles no longer served
+	if err := SyncChain(ctx, pool, protocols, cycles[:4]); err != nil {
+		t.Fatalf("SyncChain() error = %v", err)
+	}
+	if level, err := GetChainLevel(ctx, pool); err != nil || level != 60 {
+		t.Errorf("GetChainLevel() = %d, %v, want 60", level, err)
+	}
+
+	var delegations []models.Delegation
+	for i, level := range []int32{5, 15, 21, 40, 55, 70} {
+		delegations = append(delegations, models.Delegation{ID: int64(i + 1), Delegator: delegator, Timestamp: at(level), Amount: 1, Level: level})
+	}
+	if err := BulkInsertDelegations(ctx, pool, delegations); err != nil {
+		t.Fatal(err)
+	}
+
+	attributed := func(where string, args ...any) map[int32][2]any {
+		t.Helper()
+		query := AttributedDelegationsSQL
+		if where != "" {
+			query += " WHERE " + where
+		}
+		rows, err := pool.Query(ctx, query, args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all, err := pgx.CollectRows(rows, pgx.RowToStructByName[AttributedDelegation])
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[int32][2]any)
+		for _, d := range all {
+			var c, p any
+			if d.Cycle != nil {
+				c = *d.Cycle
+			}
+			if d.Protocol != nil {
+				p = *d.Protocol
+			}
+			got[d.Level] = [2]any{c, p}
+		}
+		return got
+	}
+
+	tests := []struct {
+		name  string
+		where string
+		args  []any
+		want  map[int32][2]any
+	}{
+		{
+			name: "all delegations",
+			want: map[int32][2]any{
+				5:  {int32(0), oxford},
+				15: {int32(1), oxford},
+				21: {int32(2), paris},
+				40: {int32(2), paris},
+				55: {int32(3), paris},
+				70: {nil, paris}, // past the synced cycles
+			},
+		},
+		{
+			name:  "cycle",
+			where: CycleCondition("$1"),
+			args:  []any{int32(2)},
+			want:  map[int32][2]any{21: {int32(2), paris}, 40: {int32(2), paris}},
+		},
+		{
+			name:  "unknown cycle",
+			where: CycleCondition("$1"),
+			args:  []any{int32(9)},
+			want:  map[int32][2]any{},
+		},
+		{
+			name:  "past protocol",
+			where: ProtocolCondition("$1"),
+			args:  []any{oxford},
+			want:  map[int32][2]any{5: {int32(0), oxford}, 15: {int32(1), oxford}},
+		},
+		{
+			name:  "current protocol",
+			where: ProtocolCondition("$1"),
+			args:  []any{paris},
+			want:  map[int32][2]any{21: {int32(2), paris}, 40: {int32(2), paris}, 55: {int32(3), paris}, 70: {nil, paris}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := attributed(tt.where, tt.args...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("attribution = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
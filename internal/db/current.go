@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CurrentDelegation is the latest delegation of a delegator
+type CurrentDelegation struct {
+	Delegator string
+	// Baker is empty after an undelegation
+	Baker        string
+	DelegationID int64
+	Level        int32
+	Timestamp    time.Time
+	Amount       int64
+}
+
+// latestDelegationsSQL selects the latest of the delegations matching a condition for each delegator
+const latestDelegationsSQL = `
+	SELECT DISTINCT ON (delegator) delegator, baker, id, level, timestamp, amount
+	FROM delegations WHERE %s
+	ORDER BY delegator, id DESC`
+
+// updateCurrentDelegations moves the current delegation of delegators to the delegations with the
+// given ids, just inserted in tx, unless a later delegation is already current. Delegations can
+// thus be inserted in any order, as backfills do.
+func updateCurrentDelegations(ctx context.Context, tx pgx.Tx, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO current_delegations (delegator, baker, delegation_id, level, timestamp, amount)
+		`+fmt.Sprintf(latestDelegationsSQL, "id = ANY($1)")+`
+		ON CONFLICT (delegator) DO UPDATE SET
+			baker = EXCLUDED.baker, delegation_id = EXCLUDED.delegation_id, level = EXCLUDED.level,
+			timestamp = EXCLUDED.timestamp, amount = EXCLUDED.amount
+		WHERE current_delegations.delegation_id < EXCLUDED.delegation_id`,
+		ids)
+	return err
+}
+
+// computeCurrentDelegations replaces the current delegations of the delegators selected by
+// where (a condition on the delegator column, "TRUE" for all of them) with their latest delegation
+func computeCurrentDelegations(ctx context.Context, tx pgx.Tx, where string) error {
+	if _, err := tx.Exec(ctx, "DELETE FROM current_delegations WHERE "+where); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO current_delegations (delegator, baker, delegation_id, level, timestamp, amount)
+		`+fmt.Sprintf(latestDelegationsSQL, where))
+	return err
+}
+
+// RebuildCurrentDelegations recomputes the current delegations from the delegations table,
+// blocking writes to delegations meanwhile. It returns the number of delegators.
+func RebuildCurrentDelegations(ctx context.Context, pool *pgxpool.Pool) (delegators int64, err error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "LOCK TABLE delegations IN SHARE MODE"); err != nil {
+		return 0, fmt.Errorf("failed to lock delegations: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "TRUNCATE current_delegations"); err != nil {
+		return 0, fmt.Errorf("failed to truncate current delegations: %w", err)
+	}
+	if err := computeCurrentDelegations(ctx, tx, "TRUE"); err != nil {
+		return 0, fmt.Errorf("failed to compute current delegations: %w", err)
+	}
+	if err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM current_delegations").Scan(&delegators); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit: %w", err)
+	}
+	return delegators, nil
+}
+
+// GetCurrentDelegation returns the current delegation of delegator, or nil if it never delegated
+func GetCurrentDelegation(ctx context.Context, pool *pgxpool.Pool, delegator string) (*CurrentDelegation, error) {
+	var c CurrentDelegation
+	err := pool.QueryRow(ctx, `
+		SELECT delegator, baker, delegation_id, level, timestamp, amount
+		FROM current_delegations WHERE delegator = $1`, delegator,
+	).Scan(&c.Delegator, &c.Baker, &c.DelegationID, &c.Level, &c.Timestamp, &c.Amount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// PointInTime is a point in the delegation history: after the block at Level, or at Timestamp
+// when Level is zero. The delegations table only holds applied delegations (failed ones are not
+// indexed), so the latest delegation up to a point is the delegate on chain at that point.
+type PointInTime struct {
+	Level     int32
+	Timestamp time.Time
+}
+
+// condition returns the SQL condition selecting the delegations of alias up to p, and its argument
+func (p PointInTime) condition(alias string, arg int) (string, any) {
+	if p.Level > 0 {
+		return fmt.Sprintf("%s.level <= $%d", alias, arg), p.Level
+	}
+	return fmt.Sprintf("%s.timestamp <= $%d", alias, arg), p.Timestamp.UTC()
+}
+
+// GetDelegationAt returns the latest delegation of delegator up to at, or nil if it had not delegated yet
+func GetDelegationAt(ctx context.Context, pool *pgxpool.Pool, delegator string, at PointInTime) (*CurrentDelegation, error) {
+	condition, arg := at.condition("d", 2)
+
+	var c CurrentDelegation
+	err := pool.QueryRow(ctx, `
+		SELECT delegator, baker, id, level, timestamp, amount
+		FROM delegations d
+		WHERE delegator = $1 AND `+condition+`
+		ORDER BY id DESC
+		LIMIT 1`, delegator, arg,
+	).Scan(&c.Delegator, &c.Baker, &c.DelegationID, &c.Level, &c.Timestamp, &c.Amount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/dbtest"
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// currentDelegations returns the content of current_delegations by delegator
+func currentDelegations(t *testing.T, pool *pgxpool.Pool) map[string]CurrentDelegation {
+	t.Helper()
+
+	rows, err := pool.Query(context.Background(), "SELECT delegator, baker, delegation_id, level, timestamp, amount FROM current_delegations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	all := make(map[string]CurrentDelegation)
+	for rows.Next() {
+		var c CurrentDelegation
+		if err := rows.Scan(&c.Delegator, &c.Baker, &c.DelegationID, &c.Level, &c.Timestamp, &c.Amount); err != nil {
+			t.Fatal(err)
+		}
+		all[c.Delegator] = c
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return all
+}
+
+func TestIntegration_CurrentDelegations(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+
+	const (
+		alice  = "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo"
+		bob    = "tz1QMwsi5onCV9yR2VJsSCRBDsrSfzrViMss"
+		carol  = "tz1a1SAaXRt9yoGMx29rh9FsBF4UzmvojdTL"
+		baker1 = "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM"
+		baker2 = "tz3RDC3Jdn4j15J7bBHZd29EUee9gVB1CxD9"
+	)
+	at := func(level int32) time.Time {
+		return time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(level) * time.Minute)
+	}
+	delegation := func(id int64, delegator, baker, prevBaker string) models.Delegation {
+		return models.Delegation{ID: id, Delegator: delegator, Baker: baker, PrevBaker: prevBaker, Timestamp: at(int32(id)), Amount: id * 10, Level: int32(id)}
+	}
+
+	// Indexed delegations, then an older one from a backfill that must not replace them
+	if err := BulkInsertDelegations(ctx, pool, []models.Delegation{
+		delegation(10, alice, baker1, ""),
+		delegation(11, bob, baker1, ""),
+		delegation(12, alice, baker2, baker1),
+		delegation(13, bob, "", baker1),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyInsertDelegations(ctx, pool, []models.Delegation{
+		delegation(1, alice, baker1, ""),
+		delegation(2, carol, baker1, ""),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]CurrentDelegation{
+		alice: {Delegator: alice, Baker: baker2, DelegationID: 12, Level: 12, Timestamp: at(12), Amount: 120},
+		bob:   {Delegator: bob, Baker: "", DelegationID: 13, Level: 13, Timestamp: at(13), Amount: 130},
+		carol: {Delegator: carol, Baker: baker1, DelegationID: 2, Level: 2, Timestamp: at(2), Amount: 20},
+	}
+	if got := currentDelegations(t, pool); !reflect.DeepEqual(got, want) {
+		t.Errorf("current delegations = %+v, want %+v", got, want)
+	}
+
+	current, err := GetCurrentDelegation(ctx, pool, alice)
+	if err != nil || current == nil || current.Baker != baker2 {
+		t.Errorf("GetCurrentDelegation(alice) = %+v, %v, want baker2", current, err)
+	}
+	if current, err := GetCurrentDelegation(ctx, pool, baker1); err != nil || current != nil {
+		t.Errorf("GetCurrentDelegation(baker1) = %+v, %v, want nil", current, err)
+	}
+
+	// Correcting delegation 12 to be carol's moves alice back to her previous delegation
+	if _, err := CopyMergeDelegations(ctx, pool, []models.Delegation{delegation(12, carol, baker2, baker1)}, true); err != nil {
+		t.Fatal(err)
+	}
+	want[alice] = CurrentDelegation{Delegator: alice, Baker: baker1, DelegationID: 10, Level: 10, Timestamp: at(10), Amount: 100}
+	want[carol] = CurrentDelegation{Delegator: carol, Baker: baker2, DelegationID: 12, Level: 12, Timestamp: at(12), Amount: 120}
+	if got := currentDelegations(t, pool); !reflect.DeepEqual(got, want) {
+		t.Errorf("current delegations after update = %+v, want %+v", got, want)
+	}
+
+	if _, err := pool.Exec(ctx, "DELETE FROM current_delegations"); err != nil {
+		t.Fatal(err)
+	}
+	delegators, err := RebuildCurrentDelegations(ctx, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := currentDelegations(t, pool); delegators != 3 || !reflect.DeepEqual(got, want) {
+		t.Errorf("rebuilt current delegations = %+v, want %+v", got, want)
+	}
+}
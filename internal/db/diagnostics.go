@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Summary describes the content of the delegations table
+type Summary struct {
+	Count        int64
+	MinID        *int64
+	MaxID        *int64
+	MinTimestamp *time.Time
+	MaxTimestamp *time.Time
+}
+
+// Gap is a period without any indexed delegation
+type Gap struct {
+	From time.Time
+	To   time.Time
+}
+
+// GetSummary returns the row count and the id and timestamp ranges of the delegations table
+func GetSummary(ctx context.Context, pool *pgxpool.Pool) (*Summary, error) {
+	var s Summary
+	err := pool.QueryRow(ctx, "SELECT COUNT(*), MIN(id), MAX(id), MIN(timestamp), MAX(timestamp) FROM delegations").
+		Scan(&s.Count, &s.MinID, &s.MaxID, &s.MinTimestamp, &s.MaxTimestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// FindCoverageGaps returns up to limit periods longer than minGap between consecutive delegations, longest first.
+// It scans the whole timestamp index.
+func FindCoverageGaps(ctx context.Context, pool *pgxpool.Pool, minGap time.Duration, limit int) ([]Gap, error) {
+	query := `
+		SELECT previous, timestamp FROM (
+			SELECT timestamp, LAG(timestamp) OVER (ORDER BY timestamp) AS previous FROM delegations
+		) t
+		WHERE timestamp - previous > $1 * INTERVAL '1 second'
+		ORDER BY timestamp - previous DESC
+		LIMIT $2`
+
+	rows, err := pool.Query(ctx, query, minGap.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Gap, error) {
+		var g Gap
+		err := row.Scan(&g.From, &g.To)
+		return g, err
+	})
+}
+
+// ListIndexes returns the names of the indexes of a table
+func ListIndexes(ctx context.Context, pool *pgxpool.Pool, table string) ([]string, error) {
+	rows, err := pool.Query(ctx, "SELECT indexname FROM pg_indexes WHERE tablename = $1", table)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// GetServerInfo returns the database clock and the max_connections server setting
+func GetServerInfo(ctx context.Context, pool *pgxpool.Pool) (now time.Time, maxConnections int32, err error) {
+	err = pool.QueryRow(ctx, "SELECT now(), current_setting('max_connections')::int").Scan(&now, &maxConnections)
+	return
+}
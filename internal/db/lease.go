@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrLeaseLost reports that the lease fencing the writes of a context is no longer held
+var ErrLeaseLost = errors.New("lease lost")
+
+// leaseKey is the context key of the lease fencing inserts
+type leaseKey struct{}
+
+// heldLease is a lease held by holder
+type heldLease struct {
+	name   string
+	holder string
+}
+
+// Lease is a row of the indexer_leases table
+type Lease struct {
+	Name       string
+	Holder     string
+	AcquiredAt time.Time
+	RenewedAt  time.Time
+	ExpiresAt  time.Time
+}
+
+// TryAcquireLease acquires or renews the named lease for holder until now + ttl.
+// It succeeds if the lease is free, expired or already held by holder. Times come
+// from the database clock so replicas with skewed clocks agree on expiry.
+func TryAcquireLease(ctx context.Context, pool *pgxpool.Pool, name, holder string, ttl time.Duration) (bool, error) {
+	query := `
+		INSERT INTO indexer_leases (name, holder, acquired_at, renewed_at, expires_at)
+		VALUES ($1, $2, now(), now(), now() + $3 * interval '1 millisecond')
+		ON CONFLICT (name) DO UPDATE SET
+			holder = EXCLUDED.holder,
+			acquired_at = CASE WHEN indexer_leases.holder = EXCLUDED.holder
+				THEN indexer_leases.acquired_at ELSE now() END,
+			renewed_at = now(),
+			expires_at = EXCLUDED.expires_at
+		WHERE indexer_leases.holder = EXCLUDED.holder OR indexer_leases.expires_at < now()
+		RETURNING holder`
+
+	var got string
+	err := pool.QueryRow(ctx, query, name, holder, ttl.Milliseconds()).Scan(&got)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return got == holder, nil
+}
+
+// ReleaseLease gives up the named lease if it is held by holder
+func ReleaseLease(ctx context.Context, pool *pgxpool.Pool, name, holder string) error {
+	_, err := pool.Exec(ctx, "DELETE FROM indexer_leases WHERE name = $1 AND holder = $2", name, holder)
+	return err
+}
+
+// GetLease returns the named lease, or nil if nobody holds it
+func GetLease(ctx context.Context, pool *pgxpool.Pool, name string) (*Lease, error) {
+	var l Lease
+	err := pool.QueryRow(ctx,
+		"SELECT name, holder, acquired_at, renewed_at, expires_at FROM indexer_leases WHERE name = $1", name,
+	).Scan(&l.Name, &l.Holder, &l.AcquiredAt, &l.RenewedAt, &l.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &l, nil
+}
+
+// WithLease returns a context fencing the live inserts made with it on the named lease:
+// they fail with ErrLeaseLost and roll back unless holder still holds the lease
+func WithLease(ctx context.Context, name, holder string) context.Context {
+	return context.WithValue(ctx, leaseKey{}, heldLease{name: name, holder: holder})
+}
+
+// checkLease verifies that the lease of ctx, if any, is still held, and locks it until tx ends
+// so that a standby cannot take it over before tx commits
+func checkLease(ctx context.Context, tx pgx.Tx) error {
+	lease, ok := ctx.Value(leaseKey{}).(heldLease)
+	if !ok {
+		return nil
+	}
+
+	var held bool
+	err := tx.QueryRow(ctx, `
+		SELECT true FROM indexer_leases
+		WHERE name = $1 AND holder = $2 AND expires_at > now()
+		FOR SHARE`, lease.name, lease.holder).Scan(&held)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrLeaseLost
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check lease: %w", err)
+	}
+	return nil
+}
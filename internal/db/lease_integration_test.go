@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/dbtest"
+	"github.com/broyeztony/delegated/internal/models"
+)
+
+func TestIntegration_LeaseFencesInserts(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+
+	const lease = "indexer"
+	delegation := func(id int64) models.Delegation {
+		return models.Delegation{ID: id, Delegator: "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo", Timestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Amount: 1, Level: int32(id)}
+	}
+
+	if ok, err := TryAcquireLease(ctx, pool, lease, "a", 200*time.Millisecond); err != nil || !ok {
+		t.Fatalf("TryAcquireLease(a) = %v, %v, want true", ok, err)
+	}
+	fenced := WithLease(ctx, lease, "a")
+	if err := BulkInsertDelegations(fenced, pool, []models.Delegation{delegation(1)}); err != nil {
+		t.Fatalf("BulkInsertDelegations() while holding the lease error = %v", err)
+	}
+
+	// b takes the expired lease over: a can no longer write
+	time.Sleep(300 * time.Millisecond)
+	if ok, err := TryAcquireLease(ctx, pool, lease, "b", time.Minute); err != nil || !ok {
+		t.Fatalf("TryAcquireLease(b) = %v, %v, want true", ok, err)
+	}
+	if err := BulkInsertDelegations(fenced, pool, []models.Delegation{delegation(2)}); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("BulkInsertDelegations() after losing the lease error = %v, want ErrLeaseLost", err)
+	}
+	if err := BulkInsertStakingOps(fenced, pool, []models.StakingOp{{ID: 3, Staker: "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo", Action: models.StakingActionStake, Level: 3}}); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("BulkInsertStakingOps() after losing the lease error = %v, want ErrLeaseLost", err)
+	}
+
+	if count, maxID, err := GetMaxID(ctx, pool); err != nil || count != 1 || maxID != 1 {
+		t.Errorf("GetMaxID() = %d, %d, %v, want only the delegation inserted while holding the lease", count, maxID, err)
+	}
+}
@@ -0,0 +1,29 @@
+package db
+
+import "encoding/json"
+
+// DelegationsChannel is the NOTIFY channel on which committed delegation batches are announced
+const DelegationsChannel = "delegations"
+
+// Notification is the payload sent on DelegationsChannel: the id range of a committed batch
+type Notification struct {
+	FromID int64 `json:"from_id"`
+	ToID   int64 `json:"to_id"`
+}
+
+// newNotification returns the id range covered by ids, which must not be empty
+func newNotification(ids []int64) Notification {
+	n := Notification{FromID: ids[0], ToID: ids[0]}
+	for _, id := range ids[1:] {
+		n.FromID = min(n.FromID, id)
+		n.ToID = max(n.ToID, id)
+	}
+	return n
+}
+
+// ParseNotification decodes a DelegationsChannel payload
+func ParseNotification(payload string) (Notification, error) {
+	var n Notification
+	err := json.Unmarshal([]byte(payload), &n)
+	return n, err
+}
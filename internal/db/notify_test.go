@@ -0,0 +1,26 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNotification_RoundTrip(t *testing.T) {
+	n := newNotification([]int64{12, 10, 15})
+	if n.FromID != 10 || n.ToID != 15 {
+		t.Fatalf("newNotification() = %+v, want 10-15", n)
+	}
+
+	payload, err := json.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(payload) != `{"from_id":10,"to_id":15}` {
+		t.Errorf("payload = %s", payload)
+	}
+
+	got, err := ParseNotification(string(payload))
+	if err != nil || got != n {
+		t.Errorf("ParseNotification() = %+v, %v, want %+v", got, err, n)
+	}
+}
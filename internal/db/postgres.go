@@ -2,11 +2,17 @@ package db
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/broyeztony/delegated/internal/models"
+	"github.com/broyeztony/delegated/internal/telemetry"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // GetMaxID returns the count and max id from the delegations table
@@ -15,22 +21,36 @@ func GetMaxID(ctx context.Context, pool *pgxpool.Pool) (count int64, maxID int64
 	return
 }
 
+// GetLatestID returns the max id of the delegations table (0 when empty), read from the primary key index
+func GetLatestID(ctx context.Context, pool *pgxpool.Pool) (maxID int64, err error) {
+	err = pool.QueryRow(ctx, "SELECT COALESCE(MAX(id), 0) FROM delegations").Scan(&maxID)
+	return
+}
+
 // GetMinID returns the count and min id from the delegations table
 func GetMinID(ctx context.Context, pool *pgxpool.Pool) (count int64, minID int64, err error) {
 	err = pool.QueryRow(ctx, "SELECT COUNT(*), COALESCE(MIN(id), 0) FROM delegations").Scan(&count, &minID)
 	return
 }
 
-// BulkInsertDelegations inserts delegations with ON CONFLICT handling using bulk insert
-func BulkInsertDelegations(ctx context.Context, pool *pgxpool.Pool, delegations []models.Delegation) error {
+// BulkInsertDelegations inserts delegations with ON CONFLICT handling using bulk insert.
+// In the same transaction, newly inserted delegations update the daily rollups and the current
+// delegations, those matching a webhook are queued in the webhook outbox, and the id range of
+// the inserted delegations, if any, is announced on DelegationsChannel (delivered on commit).
+// When ctx is fenced with WithLease, nothing is written unless the lease is still held.
+func BulkInsertDelegations(ctx context.Context, pool *pgxpool.Pool, delegations []models.Delegation) (err error) {
 	if len(delegations) == 0 {
 		return nil
 	}
 
+	ctx, span := startSpan(ctx, "BulkInsertDelegations", len(delegations))
+	defer func() { endSpan(span, err) }()
+
 	query := `
-		INSERT INTO delegations (id, delegator, timestamp, amount, level)
-		VALUES (@id, @delegator, @timestamp, @amount, @level)
-		ON CONFLICT (id) DO NOTHING`
+		INSERT INTO delegations (id, delegator, timestamp, amount, level, baker, prev_baker)
+		VALUES (@id, @delegator, @timestamp, @amount, @level, @baker, @prev_baker)
+		ON CONFLICT (id) DO NOTHING
+		RETURNING id`
 
 	// Use a transaction for atomicity
 	tx, err := pool.Begin(ctx)
@@ -39,14 +59,20 @@ func BulkInsertDelegations(ctx context.Context, pool *pgxpool.Pool, delegations
 	}
 	defer tx.Rollback(ctx)
 
+	if err := checkLease(ctx, tx); err != nil {
+		return err
+	}
+
 	batch := &pgx.Batch{}
 	for _, d := range delegations {
 		args := pgx.NamedArgs{
-			"id":        d.ID,
-			"delegator": d.Delegator,
-			"timestamp": d.Timestamp,
-			"amount":    d.Amount,
-			"level":     d.Level,
+			"id":         d.ID,
+			"delegator":  d.Delegator,
+			"timestamp":  d.Timestamp,
+			"amount":     d.Amount,
+			"level":      d.Level,
+			"baker":      d.Baker,
+			"prev_baker": d.PrevBaker,
 		}
 		batch.Queue(query, args)
 	}
@@ -54,41 +80,200 @@ func BulkInsertDelegations(ctx context.Context, pool *pgxpool.Pool, delegations
 	results := tx.SendBatch(ctx, batch)
 	defer results.Close()
 
-	// Consume all results to complete the batch
+	// Consume all results to complete the batch, collecting the ids actually inserted
+	insertedIDs := make([]int64, 0, len(delegations))
 	for i := 0; i < len(delegations); i++ {
-		_, err := results.Exec()
+		var id int64
+		err := results.QueryRow().Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue // already indexed
+		}
 		if err != nil {
 			return fmt.Errorf("failed to insert delegation in batch: %w", err)
 		}
+		insertedIDs = append(insertedIDs, id)
 	}
-
 	if err := results.Close(); err != nil {
 		return fmt.Errorf("failed to close batch results: %w", err)
 	}
 
+	// Only wake the listeners up when there are new rows to read
+	if len(insertedIDs) > 0 {
+		payload, err := json.Marshal(newNotification(insertedIDs))
+		if err != nil {
+			return fmt.Errorf("failed to encode notification: %w", err)
+		}
+		if _, err := tx.Exec(ctx, "SELECT pg_notify($1, $2)", DelegationsChannel, string(payload)); err != nil {
+			return fmt.Errorf("failed to notify: %w", err)
+		}
+	}
+
+	if err := enqueueWebhookDeliveries(ctx, tx, insertedIDs); err != nil {
+		return fmt.Errorf("failed to enqueue webhook deliveries: %w", err)
+	}
+	if err := updateDerivedTables(ctx, tx, insertedIDs); err != nil {
+		return err
+	}
+
 	// Commit the transaction
 	return tx.Commit(ctx)
 }
 
-// CopyInsertDelegations uses COPY protocol for fast bulk insertion directly into delegations table
-func CopyInsertDelegations(ctx context.Context, pool *pgxpool.Pool, delegations []models.Delegation) error {
+// delegationColumns are the columns written by COPY, in the order of delegationRows
+var delegationColumns = []string{"id", "delegator", "timestamp", "amount", "level", "baker", "prev_baker"}
+
+// delegationRows builds the rows of a COPY into delegationColumns
+func delegationRows(delegations []models.Delegation) pgx.CopyFromSource {
+	rows := make([][]interface{}, len(delegations))
+	for i, d := range delegations {
+		rows[i] = []interface{}{d.ID, d.Delegator, d.Timestamp, d.Amount, d.Level, d.Baker, d.PrevBaker}
+	}
+	return pgx.CopyFromRows(rows)
+}
+
+// CopyInsertDelegations uses COPY protocol for fast bulk insertion directly into delegations table,
+// and updates the daily rollups and the current delegations in the same transaction
+func CopyInsertDelegations(ctx context.Context, pool *pgxpool.Pool, delegations []models.Delegation) (err error) {
 	if len(delegations) == 0 {
 		return nil
 	}
 
-	// Build rows data for COPY
-	rows := make([][]interface{}, len(delegations))
-	for i, d := range delegations {
-		rows[i] = []interface{}{d.ID, d.Delegator, d.Timestamp, d.Amount, d.Level}
+	ctx, span := startSpan(ctx, "CopyInsertDelegations", len(delegations))
+	defer func() { endSpan(span, err) }()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback(ctx)
 
 	// Use COPY FROM to insert directly into delegations table
-	_, err := pool.CopyFrom(
-		ctx,
-		pgx.Identifier{"delegations"},
-		[]string{"id", "delegator", "timestamp", "amount", "level"},
-		pgx.CopyFromRows(rows),
-	)
-
-	return err
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"delegations"}, delegationColumns, delegationRows(delegations)); err != nil {
+		return err
+	}
+
+	ids := make([]int64, len(delegations))
+	for i, d := range delegations {
+		ids[i] = d.ID
+	}
+	if err := updateDerivedTables(ctx, tx, ids); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// CopyMergeDelegations copies delegations into a temporary staging table, then moves them into the
+// delegations table: rows whose id already exists are skipped, or overwritten when update is set.
+// It returns the number of rows inserted or changed. Duplicated ids in the batch are written once.
+// The daily rollups and the current delegations are updated in the same transaction.
+func CopyMergeDelegations(ctx context.Context, pool *pgxpool.Pool, delegations []models.Delegation, update bool) (written int64, err error) {
+	if len(delegations) == 0 {
+		return 0, nil
+	}
+
+	ctx, span := startSpan(ctx, "CopyMergeDelegations", len(delegations))
+	defer func() { endSpan(span, err) }()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "CREATE TEMP TABLE delegations_staging (LIKE delegations INCLUDING DEFAULTS) ON COMMIT DROP"); err != nil {
+		return 0, fmt.Errorf("failed to create staging table: %w", err)
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"delegations_staging"}, delegationColumns, delegationRows(delegations)); err != nil {
+		return 0, fmt.Errorf("failed to copy into staging table: %w", err)
+	}
+
+	insert := `
+		INSERT INTO delegations (id, delegator, timestamp, amount, level, baker, prev_baker)
+		SELECT DISTINCT ON (id) id, delegator, timestamp, amount, level, baker, prev_baker FROM delegations_staging ORDER BY id
+		ON CONFLICT (id) `
+
+	if !update {
+		rows, err := tx.Query(ctx, insert+"DO NOTHING RETURNING id")
+		if err != nil {
+			return 0, fmt.Errorf("failed to merge staging table: %w", err)
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+		if err != nil {
+			return 0, fmt.Errorf("failed to merge staging table: %w", err)
+		}
+		if err := updateDerivedTables(ctx, tx, ids); err != nil {
+			return 0, err
+		}
+		written = int64(len(ids))
+	} else {
+		// Updated rows can move to another day or delegator, or lower a maximum: recompute the
+		// rollups of the days and the current delegations of the delegators they come from and go to
+		if _, err := tx.Exec(ctx, `
+			CREATE TEMP TABLE rollup_days ON COMMIT DROP AS
+			SELECT d.timestamp::DATE AS day FROM delegations d JOIN delegations_staging s USING (id)
+			UNION SELECT timestamp::DATE FROM delegations_staging`); err != nil {
+			return 0, fmt.Errorf("failed to collect rollup days: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `
+			CREATE TEMP TABLE merged_delegators ON COMMIT DROP AS
+			SELECT d.delegator FROM delegations d JOIN delegations_staging s USING (id)
+			UNION SELECT delegator FROM delegations_staging`); err != nil {
+			return 0, fmt.Errorf("failed to collect delegators: %w", err)
+		}
+		tag, err := tx.Exec(ctx, insert+`DO UPDATE SET delegator = EXCLUDED.delegator, timestamp = EXCLUDED.timestamp,
+			amount = EXCLUDED.amount, level = EXCLUDED.level, baker = EXCLUDED.baker, prev_baker = EXCLUDED.prev_baker
+			WHERE (delegations.delegator, delegations.timestamp, delegations.amount, delegations.level, delegations.baker, delegations.prev_baker)
+				IS DISTINCT FROM (EXCLUDED.delegator, EXCLUDED.timestamp, EXCLUDED.amount, EXCLUDED.level, EXCLUDED.baker, EXCLUDED.prev_baker)`)
+		if err != nil {
+			return 0, fmt.Errorf("failed to merge staging table: %w", err)
+		}
+		if err := computeRollups(ctx, tx, "day IN (SELECT day FROM rollup_days)"); err != nil {
+			return 0, fmt.Errorf("failed to update rollups: %w", err)
+		}
+		if err := computeCurrentDelegations(ctx, tx, "delegator IN (SELECT delegator FROM merged_delegators)"); err != nil {
+			return 0, fmt.Errorf("failed to update current delegations: %w", err)
+		}
+		written = tag.RowsAffected()
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit: %w", err)
+	}
+	return written, nil
+}
+
+// updateDerivedTables adds the delegations with the given ids, just inserted in tx, to the tables
+// derived from them: the daily rollups and the current delegations
+func updateDerivedTables(ctx context.Context, tx pgx.Tx, ids []int64) error {
+	if err := addRollups(ctx, tx, ids); err != nil {
+		return fmt.Errorf("failed to update rollups: %w", err)
+	}
+	if err := updateCurrentDelegations(ctx, tx, ids); err != nil {
+		return fmt.Errorf("failed to update current delegations: %w", err)
+	}
+	return nil
+}
+
+// startSpan starts a span for a write of rows delegations
+func startSpan(ctx context.Context, name string, rows int) (context.Context, trace.Span) {
+	return telemetry.Tracer().Start(ctx, name, trace.WithAttributes(attribute.Int("delegations.count", rows)))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// GetDelegationsAfter returns up to limit delegations with an id greater than afterID, ordered by id
+func GetDelegationsAfter(ctx context.Context, pool *pgxpool.Pool, afterID int64, limit int) ([]models.Delegation, error) {
+	rows, err := pool.Query(ctx, "SELECT id, delegator, timestamp, amount, level, baker, prev_baker FROM delegations WHERE id > $1 ORDER BY id LIMIT $2", afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.Delegation])
 }
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// amountBucketSQL is the histogram bucket of amount in delegation_daily_amounts: 0 for 0 mutez,
+// k for amounts in [1.01^(k-1), 1.01^k)
+const amountBucketSQL = "CASE WHEN amount <= 0 THEN 0 ELSE FLOOR(LN(amount) / LN(1.01))::INTEGER + 1 END"
+
+// bucketAmountSQL is the amount standing for a histogram bucket, the geometric middle of its bounds
+const bucketAmountSQL = "CASE WHEN bucket = 0 THEN 0 ELSE ROUND(POWER(1.01, bucket - 0.5))::BIGINT END"
+
+// addRollups adds the delegations with the given ids, just inserted in tx, to the daily rollups.
+// Rows are upserted in key order, so concurrent transactions adding to the same days wait for
+// each other instead of deadlocking.
+func addRollups(ctx context.Context, tx pgx.Tx, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx, `
+		WITH added AS (
+			SELECT timestamp::DATE AS day, baker, delegator, amount FROM delegations WHERE id = ANY($1)
+		), new_delegators AS (
+			INSERT INTO delegation_daily_delegators (day, baker, delegator)
+			SELECT DISTINCT day, baker, delegator FROM added ORDER BY 1, 2, 3
+			ON CONFLICT DO NOTHING
+			RETURNING day, baker
+		), amounts AS (
+			INSERT INTO delegation_daily_amounts (day, baker, bucket, delegations)
+			SELECT day, baker, `+amountBucketSQL+`, COUNT(*) FROM added GROUP BY 1, 2, 3 ORDER BY 1, 2, 3
+			ON CONFLICT (day, baker, bucket) DO UPDATE
+			SET delegations = delegation_daily_amounts.delegations + EXCLUDED.delegations
+		)
+		INSERT INTO delegation_daily_rollups (day, baker, delegations, delegators, total_amount, max_amount)
+		SELECT a.day, a.baker, a.delegations, COALESCE(n.delegators, 0), a.total_amount, a.max_amount
+		FROM (
+			SELECT day, baker, COUNT(*) AS delegations, SUM(amount)::BIGINT AS total_amount, MAX(amount) AS max_amount
+			FROM added GROUP BY 1, 2
+		) a
+		LEFT JOIN (SELECT day, baker, COUNT(*) AS delegators FROM new_delegators GROUP BY 1, 2) n USING (day, baker)
+		ORDER BY 1, 2
+		ON CONFLICT (day, baker) DO UPDATE SET
+			delegations = delegation_daily_rollups.delegations + EXCLUDED.delegations,
+			delegators = delegation_daily_rollups.delegators + EXCLUDED.delegators,
+			total_amount = delegation_daily_rollups.total_amount + EXCLUDED.total_amount,
+			max_amount = GREATEST(delegation_daily_rollups.max_amount, EXCLUDED.max_amount)`,
+		ids)
+	return err
+}
+
+// computeRollups replaces the rollups of the days selected by where (a condition on the day
+// column, "TRUE" for all of them) with rollups computed from the delegations table
+func computeRollups(ctx context.Context, tx pgx.Tx, where string) error {
+	source := "(SELECT timestamp::DATE AS day, baker, delegator, amount FROM delegations) d WHERE " + where
+	for _, query := range []string{
+		"DELETE FROM delegation_daily_rollups WHERE " + where,
+		"DELETE FROM delegation_daily_delegators WHERE " + where,
+		"DELETE FROM delegation_daily_amounts WHERE " + where,
+		`INSERT INTO delegation_daily_rollups (day, baker, delegations, delegators, total_amount, max_amount)
+		SELECT day, baker, COUNT(*), COUNT(DISTINCT delegator), SUM(amount)::BIGINT, MAX(amount)
+		FROM ` + source + ` GROUP BY 1, 2`,
+		`INSERT INTO delegation_daily_delegators (day, baker, delegator)
+		SELECT DISTINCT day, baker, delegator FROM ` + source,
+		`INSERT INTO delegation_daily_amounts (day, baker, bucket, delegations)
+		SELECT day, baker, ` + amountBucketSQL + `, COUNT(*) FROM ` + source + ` GROUP BY 1, 2, 3`,
+	} {
+		if _, err := tx.Exec(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RebuildRollups recomputes all the daily rollups from the delegations table, blocking
+// writes to delegations meanwhile. It returns the number of (day, baker) rollups.
+func RebuildRollups(ctx context.Context, pool *pgxpool.Pool) (rollups int64, err error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "LOCK TABLE delegations IN SHARE MODE"); err != nil {
+		return 0, fmt.Errorf("failed to lock delegations: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "TRUNCATE delegation_daily_rollups, delegation_daily_delegators, delegation_daily_amounts"); err != nil {
+		return 0, fmt.Errorf("failed to truncate rollups: %w", err)
+	}
+	if err := computeRollups(ctx, tx, "TRUE"); err != nil {
+		return 0, fmt.Errorf("failed to compute rollups: %w", err)
+	}
+	if err := tx.QueryRow(ctx, "SELECT COUNT(*) FROM delegation_daily_rollups").Scan(&rollups); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit: %w", err)
+	}
+	return rollups, nil
+}
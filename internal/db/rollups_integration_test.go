@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/dbtest"
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// rollupRows returns the content of the rollup tables as text, in key order
+func rollupRows(t *testing.T, pool *pgxpool.Pool) []string {
+	t.Helper()
+
+	var all []string
+	for _, query := range []string{
+		"SELECT concat_ws(' ', day, baker, delegations, delegators, total_amount, max_amount) FROM delegation_daily_rollups ORDER BY day, baker",
+		"SELECT concat_ws(' ', day, baker, delegator) FROM delegation_daily_delegators ORDER BY day, baker, delegator",
+		"SELECT concat_ws(' ', day, baker, bucket, delegations) FROM delegation_daily_amounts ORDER BY day, baker, bucket",
+	} {
+		rows, err := pool.Query(context.Background(), query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var row string
+			if err := rows.Scan(&row); err != nil {
+				t.Fatal(err)
+			}
+			all = append(all, row)
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return all
+}
+
+func TestIntegration_RollupsMatchRebuild(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+
+	const (
+		alice = "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo"
+		bob   = "tz1QMwsi5onCV9yR2VJsSCRBDsrSfzrViMss"
+		baker = "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM"
+	)
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC) }
+
+	// Every write path, including a duplicate, an update moving a delegation to another day and
+	// an update lowering the maximum of a day
+	if err := CopyInsertDelegations(ctx, pool, []models.Delegation{
+		{ID: 1, Delegator: alice, Baker: baker, Timestamp: day(4), Amount: 10, Level: 1},
+		{ID: 2, Delegator: bob, Baker: baker, Timestamp: day(4), Amount: 500, Level: 2},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := BulkInsertDelegations(ctx, pool, []models.Delegation{
+		{ID: 2, Delegator: bob, Baker: baker, Timestamp: day(4), Amount: 500, Level: 2},
+		{ID: 3, Delegator: alice, Timestamp: day(5), Amount: 0, Level: 3},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CopyMergeDelegations(ctx, pool, []models.Delegation{
+		{ID: 3, Delegator: alice, Timestamp: day(5), Amount: 0, Level: 3},
+		{ID: 4, Delegator: alice, Baker: baker, Timestamp: day(5), Amount: 7, Level: 4},
+	}, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CopyMergeDelegations(ctx, pool, []models.Delegation{
+		{ID: 1, Delegator: alice, Baker: baker, Timestamp: day(6), Amount: 10, Level: 1},
+		{ID: 2, Delegator: bob, Baker: baker, Timestamp: day(4), Amount: 50, Level: 2},
+	}, true); err != nil {
+		t.Fatal(err)
+	}
+
+	incremental := rollupRows(t, pool)
+	if len(incremental) == 0 {
+		t.Fatal("no rollups")
+	}
+	if _, err := pool.Exec(ctx, "DELETE FROM delegation_daily_rollups"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RebuildRollups(ctx, pool); err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt := rollupRows(t, pool); !reflect.DeepEqual(incremental, rebuilt) {
+		t.Errorf("incremental rollups differ from rebuilt ones:\n%v\n%v", incremental, rebuilt)
+	}
+}
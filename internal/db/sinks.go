@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SinkCursor is the position of an event sink
+type SinkCursor struct {
+	Name        string
+	Cursor      int64
+	PublishedAt *time.Time
+}
+
+// InitSinkCursor creates the cursor of a new sink at the latest delegation, so that it only
+// receives delegations indexed from now on, and returns the cursor of the sink
+func InitSinkCursor(ctx context.Context, pool *pgxpool.Pool, name string) (int64, error) {
+	var cursor int64
+	err := pool.QueryRow(ctx, `
+		WITH created AS (
+			INSERT INTO sink_cursors (name, cursor)
+			SELECT $1, COALESCE(MAX(id), 0) FROM delegations
+			ON CONFLICT (name) DO NOTHING
+			RETURNING cursor
+		)
+		SELECT cursor FROM created
+		UNION ALL
+		SELECT cursor FROM sink_cursors WHERE name = $1`, name).Scan(&cursor)
+	return cursor, err
+}
+
+// ListSinkCursors returns the cursor of every sink ordered by name
+func ListSinkCursors(ctx context.Context, pool *pgxpool.Pool) ([]SinkCursor, error) {
+	rows, err := pool.Query(ctx, "SELECT name, cursor, published_at FROM sink_cursors ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[SinkCursor])
+}
+
+// PublishNextBatch calls publish with up to limit delegations following the cursor of a sink, in id order,
+// and advances the cursor past them once publish succeeded. The sink is claimed by holder for lease
+// beforehand, so that no transaction or lock is held during publish: when another process holds
+// the lease, it returns 0 without publishing. publish is cancelled when the lease expires, and the
+// cursor only advances if the lease is still held, so a batch is never published concurrently.
+// A batch that failed is published again by the next call, so delivery is at least once.
+func PublishNextBatch(
+	ctx context.Context,
+	pool *pgxpool.Pool,
+	name, holder string,
+	limit int,
+	lease time.Duration,
+	publish func(ctx context.Context, delegations []models.Delegation) error,
+) (published int, cursor int64, err error) {
+	err = pool.QueryRow(ctx, `
+		UPDATE sink_cursors SET lease_holder = $2, lease_expires_at = now() + $3 * INTERVAL '1 second'
+		WHERE name = $1 AND (lease_holder IS NULL OR lease_holder = $2 OR lease_expires_at <= now())
+		RETURNING cursor`, name, holder, lease.Seconds()).Scan(&cursor)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, 0, nil // published by another process
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to claim sink: %w", err)
+	}
+	// The lease is released however this call ends, even when shutting down
+	released := false
+	defer func() {
+		if released {
+			return
+		}
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if _, releaseErr := pool.Exec(releaseCtx, `
+			UPDATE sink_cursors SET lease_holder = NULL, lease_expires_at = NULL
+			WHERE name = $1 AND lease_holder = $2`, name, holder); releaseErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to release sink: %w", releaseErr))
+		}
+	}()
+
+	rows, err := pool.Query(ctx, "SELECT id, delegator, timestamp, amount, level, baker, prev_baker FROM delegations WHERE id > $1 ORDER BY id LIMIT $2", cursor, limit)
+	if err != nil {
+		return 0, cursor, fmt.Errorf("failed to read delegations: %w", err)
+	}
+	delegations, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Delegation])
+	if err != nil {
+		return 0, cursor, fmt.Errorf("failed to read delegations: %w", err)
+	}
+	if len(delegations) == 0 {
+		return 0, cursor, nil
+	}
+
+	publishCtx, cancel := context.WithTimeout(ctx, lease)
+	defer cancel()
+	if err := publish(publishCtx, delegations); err != nil {
+		return 0, cursor, err
+	}
+
+	// Advancing the cursor releases the lease, unless it expired and the sink may have been claimed again
+	next := delegations[len(delegations)-1].ID
+	tag, err := pool.Exec(ctx, `
+		UPDATE sink_cursors SET cursor = $3, published_at = now(), lease_holder = NULL, lease_expires_at = NULL
+		WHERE name = $1 AND lease_holder = $2 AND lease_expires_at > now()`, name, holder, next)
+	if err != nil {
+		return 0, cursor, fmt.Errorf("failed to advance cursor: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, cursor, fmt.Errorf("lease on sink %s expired before the cursor advanced", name)
+	}
+	released = true
+
+	return len(delegations), next, nil
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// stakingColumns are the columns of the staking_ops table, in the order of stakingRows
+var stakingColumns = []string{"id", "staker", "baker", "action", "timestamp", "amount", "level"}
+
+// stakingRows builds the rows of stakingColumns
+func stakingRows(ops []models.StakingOp) [][]interface{} {
+	rows := make([][]interface{}, len(ops))
+	for i, o := range ops {
+		rows[i] = []interface{}{o.ID, o.Staker, o.Baker, o.Action, o.Timestamp, o.Amount, o.Level}
+	}
+	return rows
+}
+
+// BulkInsertStakingOps inserts staking operations, skipping those already indexed
+func BulkInsertStakingOps(ctx context.Context, pool *pgxpool.Pool, ops []models.StakingOp) (err error) {
+	if len(ops) == 0 {
+		return nil
+	}
+
+	ctx, span := startSpan(ctx, "BulkInsertStakingOps", len(ops))
+	defer func() { endSpan(span, err) }()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := checkLease(ctx, tx); err != nil {
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	for _, row := range stakingRows(ops) {
+		batch.Queue(`
+			INSERT INTO staking_ops (id, staker, baker, action, timestamp, amount, level)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (id) DO NOTHING`, row...)
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to insert staking operations in batch: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// CopyInsertStakingOps uses COPY protocol for fast bulk insertion of staking operations
+func CopyInsertStakingOps(ctx context.Context, pool *pgxpool.Pool, ops []models.StakingOp) (err error) {
+	if len(ops) == 0 {
+		return nil
+	}
+
+	ctx, span := startSpan(ctx, "CopyInsertStakingOps", len(ops))
+	defer func() { endSpan(span, err) }()
+
+	_, err = pool.CopyFrom(ctx, pgx.Identifier{"staking_ops"}, stakingColumns, pgx.CopyFromRows(stakingRows(ops)))
+	return err
+}
+
+// GetStakingMaxID returns the count and max id from the staking_ops table
+func GetStakingMaxID(ctx context.Context, pool *pgxpool.Pool) (count int64, maxID int64, err error) {
+	err = pool.QueryRow(ctx, "SELECT COUNT(*), COALESCE(MAX(id), 0) FROM staking_ops").Scan(&count, &maxID)
+	return
+}
+
+// GetStakingMinID returns the count and min id from the staking_ops table
+func GetStakingMinID(ctx context.Context, pool *pgxpool.Pool) (count int64, minID int64, err error) {
+	err = pool.QueryRow(ctx, "SELECT COUNT(*), COALESCE(MIN(id), 0) FROM staking_ops").Scan(&count, &minID)
+	return
+}
+
+// StakingQuery filters staking operations. Empty fields do not filter.
+type StakingQuery struct {
+	Staker string
+	Baker  string
+	Action string
+	// BeforeID pages backward: only operations with a smaller id are returned (0 for the latest)
+	BeforeID int64
+	Limit    int
+}
+
+// ListStakingOps returns up to q.Limit staking operations matching q, latest first
+func ListStakingOps(ctx context.Context, pool *pgxpool.Pool, q StakingQuery) ([]models.StakingOp, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if q.Staker != "" {
+		add("staker = $%d", q.Staker)
+	}
+	if q.Baker != "" {
+		add("baker = $%d", q.Baker)
+	}
+	if q.Action != "" {
+		add("action = $%d", q.Action)
+	}
+	if q.BeforeID > 0 {
+		add("id < $%d", q.BeforeID)
+	}
+
+	query := "SELECT id, staker, baker, action, timestamp, amount, level FROM staking_ops"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, q.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[models.StakingOp])
+}
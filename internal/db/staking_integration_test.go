@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/dbtest"
+	"github.com/broyeztony/delegated/internal/models"
+)
+
+func TestIntegration_StakingOps(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+
+	const (
+		staker = "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo"
+		other  = "tz1a1SAaXRt9yoGMx29rh9FsBF4UzmvojdTL"
+		baker  = "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM"
+	)
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	op := func(id int64, sender, action string) models.StakingOp {
+		return models.StakingOp{ID: id, Staker: sender, Baker: baker, Action: action, Timestamp: start.Add(time.Duration(id) * time.Minute), Amount: id * 1000, Level: int32(id)}
+	}
+
+	// A backfill page is copied, a poll overlapping it is inserted without duplicates
+	if err := CopyInsertStakingOps(ctx, pool, []models.StakingOp{op(1, staker, "stake"), op(2, other, "stake")}); err != nil {
+		t.Fatalf("CopyInsertStakingOps() error = %v", err)
+	}
+	if err := BulkInsertStakingOps(ctx, pool, []models.StakingOp{op(2, other, "stake"), op(3, staker, "unstake"), op(4, staker, "finalize")}); err != nil {
+		t.Fatalf("BulkInsertStakingOps() error = %v", err)
+	}
+
+	if count, maxID, err := GetStakingMaxID(ctx, pool); err != nil || count != 4 || maxID != 4 {
+		t.Errorf("GetStakingMaxID() = %d, %d, %v, want 4, 4", count, maxID, err)
+	}
+	if count, minID, err := GetStakingMinID(ctx, pool); err != nil || count != 4 || minID != 1 {
+		t.Errorf("GetStakingMinID() = %d, %d, %v, want 4, 1", count, minID, err)
+	}
+
+	tests := []struct {
+		name    string
+		query   StakingQuery
+		wantIDs []int64
+	}{
+		{"latest first", StakingQuery{Limit: 10}, []int64{4, 3, 2, 1}},
+		{"limit", StakingQuery{Limit: 2}, []int64{4, 3}},
+		{"staker", StakingQuery{Staker: staker, Limit: 10}, []int64{4, 3, 1}},
+		{"baker and action", StakingQuery{Baker: baker, Action: "stake", Limit: 10}, []int64{2, 1}},
+		{"before", StakingQuery{Staker: staker, BeforeID: 3, Limit: 10}, []int64{1}},
+		{"no match", StakingQuery{Baker: other, Limit: 10}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops, err := ListStakingOps(ctx, pool, tt.query)
+			if err != nil {
+				t.Fatalf("ListStakingOps() error = %v", err)
+			}
+			var ids []int64
+			for _, o := range ops {
+				ids = append(ids, o.ID)
+			}
+			if len(ids) != len(tt.wantIDs) {
+				t.Fatalf("ids = %v, want %v", ids, tt.wantIDs)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Errorf("ids = %v, want %v", ids, tt.wantIDs)
+					break
+				}
+			}
+		})
+	}
+
+	ops, err := ListStakingOps(ctx, pool, StakingQuery{Limit: 1})
+	if err != nil || len(ops) != 1 || ops[0] != op(4, staker, "finalize") {
+		t.Errorf("ListStakingOps() = %+v, %v, want %+v", ops, err, op(4, staker, "finalize"))
+	}
+}
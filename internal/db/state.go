@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SchemaVersion is the version of schema.sql this binary expects
+const SchemaVersion = 9
+
+// DelegationsIndexer is the indexer_state row of the live delegations indexer
+const DelegationsIndexer = "delegations"
+
+// StakingIndexer is the indexer_state row of the live staking operations indexer
+const StakingIndexer = "staking"
+
+// IndexerState is a row of the indexer_state table, written by the indexer after each successful poll
+type IndexerState struct {
+	Name   string
+	Cursor int64
+	// SyncedLevel and SyncedTimestamp describe the last TzKT head the indexer fully caught up with
+	SyncedLevel     *int32
+	SyncedTimestamp *time.Time
+	LastPollAt      time.Time
+	LastIngestAt    *time.Time
+
+	// Ingested marks a poll that inserted rows, so that saving it updates LastIngestAt
+	Ingested bool
+}
+
+// LatestDelegation is the most recent indexed delegation
+type LatestDelegation struct {
+	ID        int64
+	Level     int32
+	Timestamp time.Time
+}
+
+// GetSchemaVersion returns the version recorded by schema.sql
+func GetSchemaVersion(ctx context.Context, pool *pgxpool.Pool) (version int, err error) {
+	err = pool.QueryRow(ctx, "SELECT version FROM schema_version").Scan(&version)
+	return
+}
+
+// SaveIndexerState upserts the indexer state. Poll and ingest times come from the database clock.
+func SaveIndexerState(ctx context.Context, pool *pgxpool.Pool, state IndexerState) error {
+	query := `
+		INSERT INTO indexer_state (name, cursor, synced_level, synced_timestamp, last_poll_at, last_ingest_at)
+		VALUES ($1, $2, $3, $4, now(), CASE WHEN $5::boolean THEN now() END)
+		ON CONFLICT (name) DO UPDATE SET
+			cursor = EXCLUDED.cursor,
+			synced_level = COALESCE(EXCLUDED.synced_level, indexer_state.synced_level),
+			synced_timestamp = COALESCE(EXCLUDED.synced_timestamp, indexer_state.synced_timestamp),
+			last_poll_at = EXCLUDED.last_poll_at,
+			last_ingest_at = COALESCE(EXCLUDED.last_ingest_at, indexer_state.last_ingest_at)`
+
+	_, err := pool.Exec(ctx, query, state.Name, state.Cursor, state.SyncedLevel, state.SyncedTimestamp, state.Ingested)
+	return err
+}
+
+// GetIndexerState returns the named indexer state, or nil if the indexer never polled
+func GetIndexerState(ctx context.Context, pool *pgxpool.Pool, name string) (*IndexerState, error) {
+	var s IndexerState
+	err := pool.QueryRow(ctx, `
+		SELECT name, cursor, synced_level, synced_timestamp, last_poll_at, last_ingest_at
+		FROM indexer_state WHERE name = $1`, name,
+	).Scan(&s.Name, &s.Cursor, &s.SyncedLevel, &s.SyncedTimestamp, &s.LastPollAt, &s.LastIngestAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// GetLatestDelegation returns the delegation with the highest id, or nil if the table is empty
+func GetLatestDelegation(ctx context.Context, pool *pgxpool.Pool) (*LatestDelegation, error) {
+	var d LatestDelegation
+	err := pool.QueryRow(ctx, "SELECT id, level, timestamp FROM delegations ORDER BY id DESC LIMIT 1").
+		Scan(&d.ID, &d.Level, &d.Timestamp)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Stats intervals, the units of Postgres date_trunc. Weeks start on Monday.
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+	IntervalYear  = "year"
+)
+
+// StatsQuery selects the days aggregated by GetDelegationStats.
+// Zero From and To leave the range open.
+type StatsQuery struct {
+	Interval     string
+	From         time.Time
+	To           time.Time
+	GroupByBaker bool
+}
+
+// StatsBucket aggregates the (applied) delegations of one interval, and one baker when grouped by baker.
+// Amounts are in mutez; the average is rounded to the nearest mutez and the median approximated
+// within 0.5% from the amount histogram.
+type StatsBucket struct {
+	Start         time.Time
+	Baker         string
+	Count         int64
+	Delegators    int64
+	TotalAmount   int64
+	AverageAmount int64
+	MedianAmount  int64
+	MaxAmount     int64
+}
+
+// GetDelegationStats aggregates the daily rollups of the days From <= day < To by interval,
+// ordered by interval start (then baker). Intervals without delegations are left out.
+func GetDelegationStats(ctx context.Context, pool *pgxpool.Pool, q StatsQuery) ([]StatsBucket, error) {
+	switch q.Interval {
+	case IntervalDay, IntervalWeek, IntervalMonth, IntervalYear:
+	default:
+		return nil, fmt.Errorf("unknown interval %q", q.Interval)
+	}
+
+	baker := "''"
+	if q.GroupByBaker {
+		baker = "baker"
+	}
+	bucket := "date_trunc($1, day)::TIMESTAMP AS start, " + baker + " AS baker"
+	where := "($2::DATE IS NULL OR day >= $2) AND ($3::DATE IS NULL OR day < $3)"
+
+	// The median is the lower one: the amount of the (n+1)/2th delegation by amount
+	rows, err := pool.Query(ctx, `
+		WITH totals AS (
+			SELECT `+bucket+`, SUM(delegations)::BIGINT AS delegations,
+				SUM(total_amount)::BIGINT AS total_amount, MAX(max_amount) AS max_amount
+			FROM delegation_daily_rollups WHERE `+where+` GROUP BY 1, 2
+		), delegators AS (
+			SELECT `+bucket+`, COUNT(DISTINCT delegator) AS delegators
+			FROM delegation_daily_delegators WHERE `+where+` GROUP BY 1, 2
+		), histogram AS (
+			SELECT `+bucket+`, bucket, SUM(delegations)::BIGINT AS delegations
+			FROM delegation_daily_amounts WHERE `+where+` GROUP BY 1, 2, 3
+		), medians AS (
+			SELECT start, baker, `+bucketAmountSQL+` AS median_amount
+			FROM (
+				SELECT start, baker, bucket, delegations,
+					SUM(delegations) OVER (PARTITION BY start, baker ORDER BY bucket) AS cumulative,
+					SUM(delegations) OVER (PARTITION BY start, baker) AS total
+				FROM histogram
+			) h
+			WHERE cumulative >= FLOOR((total + 1) / 2) AND cumulative - delegations < FLOOR((total + 1) / 2)
+		)
+		SELECT start, baker, t.delegations, d.delegators, t.total_amount,
+			ROUND(t.total_amount::NUMERIC / t.delegations)::BIGINT, m.median_amount, t.max_amount
+		FROM totals t
+		JOIN delegators d USING (start, baker)
+		JOIN medians m USING (start, baker)
+		ORDER BY 1, 2`,
+		q.Interval, nullTime(q.From), nullTime(q.To))
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (StatsBucket, error) {
+		var b StatsBucket
+		err := row.Scan(&b.Start, &b.Baker, &b.Count, &b.Delegators, &b.TotalAmount, &b.AverageAmount, &b.MedianAmount, &b.MaxAmount)
+		return b, err
+	})
+}
+
+// nullTime maps the zero time to NULL
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
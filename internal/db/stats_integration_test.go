@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/dbtest"
+	"github.com/broyeztony/delegated/internal/models"
+)
+
+func TestIntegration_GetDelegationStats(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+
+	const (
+		alice = "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo"
+		bob   = "tz1QMwsi5onCV9yR2VJsSCRBDsrSfzrViMss"
+		baker = "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM"
+	)
+	day := func(d int, hour int) time.Time { return time.Date(2024, 3, d, hour, 0, 0, 0, time.UTC) }
+	delegations := []models.Delegation{
+		{ID: 1, Delegator: alice, Baker: baker, Timestamp: day(4, 1), Amount: 10, Level: 1},
+		{ID: 2, Delegator: alice, Baker: baker, Timestamp: day(4, 2), Amount: 20, Level: 2},
+		{ID: 3, Delegator: bob, Timestamp: day(4, 3), Amount: 5, Level: 3},
+		{ID: 4, Delegator: bob, Baker: baker, Timestamp: day(6, 0), Amount: 100, Level: 4},
+		{ID: 5, Delegator: bob, Baker: baker, Timestamp: day(11, 0), Amount: 1, Level: 5},
+	}
+	if err := CopyInsertDelegations(ctx, pool, delegations); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query StatsQuery
+		want  []StatsBucket
+	}{
+		{
+			name:  "day",
+			query: StatsQuery{Interval: IntervalDay, To: day(11, 0)},
+			want: []StatsBucket{
+				{Start: day(4, 0), Count: 3, Delegators: 2, TotalAmount: 35, AverageAmount: 12, MedianAmount: 10, MaxAmount: 20},
+				{Start: day(6, 0), Count: 1, Delegators: 1, TotalAmount: 100, AverageAmount: 100, MedianAmount: 100, MaxAmount: 100},
+			},
+		},
+		{
+			// 2024-03-04 is a Monday
+			name:  "week",
+			query: StatsQuery{Interval: IntervalWeek, From: day(5, 0)},
+			want: []StatsBucket{
+				{Start: day(4, 0), Count: 1, Delegators: 1, TotalAmount: 100, AverageAmount: 100, MedianAmount: 100, MaxAmount: 100},
+				{Start: day(11, 0), Count: 1, Delegators: 1, TotalAmount: 1, AverageAmount: 1, MedianAmount: 1, MaxAmount: 1},
+			},
+		},
+		{
+			name:  "month by baker",
+			query: StatsQuery{Interval: IntervalMonth, GroupByBaker: true},
+			want: []StatsBucket{
+				{Start: day(1, 0), Count: 1, Delegators: 1, TotalAmount: 5, AverageAmount: 5, MedianAmount: 5, MaxAmount: 5},
+				{Start: day(1, 0), Baker: baker, Count: 4, Delegators: 2, TotalAmount: 131, AverageAmount: 33, MedianAmount: 10, MaxAmount: 100},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetDelegationStats(ctx, pool, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d buckets, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range tt.want {
+				if !got[i].Start.Equal(tt.want[i].Start) {
+					t.Errorf("bucket %d starts at %v, want %v", i, got[i].Start, tt.want[i].Start)
+				}
+				got[i].Start = tt.want[i].Start
+				if got[i] != tt.want[i] {
+					t.Errorf("bucket %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
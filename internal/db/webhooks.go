@@ -0,0 +1,255 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Webhook is a subscription to newly indexed delegations. Nil filters match everything.
+type Webhook struct {
+	ID        int64
+	URL       string
+	Secret    string
+	Delegator *string
+	Baker     *string
+	MinAmount *int64
+	CreatedAt time.Time
+}
+
+// Delivery is a queued webhook delivery of one delegation
+type Delivery struct {
+	ID         int64
+	WebhookID  int64
+	URL        string
+	Secret     string
+	Attempts   int
+	Delegation models.Delegation
+}
+
+// DeadLetter is a delivery that failed too many times
+type DeadLetter struct {
+	ID           int64
+	WebhookID    int64
+	DelegationID int64
+	Attempts     int
+	LastError    string
+	CreatedAt    time.Time
+	FailedAt     time.Time
+}
+
+// CreateWebhook stores a webhook and returns it with its id
+func CreateWebhook(ctx context.Context, pool *pgxpool.Pool, w Webhook) (*Webhook, error) {
+	err := pool.QueryRow(ctx, `
+		INSERT INTO webhooks (url, secret, delegator, baker, min_amount)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`,
+		w.URL, w.Secret, w.Delegator, w.Baker, w.MinAmount,
+	).Scan(&w.ID, &w.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+// ListWebhooks returns every webhook ordered by id
+func ListWebhooks(ctx context.Context, pool *pgxpool.Pool) ([]Webhook, error) {
+	rows, err := pool.Query(ctx, "SELECT id, url, secret, delegator, baker, min_amount, created_at FROM webhooks ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[Webhook])
+}
+
+// DeleteWebhook deletes a webhook with its queued deliveries and dead letters, and reports whether it existed
+func DeleteWebhook(ctx context.Context, pool *pgxpool.Pool, id int64) (bool, error) {
+	tag, err := pool.Exec(ctx, "DELETE FROM webhooks WHERE id = $1", id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// enqueueWebhookDeliveries queues a delivery for every webhook matching one of the given delegations
+func enqueueWebhookDeliveries(ctx context.Context, tx pgx.Tx, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO webhook_outbox (webhook_id, delegation_id)
+		SELECT w.id, d.id
+		FROM delegations d
+		JOIN webhooks w
+			ON (w.delegator IS NULL OR w.delegator = d.delegator)
+			AND (w.baker IS NULL OR w.baker = d.baker)
+			AND (w.min_amount IS NULL OR d.amount >= w.min_amount)
+		WHERE d.id = ANY($1)
+		ON CONFLICT (webhook_id, delegation_id) DO NOTHING`, ids)
+	return err
+}
+
+// ProcessDeliveries claims up to limit due deliveries and calls deliver for each of them.
+// Claiming pushes next_attempt_at forward by lease in a single statement, so several processes
+// can deliver concurrently without holding locks or a transaction during the HTTP calls; a
+// delivery claimed by a process that died is retried once its lease expires.
+// Each outcome is then recorded on its own: delivered rows are removed from the outbox, a failed
+// delivery is rescheduled after backoff(attempts), or moved to the dead-letter table once it
+// failed maxAttempts times.
+func ProcessDeliveries(
+	ctx context.Context,
+	pool *pgxpool.Pool,
+	limit, maxAttempts int,
+	lease time.Duration,
+	backoff func(attempts int) time.Duration,
+	deliver func(ctx context.Context, d Delivery) error,
+) (delivered, failed, dead int, err error) {
+	rows, err := pool.Query(ctx, `
+		WITH claimed AS (
+			SELECT id FROM webhook_outbox
+			WHERE next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), leased AS (
+			UPDATE webhook_outbox o
+			SET next_attempt_at = now() + $2 * INTERVAL '1 second'
+			FROM claimed c
+			WHERE o.id = c.id
+			RETURNING o.id, o.webhook_id, o.delegation_id, o.attempts
+		)
+		SELECT l.id, l.webhook_id, w.url, w.secret, l.attempts,
+			d.id, d.delegator, d.timestamp, d.amount, d.level, d.baker, d.prev_baker
+		FROM leased l
+		JOIN webhooks w ON w.id = l.webhook_id
+		JOIN delegations d ON d.id = l.delegation_id`, limit, lease.Seconds())
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to claim deliveries: %w", err)
+	}
+	deliveries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (Delivery, error) {
+		var d Delivery
+		err := row.Scan(&d.ID, &d.WebhookID, &d.URL, &d.Secret, &d.Attempts,
+			&d.Delegation.ID, &d.Delegation.Delegator, &d.Delegation.Timestamp, &d.Delegation.Amount, &d.Delegation.Level, &d.Delegation.Baker, &d.Delegation.PrevBaker)
+		return d, err
+	})
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to claim deliveries: %w", err)
+	}
+
+	// Deliver concurrently, recording each outcome as soon as it is known
+	outcomes := make([]string, len(deliveries))
+	recordErrs := make([]error, len(deliveries))
+	var wg sync.WaitGroup
+	for i, d := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			outcomes[i], recordErrs[i] = recordDelivery(ctx, pool, d, deliver(ctx, d), maxAttempts, backoff)
+		}()
+	}
+	wg.Wait()
+
+	for _, outcome := range outcomes {
+		switch outcome {
+		case deliveryDelivered:
+			delivered++
+		case deliveryFailed:
+			failed++
+		case deliveryDead:
+			dead++
+		}
+	}
+	return delivered, failed, dead, errors.Join(recordErrs...)
+}
+
+// Outcomes of a delivery attempt
+const (
+	deliveryDelivered   = "delivered"
+	deliveryFailed      = "failed"
+	deliveryDead        = "dead"
+	deliveryInterrupted = "interrupted"
+)
+
+// recordDelivery records the outcome of delivering d in a single statement. Updates only apply if
+// d was not claimed and attempted again meanwhile, its lease having expired.
+func recordDelivery(
+	ctx context.Context,
+	pool *pgxpool.Pool,
+	d Delivery,
+	deliverErr error,
+	maxAttempts int,
+	backoff func(attempts int) time.Duration,
+) (string, error) {
+	// Outcomes are recorded even when shutting down
+	recordCtx := context.WithoutCancel(ctx)
+
+	var outcome string
+	var err error
+	switch attempts := d.Attempts + 1; {
+	case deliverErr == nil:
+		outcome = deliveryDelivered
+		_, err = pool.Exec(recordCtx, "DELETE FROM webhook_outbox WHERE id = $1", d.ID)
+	case ctx.Err() != nil:
+		// Interrupted by shutdown, not a failure of the endpoint: released for the next run
+		outcome = deliveryInterrupted
+		_, err = pool.Exec(recordCtx, `
+			UPDATE webhook_outbox SET next_attempt_at = now()
+			WHERE id = $1 AND attempts = $2`, d.ID, d.Attempts)
+	case attempts >= maxAttempts:
+		outcome = deliveryDead
+		_, err = pool.Exec(recordCtx, `
+			WITH moved AS (DELETE FROM webhook_outbox WHERE id = $1 AND attempts = $2 RETURNING *)
+			INSERT INTO webhook_dead_letters (id, webhook_id, delegation_id, attempts, last_error, created_at)
+			SELECT id, webhook_id, delegation_id, $3, $4, created_at FROM moved`,
+			d.ID, d.Attempts, attempts, deliverErr.Error())
+	default:
+		outcome = deliveryFailed
+		_, err = pool.Exec(recordCtx, `
+			UPDATE webhook_outbox
+			SET attempts = $3, last_error = $4, next_attempt_at = now() + $5 * INTERVAL '1 second'
+			WHERE id = $1 AND attempts = $2`,
+			d.ID, d.Attempts, attempts, deliverErr.Error(), backoff(attempts).Seconds())
+	}
+	if err != nil {
+		return outcome, fmt.Errorf("failed to record delivery %d: %w", d.ID, err)
+	}
+	return outcome, nil
+}
+
+// ListDeadLetters returns the dead letters of a webhook, most recent first
+func ListDeadLetters(ctx context.Context, pool *pgxpool.Pool, webhookID int64) ([]DeadLetter, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT id, webhook_id, delegation_id, attempts, last_error, created_at, failed_at
+		FROM webhook_dead_letters WHERE webhook_id = $1 ORDER BY failed_at DESC`, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByPos[DeadLetter])
+}
+
+// ErrNotFound is returned when the requested row does not exist
+var ErrNotFound = errors.New("not found")
+
+// RetryDeadLetter moves a dead letter back to the outbox for immediate delivery, with its attempts reset
+func RetryDeadLetter(ctx context.Context, pool *pgxpool.Pool, webhookID, id int64) error {
+	tag, err := pool.Exec(ctx, `
+		WITH moved AS (DELETE FROM webhook_dead_letters WHERE id = $1 AND webhook_id = $2 RETURNING *)
+		INSERT INTO webhook_outbox (id, webhook_id, delegation_id, created_at)
+		SELECT id, webhook_id, delegation_id, created_at FROM moved`, id, webhookID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Package dbtest provides the database of the integration tests
+package dbtest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Pool connects to the database of the calling test package and reloads schema.sql, dropping
+// all data. The test is skipped unless TEST_DB_URL points to a disposable database.
+//
+// go test ./... runs the test binaries of the packages in parallel, so each package gets its
+// own database on the same server, named after the TEST_DB_URL one and the package
+// (e.g. delegated_test_indexer) and created on first use: sharing one, the packages would drop
+// each other's tables mid-test.
+func Pool(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+
+	connStr := os.Getenv("TEST_DB_URL")
+	if connStr == "" {
+		t.Skip("TEST_DB_URL not set, skipping integration test")
+	}
+
+	ctx := context.Background()
+	config, err := pgxpool.ParseConfig(connStr)
+	if err != nil {
+		t.Fatalf("failed to parse TEST_DB_URL: %v", err)
+	}
+
+	_, file, _, _ := runtime.Caller(1)
+	database := config.ConnConfig.Database + "_" + filepath.Base(filepath.Dir(file))
+	if err := createDatabase(ctx, connStr, database); err != nil {
+		t.Fatalf("failed to create database %s: %v", database, err)
+	}
+	config.ConnConfig.Database = database
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	schema, err := os.ReadFile(schemaPath())
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	if _, err := pool.Exec(ctx, string(schema)); err != nil {
+		t.Fatalf("failed to load schema: %v", err)
+	}
+
+	return pool
+}
+
+// createDatabase creates database on the server at connStr unless it exists
+func createDatabase(ctx context.Context, connStr, database string) error {
+	conn, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	var exists bool
+	err = conn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", database).Scan(&exists)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = conn.Exec(ctx, "CREATE DATABASE "+pgx.Identifier{database}.Sanitize())
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "42P04" {
+		// duplicate_database: created concurrently
+		return nil
+	}
+	return err
+}
+
+// schemaPath returns the path of schema.sql at the root of the repository
+func schemaPath() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "schema.sql")
+}
@@ -0,0 +1,95 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrSkipped is returned by a check that cannot run because a check it depends on failed
+var ErrSkipped = errors.New("skipped")
+
+// Check is a single diagnostic. Run returns a short description of what was found,
+// or an error; Hint tells the operator how to fix a failure.
+type Check struct {
+	Name string
+	Hint string
+	Run  func(ctx context.Context) (string, error)
+}
+
+// Result is the outcome of a check
+type Result struct {
+	Check
+	Detail string
+	Err    error
+}
+
+// Passed reports whether the check succeeded
+func (r Result) Passed() bool {
+	return r.Err == nil
+}
+
+// Skipped reports whether the check did not run
+func (r Result) Skipped() bool {
+	return errors.Is(r.Err, ErrSkipped)
+}
+
+// Run runs the checks in order, each with its own timeout
+func Run(ctx context.Context, checks []Check, timeout time.Duration) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, c := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		detail, err := c.Run(checkCtx)
+		cancel()
+
+		results = append(results, Result{Check: c, Detail: detail, Err: err})
+	}
+	return results
+}
+
+// Print writes one line per result, followed by the hint of failed checks,
+// and returns the number of failed checks
+func Print(w io.Writer, results []Result) (failed int) {
+	for _, r := range results {
+		switch {
+		case r.Passed():
+			fmt.Fprintf(w, "[ OK ] %s: %s\n", r.Name, r.Detail)
+		case r.Skipped():
+			fmt.Fprintf(w, "[SKIP] %s: %s\n", r.Name, strings.TrimPrefix(r.Err.Error(), ErrSkipped.Error()+": "))
+		default:
+			failed++
+			fmt.Fprintf(w, "[FAIL] %s: %v\n", r.Name, r.Err)
+			if r.Hint != "" {
+				fmt.Fprintf(w, "       hint: %s\n", r.Hint)
+			}
+		}
+	}
+	return failed
+}
+
+// ClockSkew fails when remote differs from local by more than maxSkew
+func ClockSkew(local, remote time.Time, maxSkew time.Duration) (string, error) {
+	skew := remote.Sub(local)
+	if skew.Abs() > maxSkew {
+		return "", fmt.Errorf("clock is %s off, more than %s", skew.Round(time.Millisecond), maxSkew)
+	}
+	return fmt.Sprintf("%s off", skew.Round(time.Millisecond)), nil
+}
+
+// minPoolConns covers the connections held at once by `run`: indexer batch, lease renewal and API queries
+const minPoolConns = 4
+
+// PoolSize fails when the pool is too small for the process or larger than the server allows
+func PoolSize(maxConns, serverMaxConnections int32) (string, error) {
+	detail := fmt.Sprintf("pool_max_conns=%d, server max_connections=%d", maxConns, serverMaxConnections)
+	if maxConns < minPoolConns {
+		return "", fmt.Errorf("%s: fewer than %d connections", detail, minPoolConns)
+	}
+	if maxConns >= serverMaxConnections {
+		return "", fmt.Errorf("%s: the pool can exhaust the server", detail)
+	}
+	return detail, nil
+}
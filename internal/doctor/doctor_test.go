@@ -0,0 +1,69 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunAndPrint(t *testing.T) {
+	checks := []Check{
+		{Name: "passes", Run: func(ctx context.Context) (string, error) { return "fine", nil }},
+		{Name: "fails", Hint: "fix it", Run: func(ctx context.Context) (string, error) { return "", errors.New("broken") }},
+		{Name: "skipped", Hint: "not shown", Run: func(ctx context.Context) (string, error) {
+			return "", fmt.Errorf("%w: depends on fails", ErrSkipped)
+		}},
+		{Name: "times out", Run: func(ctx context.Context) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		}},
+	}
+
+	var buf bytes.Buffer
+	failed := Print(&buf, Run(context.Background(), checks, 10*time.Millisecond))
+
+	if failed != 2 {
+		t.Errorf("failed = %d, want 2", failed)
+	}
+	want := []string{
+		"[ OK ] passes: fine",
+		"[FAIL] fails: broken",
+		"       hint: fix it",
+		"[SKIP] skipped: depends on fails",
+		"[FAIL] times out: context deadline exceeded",
+	}
+	if got := strings.Split(strings.TrimSpace(buf.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("output =\n%s\nwant\n%s", buf.String(), strings.Join(want, "\n"))
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	now := time.Now()
+	if _, err := ClockSkew(now, now.Add(2*time.Second), 5*time.Second); err != nil {
+		t.Errorf("2s skew: error = %v, want nil", err)
+	}
+	if _, err := ClockSkew(now, now.Add(-10*time.Second), 5*time.Second); err == nil {
+		t.Error("-10s skew: want error")
+	}
+}
+
+func TestPoolSize(t *testing.T) {
+	tests := []struct {
+		maxConns, serverMax int32
+		wantErr             bool
+	}{
+		{maxConns: 10, serverMax: 100},
+		{maxConns: 2, serverMax: 100, wantErr: true},
+		{maxConns: 100, serverMax: 100, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if _, err := PoolSize(tt.maxConns, tt.serverMax); (err != nil) != tt.wantErr {
+			t.Errorf("PoolSize(%d, %d) error = %v, wantErr %v", tt.maxConns, tt.serverMax, err, tt.wantErr)
+		}
+	}
+}
@@ -3,177 +3,485 @@ package indexer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
+	"github.com/broyeztony/delegated/internal/config"
 	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/metrics"
 	"github.com/broyeztony/delegated/internal/models"
+	"github.com/broyeztony/delegated/internal/telemetry"
+	"github.com/broyeztony/delegated/internal/tzkt"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type Indexer struct {
+// Indexer indexes the TzKT operations of a Source: Poll follows new operations, Backfill walks back
+// through older ones, both by id
+type Indexer[T Operation] struct {
+	source  Source[T]
 	pool    *pgxpool.Pool
 	cursor  int64
 	tzktURL string
+	headURL string
+	client  *http.Client
+
+	cyclesURL    string
+	protocolsURL string
+	// chainSyncedUntil is the last level of the cycle of the head when cycles and protocols were last
+	// synced: they are synced again once per cycle, upcoming cycles moving with protocol upgrades
+	chainSyncedUntil int32
+
+	// pollPageSize is the number of operations fetched per poll
+	pollPageSize     int
+	backfillPageSize int
+	backfillSleep    time.Duration
+
+	// syncedHead is the last TzKT head the live indexer fully caught up with
+	syncedHead *tzkt.Head
+
+	// saveState persists the indexer progress after each successful poll
+	saveState func(ctx context.Context, state db.IndexerState) error
+	// saveChain persists the cycles and protocols synced from TzKT, nil when the indexer does not sync them
+	saveChain func(ctx context.Context, protocols []models.Protocol, cycles []models.Cycle) error
+
+	// onCommit are called with the operations of each committed poll, ordered by id
+	onCommit []func(ops []T)
 }
 
-func NewIndexer(pool *pgxpool.Pool) *Indexer {
-
-	// https://api.tzkt.io/v1/operations/delegations
-	tzApiUrl := os.Getenv("TZ_API_URL")
+// New creates an indexer of source reading from the TzKT operations endpoint tzktURL,
+// e.g. https://api.tzkt.io/v1/operations/staking
+func New[T Operation](pool *pgxpool.Pool, source Source[T], tzktURL string, cfg config.Indexer) *Indexer[T] {
+	return &Indexer[T]{
+		source:           source,
+		pool:             pool,
+		tzktURL:          tzktURL,
+		headURL:          tzkt.HeadURL(tzktURL),
+		cyclesURL:        tzkt.CyclesURL(tzktURL),
+		protocolsURL:     tzkt.ProtocolsURL(tzktURL),
+		client:           &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)},
+		pollPageSize:     cfg.PollPageSize,
+		backfillPageSize: cfg.BackfillPageSize,
+		backfillSleep:    cfg.BackfillSleep,
+		saveState: func(ctx context.Context, state db.IndexerState) error {
+			return db.SaveIndexerState(ctx, pool, state)
+		},
+	}
+}
 
-	return &Indexer{
-		pool:    pool,
-		tzktURL: tzApiUrl,
+// NewIndexer creates the delegations indexer reading from the TzKT delegations endpoint tzktURL,
+// e.g. https://api.tzkt.io/v1/operations/delegations. It also keeps the cycles and protocols in sync.
+func NewIndexer(pool *pgxpool.Pool, tzktURL string, cfg config.Indexer) *Indexer[models.Delegation] {
+	i := New(pool, Delegations, tzktURL, cfg)
+	i.saveChain = func(ctx context.Context, protocols []models.Protocol, cycles []models.Cycle) error {
+		return db.SyncChain(ctx, pool, protocols, cycles)
 	}
+	return i
+}
+
+// NewStakingIndexer creates the staking operations indexer. tzktURL is the TzKT delegations
+// endpoint, the staking endpoint is its sibling.
+func NewStakingIndexer(pool *pgxpool.Pool, tzktURL string, cfg config.Indexer) *Indexer[models.StakingOp] {
+	return New(pool, Staking, tzkt.StakingURL(tzktURL), cfg)
+}
+
+// Name returns the name of the indexed source
+func (i *Indexer[T]) Name() string {
+	return i.source.Name
 }
 
-// Initialize sets up the cursor (latest TzKT delegation if table empty)
-func (i *Indexer) Initialize(ctx context.Context) error {
-	count, maxID, err := db.GetMaxID(ctx, i.pool)
+// OnCommit registers a function called with the operations of each committed poll, ordered by id.
+// Functions run in registration order on the polling goroutine and must not block.
+func (i *Indexer[T]) OnCommit(fn func(ops []T)) {
+	i.onCommit = append(i.onCommit, fn)
+}
+
+// Initialize sets up the cursor: the latest stored operation, else the latest TzKT operation
+// (stored right away), else 0 when TzKT has none yet
+func (i *Indexer[T]) Initialize(ctx context.Context) error {
+	count, maxID, err := i.source.MaxID(ctx, i.pool)
 	if err != nil {
 		return fmt.Errorf("failed to get max id: %w", err)
 	}
 
 	if count == 0 {
-		log.Println("Table is empty, fetching latest delegation from TzKT...")
-		latestDelegation, err := i.fetchLatestDelegation()
+		slog.InfoContext(ctx, "Table is empty, fetching latest operation from TzKT", "indexer", i.source.Name)
+		latest, err := i.fetchLatest(ctx)
 		if err != nil {
-			return fmt.Errorf("failed to fetch latest delegation: %w", err)
+			return fmt.Errorf("failed to fetch latest operation: %w", err)
 		}
-
-		// Insert the latest delegation into the database
-		if err := db.BulkInsertDelegations(ctx, i.pool, []models.Delegation{*latestDelegation}); err != nil {
-			return fmt.Errorf("failed to insert latest delegation: %w", err)
+		if latest == nil {
+			slog.InfoContext(ctx, "No operation on TzKT yet, indexing from the first one", "indexer", i.source.Name)
+			i.cursor = 0
+			metrics.Cursor.WithLabelValues(i.source.Name).Set(0)
+			return nil
 		}
-		log.Println("Inserted latest delegation into database")
 
+		// Insert the latest operation into the database
+		if err := i.source.Insert(ctx, i.pool, []T{*latest}); err != nil {
+			return fmt.Errorf("failed to insert latest operation: %w", err)
+		}
 		// Update cursor only after successful insert
-		i.cursor = latestDelegation.ID
-		log.Printf("Latest delegation id: %d\n", i.cursor)
+		i.cursor = (*latest).OperationID()
+		slog.InfoContext(ctx, "Inserted latest operation into database", "indexer", i.source.Name, "cursor", i.cursor)
 	} else {
-		log.Printf("Resuming from id: %d\n", maxID)
+		slog.InfoContext(ctx, "Resuming from latest indexed operation", "indexer", i.source.Name, "cursor", maxID)
 		i.cursor = maxID
 	}
+	metrics.Cursor.WithLabelValues(i.source.Name).Set(float64(i.cursor))
 
 	return nil
 }
 
-// fetchDelegations fetches delegations from TzKT with given query parameters
-func (i *Indexer) fetchDelegations(queryParams string) ([]models.Delegation, error) {
-	response, err := http.Get(i.tzktURL + queryParams)
+// OldestID returns the number of stored operations and the lowest id, where a backfill starts
+func (i *Indexer[T]) OldestID(ctx context.Context) (count, minID int64, err error) {
+	return i.source.MinID(ctx, i.pool)
+}
+
+// maxFetchAttempts is the number of times a request is tried when TzKT answers 429 or 5xx
+const maxFetchAttempts = 3
+
+// fetch fetches operations from TzKT with given query parameters, followed by the source query
+func (i *Indexer[T]) fetch(ctx context.Context, queryParams string) ([]T, error) {
+	start := time.Now()
+	defer func() {
+		metrics.FetchDuration.WithLabelValues(i.source.Name).Observe(time.Since(start).Seconds())
+	}()
+
+	var body []byte
+	for attempt := 1; ; attempt++ {
+		status, b, err := i.get(ctx, i.tzktURL+queryParams+i.source.Query)
+		if err != nil {
+			return nil, err
+		}
+
+		if status == http.StatusOK {
+			body = b
+			break
+		}
+
+		retryable := status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+		if !retryable || attempt == maxFetchAttempts {
+			return nil, fmt.Errorf("unexpected status code %d: %s", status, b)
+		}
+
+		backoff := time.Duration(attempt) * 500 * time.Millisecond
+		slog.WarnContext(ctx, "TzKT request failed, retrying", "indexer", i.source.Name,
+			"status", status, "backoff", backoff, "attempt", attempt, "max_attempts", maxFetchAttempts)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	_, span := telemetry.Tracer().Start(ctx, "tzkt.decode", trace.WithAttributes(attribute.Int("http.response.body.size", len(body))))
+	defer span.End()
+
+	var ops []T
+	err := json.Unmarshal(body, &ops)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	span.SetAttributes(attribute.Int(i.source.Name+".count", len(ops)))
+
+	return ops, nil
+}
+
+// get performs a single GET request against TzKT and returns the status code and body
+func (i *Indexer[T]) get(ctx context.Context, url string) (int, []byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch delegations: %w", err)
+		return 0, nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	defer response.Body.Close()
 
-	body, err := io.ReadAll(response.Body)
+	response, err := i.client.Do(request)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return 0, nil, fmt.Errorf("failed to fetch %s: %w", url, err)
 	}
+	defer response.Body.Close()
 
-	var delegations []models.Delegation
-	err = json.Unmarshal(body, &delegations)
+	body, err := io.ReadAll(response.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		return 0, nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	return delegations, nil
+	return response.StatusCode, body, nil
 }
 
-// fetchLatestDelegation fetches the most recent delegation from TzKT
-func (i *Indexer) fetchLatestDelegation() (*models.Delegation, error) {
-	delegations, err := i.fetchDelegations("?limit=1&sort.desc=id")
+// fetchLatest fetches the most recent operation from TzKT, nil when there is none
+func (i *Indexer[T]) fetchLatest(ctx context.Context) (*T, error) {
+	ops, err := i.fetch(ctx, "?limit=1&sort.desc=id")
 	if err != nil {
 		return nil, err
 	}
 
-	if len(delegations) == 0 {
-		return nil, fmt.Errorf("no delegations found")
+	if len(ops) == 0 {
+		return nil, nil
 	}
 
-	return &delegations[0], nil
+	return &ops[0], nil
 }
 
-func (i *Indexer) fetchNewDelegations(ctx context.Context, cursor int64) ([]models.Delegation, error) {
-	query := "?id.gt=" + strconv.FormatInt(cursor, 10) + "&limit=100&sort.asc=id"
-	return i.fetchDelegations(query)
+// fetchNew fetches the page of operations following cursor, oldest first
+func (i *Indexer[T]) fetchNew(ctx context.Context, cursor int64) ([]T, error) {
+	query := "?id.gt=" + strconv.FormatInt(cursor, 10) + "&limit=" + strconv.Itoa(i.pollPageSize) + "&sort.asc=id"
+	return i.fetch(ctx, query)
 }
 
-// FetchNewDelegations is a public method for fetching delegations with a specific cursor (used by backfill)
-func (i *Indexer) FetchNewDelegations(ctx context.Context, cursor int64) ([]models.Delegation, error) {
+// fetchOlder fetches the page of operations preceding cursor, most recent first (used by backfill)
+func (i *Indexer[T]) fetchOlder(ctx context.Context, cursor int64) ([]T, error) {
 	// Use id.lt to go backward in time (older records have smaller IDs)
 	// Sort desc to get the most recent records within the range
-	query := "?id.lt=" + strconv.FormatInt(cursor, 10) + "&limit=10000&sort.desc=id"
-	return i.fetchDelegations(query)
+	query := "?id.lt=" + strconv.FormatInt(cursor, 10) + "&limit=" + strconv.Itoa(i.backfillPageSize) + "&sort.desc=id"
+	return i.fetch(ctx, query)
 }
 
-// Backfill fetches historical delegations going backward from the given cursor and inserts directly into delegations using COPY protocol
-func (i *Indexer) Backfill(ctx context.Context, startCursor int64) (totalRecords int, totalBatches int, err error) {
+// Backfill fetches historical operations going backward from the given cursor and inserts them using COPY protocol
+func (i *Indexer[T]) Backfill(ctx context.Context, startCursor int64) (totalRecords int, totalBatches int, err error) {
 	cursor := startCursor
 
 	for {
-		log.Printf("Fetching batch %d (cursor: %d)\n", totalBatches+1, cursor)
+		slog.DebugContext(ctx, "Fetching batch", "indexer", i.source.Name, "batch", totalBatches+1, "cursor", cursor)
 
-		delegations, err := i.FetchNewDelegations(ctx, cursor)
+		ops, err := i.fetchOlder(ctx, cursor)
 		if err != nil {
 			return totalRecords, totalBatches, fmt.Errorf("failed to fetch: %w", err)
 		}
 
-		if len(delegations) == 0 {
-			log.Println("No more delegations found. Backfill complete!")
+		if len(ops) == 0 {
+			slog.InfoContext(ctx, "No more operations found, backfill complete", "indexer", i.source.Name)
 			break
 		}
+		metrics.FetchedRows.WithLabelValues(i.source.Name, "backfill").Add(float64(len(ops)))
 
 		insertStart := time.Now()
-		if err := db.CopyInsertDelegations(ctx, i.pool, delegations); err != nil {
+		if err := i.source.Copy(ctx, i.pool, ops); err != nil {
 			return totalRecords, totalBatches, fmt.Errorf("failed to copy: %w", err)
 		}
 		insertDuration := time.Since(insertStart)
+		metrics.InsertDuration.WithLabelValues(i.source.Name, "backfill").Observe(insertDuration.Seconds())
+		metrics.InsertedRows.WithLabelValues(i.source.Name, "backfill").Add(float64(len(ops)))
 
 		totalBatches++
-		totalRecords += len(delegations)
-		log.Printf("Inserted %d records in %v (total: %d records in %d batches)\n",
-			len(delegations), insertDuration, totalRecords, totalBatches)
+		totalRecords += len(ops)
+		slog.InfoContext(ctx, "Inserted batch", "indexer", i.source.Name,
+			"batch", totalBatches, "cursor", cursor, "rows", len(ops), "duration", insertDuration, "total_rows", totalRecords)
 
-		cursor = delegations[len(delegations)-1].ID
+		cursor = ops[len(ops)-1].OperationID()
 
-		time.Sleep(100 * time.Millisecond)
+		select {
+		case <-time.After(i.backfillSleep):
+		case <-ctx.Done():
+			slog.WarnContext(ctx, "Backfill interrupted", "indexer", i.source.Name, "cursor", cursor, "total_rows", totalRecords)
+			return totalRecords, totalBatches, ctx.Err()
+		}
 	}
 
 	return totalRecords, totalBatches, nil
 }
 
-// Poll fetches new delegations from TzKT and inserts them into the database
-func (i *Indexer) Poll(ctx context.Context) error {
-	log.Println("Polling for new delegations...")
+// Run polls for new operations every interval until ctx is cancelled.
+// A poll in flight when ctx is cancelled gets up to shutdownTimeout to finish;
+// past that it is aborted and its transaction rolls back. It is aborted at once
+// when ctx is cancelled because the indexer lease was lost (cause db.ErrLeaseLost).
+func (i *Indexer[T]) Run(ctx context.Context, interval, shutdownTimeout time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-	newDelegations, err := i.fetchNewDelegations(ctx, i.cursor)
-	if err != nil {
-		return fmt.Errorf("failed to fetch new delegations: %w", err)
+	for {
+		if err := i.pollWithShutdownTimeout(ctx, shutdownTimeout); err != nil {
+			slog.ErrorContext(ctx, "Error polling", "indexer", i.source.Name, "cursor", i.cursor, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.InfoContext(ctx, "Indexer stopped", "indexer", i.source.Name, "cursor", i.cursor)
+			return nil
+		case <-ticker.C:
+		}
 	}
+}
 
-	if len(newDelegations) == 0 {
-		log.Println("No new delegations found")
+// pollWithShutdownTimeout runs Poll on a context that outlives ctx by at most shutdownTimeout
+func (i *Indexer[T]) pollWithShutdownTimeout(ctx context.Context, shutdownTimeout time.Duration) error {
+	if ctx.Err() != nil {
 		return nil
 	}
 
-	log.Println("Found", len(newDelegations), "new delegations")
+	pollCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+		}
+
+		// Another replica may already be polling: stop writing at once
+		if errors.Is(context.Cause(ctx), db.ErrLeaseLost) {
+			slog.Warn("Lease lost, aborting in-flight poll", "indexer", i.source.Name)
+			cancel()
+			return
+		}
 
-	if err := db.BulkInsertDelegations(ctx, i.pool, newDelegations); err != nil {
-		return fmt.Errorf("failed to insert new delegations: %w", err)
+		slog.Info("Shutdown requested, waiting for in-flight poll", "indexer", i.source.Name, "timeout", shutdownTimeout)
+		select {
+		case <-done:
+		case <-time.After(shutdownTimeout):
+			slog.Warn("Shutdown timeout reached, aborting in-flight poll", "indexer", i.source.Name, "timeout", shutdownTimeout)
+			cancel()
+		}
+	}()
+
+	return i.Poll(pollCtx)
+}
+
+// Poll fetches new operations from TzKT and inserts them into the database
+func (i *Indexer[T]) Poll(ctx context.Context) (err error) {
+	slog.DebugContext(ctx, "Polling for new operations", "indexer", i.source.Name, "cursor", i.cursor)
+	start := time.Now()
+
+	ctx, span := telemetry.Tracer().Start(ctx, "Indexer.Poll", trace.WithAttributes(
+		attribute.String("indexer.name", i.source.Name), attribute.Int64("indexer.cursor", i.cursor)))
+	defer span.End()
+
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			metrics.Polls.WithLabelValues(i.source.Name, "error").Inc()
+			return
+		}
+		metrics.Polls.WithLabelValues(i.source.Name, "success").Inc()
+		metrics.LastSuccessfulPoll.WithLabelValues(i.source.Name).SetToCurrentTime()
+	}()
+
+	// The head is read before fetching: if this poll drains the backlog, we are synced up to it
+	head, headErr := tzkt.FetchHead(ctx, i.client, i.headURL)
+	if headErr != nil {
+		slog.WarnContext(ctx, "Failed to fetch TzKT head", "indexer", i.source.Name, "error", headErr)
+	}
+
+	if headErr == nil {
+		// Measured whatever the outcome of the poll, so that lag keeps growing while polls fail
+		defer i.recordLag(head)
 	}
 
-	log.Println("Inserted", len(newDelegations), "new delegations into database")
+	ops, err := i.fetchNew(ctx, i.cursor)
+	if err != nil {
+		return fmt.Errorf("failed to fetch new operations: %w", err)
+	}
+	metrics.FetchedRows.WithLabelValues(i.source.Name, "poll").Add(float64(len(ops)))
+	span.SetAttributes(attribute.Int(i.source.Name+".count", len(ops)))
+
+	if headErr == nil && i.saveChain != nil && head.Level > i.chainSyncedUntil {
+		if err := i.SyncChain(ctx, head.Level); err != nil {
+			slog.WarnContext(ctx, "Failed to sync cycles and protocols", "error", err)
+		}
+	}
+
+	if len(ops) == 0 {
+		slog.DebugContext(ctx, "No new operations found", "indexer", i.source.Name, "cursor", i.cursor)
+		if headErr == nil {
+			i.syncedHead = head
+		}
+		return i.persistState(ctx, false)
+	}
+
+	insertStart := time.Now()
+	if err := i.source.Insert(ctx, i.pool, ops); err != nil {
+		return fmt.Errorf("failed to insert new operations: %w", err)
+	}
+	// Caught up with the head only once its operations are stored
+	if headErr == nil && len(ops) < i.pollPageSize {
+		i.syncedHead = head
+	}
+	metrics.InsertDuration.WithLabelValues(i.source.Name, "poll").Observe(time.Since(insertStart).Seconds())
+	metrics.InsertedRows.WithLabelValues(i.source.Name, "poll").Add(float64(len(ops)))
 
 	// Update cursor only after successful insert
-	i.cursor = newDelegations[len(newDelegations)-1].ID
-	log.Printf("Updated cursor to: %d\n", i.cursor)
+	i.cursor = ops[len(ops)-1].OperationID()
+	metrics.Cursor.WithLabelValues(i.source.Name).Set(float64(i.cursor))
+	slog.InfoContext(ctx, "Inserted new operations", "indexer", i.source.Name, "cursor", i.cursor, "rows", len(ops), "duration", time.Since(start))
+
+	for _, fn := range i.onCommit {
+		fn(ops)
+	}
+
+	return i.persistState(ctx, true)
+}
 
+// SyncChain copies the cycles and protocols of TzKT into the database, for the attribution of
+// delegations to cycles and protocols. level is the current head level. It does nothing for
+// indexers that do not sync them.
+func (i *Indexer[T]) SyncChain(ctx context.Context, level int32) error {
+	if i.saveChain == nil {
+		return nil
+	}
+
+	protocols, err := tzkt.FetchProtocols(ctx, i.client, i.protocolsURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch protocols: %w", err)
+	}
+	cycles, err := tzkt.FetchCycles(ctx, i.client, i.cyclesURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch cycles: %w", err)
+	}
+
+	if err := i.saveChain(ctx, protocols, cycles); err != nil {
+		return err
+	}
+
+	i.chainSyncedUntil = level
+	for _, c := range cycles {
+		if c.FirstLevel <= level && level <= c.LastLevel {
+			i.chainSyncedUntil = c.LastLevel
+			break
+		}
+	}
+	slog.InfoContext(ctx, "Synced cycles and protocols", "cycles", len(cycles), "protocols", len(protocols), "until_level", i.chainSyncedUntil)
+	return nil
+}
+
+// persistState records the cursor and synced head so that other processes (API, status) can report freshness
+func (i *Indexer[T]) persistState(ctx context.Context, ingested bool) error {
+	state := db.IndexerState{
+		Name:     i.source.Name,
+		Cursor:   i.cursor,
+		Ingested: ingested,
+	}
+	if i.syncedHead != nil {
+		state.SyncedLevel = &i.syncedHead.Level
+		state.SyncedTimestamp = &i.syncedHead.Timestamp
+	}
+
+	if err := i.saveState(ctx, state); err != nil {
+		return fmt.Errorf("failed to save indexer state: %w", err)
+	}
 	return nil
 }
+
+// recordLag exports how far the last head we caught up with is behind the current head
+func (i *Indexer[T]) recordLag(head *tzkt.Head) {
+	if i.syncedHead == nil {
+		return
+	}
+
+	metrics.LagLevels.WithLabelValues(i.source.Name).Set(float64(head.Level - i.syncedHead.Level))
+	metrics.LagSeconds.WithLabelValues(i.source.Name).Set(head.Timestamp.Sub(i.syncedHead.Timestamp).Seconds())
+}
@@ -0,0 +1,204 @@
+package indexer
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/config"
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/dbtest"
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/broyeztony/delegated/internal/tzktmock"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// These tests run the indexer end to end against the mock TzKT server and a
+// real PostgreSQL database. They are skipped unless TEST_DB_URL points to a
+// disposable database: schema.sql is reloaded (dropping all data) before each test.
+
+func setupIntegration(t *testing.T, opts tzktmock.Options) (*pgxpool.Pool, string, *tzktmock.Server) {
+	t.Helper()
+
+	pool := dbtest.Pool(t)
+
+	mock := tzktmock.New(tzktmock.Synthetic(250, time.Now(), 7), opts)
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+	return pool, srv.URL + "/v1/operations/delegations", mock
+}
+
+func TestIntegration_InitializeAndPoll(t *testing.T) {
+	pool, tzktURL, mock := setupIntegration(t, tzktmock.Options{})
+	ctx := context.Background()
+
+	idx := NewIndexer(pool, tzktURL, config.Default().Indexer)
+	var committed []models.Delegation
+	idx.OnCommit(func(delegations []models.Delegation) {
+		committed = append(committed, delegations...)
+	})
+	if err := idx.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+
+	last, _ := mock.Last()
+	if idx.cursor != last.ID {
+		t.Fatalf("cursor = %d, want %d", idx.cursor, last.ID)
+	}
+
+	// Nothing new yet
+	if err := idx.Poll(ctx); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+
+	// Simulate three new blocks
+	next := tzktmock.Synthetic(3, time.Now(), 8)
+	for i := range next {
+		next[i].ID = last.ID + int64(i) + 1
+	}
+	mock.Append(next...)
+
+	if err := idx.Poll(ctx); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+
+	count, maxID, err := db.GetMaxID(ctx, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 || maxID != next[2].ID {
+		t.Errorf("count, maxID = %d, %d, want 4, %d", count, maxID, next[2].ID)
+	}
+	if idx.cursor != next[2].ID {
+		t.Errorf("cursor = %d, want %d", idx.cursor, next[2].ID)
+	}
+	if len(committed) != 3 || committed[0].ID != next[0].ID {
+		t.Errorf("OnCommit received %d delegations, want the 3 new ones", len(committed))
+	}
+
+	state, err := db.GetIndexerState(ctx, pool, db.DelegationsIndexer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state == nil || state.Cursor != next[2].ID || state.LastIngestAt == nil || state.SyncedLevel == nil {
+		t.Errorf("indexer state = %+v, want cursor %d with ingest time and synced level", state, next[2].ID)
+	}
+}
+
+func TestIntegration_Backfill(t *testing.T) {
+	pool, tzktURL, mock := setupIntegration(t, tzktmock.Options{ErrorRate: 0.2, Seed: 3})
+	ctx := context.Background()
+
+	idx := NewIndexer(pool, tzktURL, config.Default().Indexer)
+	if err := idx.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+
+	_, minID, err := db.GetMinID(ctx, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	totalRecords, _, err := idx.Backfill(ctx, minID)
+	if err != nil {
+		t.Fatalf("Backfill() error = %v", err)
+	}
+	if totalRecords != mock.Len()-1 {
+		t.Errorf("totalRecords = %d, want %d", totalRecords, mock.Len()-1)
+	}
+
+	count, _, err := db.GetMaxID(ctx, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(count) != mock.Len() {
+		t.Errorf("count = %d, want %d", count, mock.Len())
+	}
+}
+
+func TestIntegration_FailedRedelegation(t *testing.T) {
+	pool, tzktURL, mock := setupIntegration(t, tzktmock.Options{})
+	ctx := context.Background()
+
+	idx := NewIndexer(pool, tzktURL, config.Default().Indexer)
+	if err := idx.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize() error = %v", err)
+	}
+
+	const (
+		alice  = "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo"
+		baker1 = "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM"
+		baker2 = "tz3RDC3Jdn4j15J7bBHZd29EUee9gVB1CxD9"
+	)
+	last, _ := mock.Last()
+	joined := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	mock.Append(models.Delegation{ID: last.ID + 1, Delegator: alice, Baker: baker1, Timestamp: joined, Amount: 100, Level: last.Level + 1})
+	// alice's redelegation to baker2 failed: she still delegates to baker1
+	mock.AppendFailed(models.Delegation{ID: last.ID + 2, Delegator: alice, Baker: baker2, PrevBaker: baker1, Timestamp: joined.Add(time.Minute), Amount: 100, Level: last.Level + 2})
+
+	if err := idx.Poll(ctx); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+
+	bakers, err := db.ListBakers(ctx, pool, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []db.Baker
+	for _, b := range bakers {
+		if b.Address == baker1 || b.Address == baker2 {
+			got = append(got, b)
+		}
+	}
+	if want := (db.Baker{Address: baker1, Delegators: 1, DelegatedAmount: 100}); len(got) != 1 || got[0] != want {
+		t.Errorf("ListBakers() = %+v, want only %+v", got, want)
+	}
+
+	delegators, err := db.ListBakerDelegators(ctx, pool, baker1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(delegators) != 1 || delegators[0].Address != alice {
+		t.Errorf("ListBakerDelegators(baker1) = %+v, want alice", delegators)
+	}
+
+	history, err := db.GetBakerHistory(ctx, pool, baker1, db.IntervalDay, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Joins != 1 || history[0].Leaves != 0 || history[0].CumulativeNetAmount != 100 {
+		t.Errorf("GetBakerHistory(baker1) = %+v, want a single join of 100", history)
+	}
+
+	// The failed redelegation is not counted in the daily rollups
+	stats, err := db.GetDelegationStats(ctx, pool, db.StatsQuery{Interval: db.IntervalDay, GroupByBaker: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var counted []db.StatsBucket
+	for _, s := range stats {
+		if s.Baker == baker1 || s.Baker == baker2 {
+			counted = append(counted, s)
+		}
+	}
+	if len(counted) != 1 || counted[0].Baker != baker1 || counted[0].Count != 1 || counted[0].TotalAmount != 100 {
+		t.Errorf("GetDelegationStats() = %+v, want a single delegation of 100 to baker1", counted)
+	}
+
+	// After the block of the failed redelegation, alice still delegated to baker1
+	at := db.PointInTime{Level: last.Level + 2}
+	delegation, err := db.GetDelegationAt(ctx, pool, alice, at)
+	if err != nil || delegation == nil || delegation.Baker != baker1 || delegation.DelegationID != last.ID+1 {
+		t.Errorf("GetDelegationAt(alice) = %+v, %v, want delegation %d to baker1", delegation, err, last.ID+1)
+	}
+	for baker, want := range map[string]int{baker1: 1, baker2: 0} {
+		past, err := db.ListBakerDelegatorsAt(ctx, pool, baker, at, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(past) != want {
+			t.Errorf("ListBakerDelegatorsAt(%s) = %+v, want %d delegators", baker, past, want)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/config"
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/metrics"
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/broyeztony/delegated/internal/tzkt"
+	"github.com/broyeztony/delegated/internal/tzktmock"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// newMockIndexer returns an indexer pointed at a mock TzKT with no delegations
+// newer than its cursor and no-op state and chain stores, so Poll never touches the database
+func newMockIndexer(t *testing.T, opts tzktmock.Options) *Indexer[models.Delegation] {
+	t.Helper()
+
+	mock := tzktmock.New(tzktmock.Synthetic(10, time.Now(), 1), opts)
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+	idx := NewIndexer(nil, srv.URL+"/v1/operations/delegations", config.Default().Indexer)
+	idx.saveState = func(context.Context, db.IndexerState) error { return nil }
+	idx.saveChain = func(context.Context, []models.Protocol, []models.Cycle) error { return nil }
+	last, _ := mock.Last()
+	idx.cursor = last.ID
+	return idx
+}
+
+func TestRun_ShutdownWaitsForInFlightPoll(t *testing.T) {
+	idx := newMockIndexer(t, tzktmock.Options{Latency: 300 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	if err := idx.Run(ctx, time.Hour, 5*time.Second); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// The in-flight poll was allowed to finish
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("Run() returned after %v, before the in-flight poll completed", elapsed)
+	}
+}
+
+func TestRun_ShutdownTimeoutAbortsPoll(t *testing.T) {
+	idx := newMockIndexer(t, tzktmock.Options{Latency: 5 * time.Second})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	if err := idx.Run(ctx, time.Hour, 100*time.Millisecond); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Run() returned after %v, want the poll aborted after the shutdown timeout", elapsed)
+	}
+}
+
+func TestRun_LeaseLostAbortsPoll(t *testing.T) {
+	idx := newMockIndexer(t, tzktmock.Options{Latency: 5 * time.Second})
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	time.AfterFunc(50*time.Millisecond, func() { cancel(db.ErrLeaseLost) })
+
+	start := time.Now()
+	if err := idx.Run(ctx, time.Hour, 30*time.Second); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Run() returned after %v, want the poll aborted as soon as the lease is lost", elapsed)
+	}
+}
+
+func TestFetchDelegations_RetriesTransientErrors(t *testing.T) {
+	// With this seed the first request fails with 500 and the retry succeeds
+	idx := newMockIndexer(t, tzktmock.Options{ErrorRate: 0.5, Seed: 6})
+
+	if _, err := idx.fetchLatest(context.Background()); err != nil {
+		t.Fatalf("fetchLatest() error = %v", err)
+	}
+}
+
+func TestFetchDelegations_CancelledContext(t *testing.T) {
+	idx := newMockIndexer(t, tzktmock.Options{Latency: 5 * time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := idx.fetchLatest(ctx); err == nil {
+		t.Fatal("fetchLatest() error = nil, want context error")
+	}
+}
+
+func TestPoll_RecordsSyncedHead(t *testing.T) {
+	idx := newMockIndexer(t, tzktmock.Options{})
+
+	if err := idx.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+
+	if idx.syncedHead == nil || idx.syncedHead.Level == 0 {
+		t.Fatalf("syncedHead = %+v, want the mock head", idx.syncedHead)
+	}
+	if got := testutil.ToFloat64(metrics.LagLevels.WithLabelValues(db.DelegationsIndexer)); got != 0 {
+		t.Errorf("lag_levels = %v, want 0", got)
+	}
+}
+
+func TestPoll_InsertFailureKeepsLag(t *testing.T) {
+	idx := newMockIndexer(t, tzktmock.Options{})
+	idx.source.Insert = func(context.Context, *pgxpool.Pool, []models.Delegation) error {
+		return errors.New("database unavailable")
+	}
+	// Behind the mock head (the last of the synthetic delegations, at level 100009) by 5 levels
+	synced := &tzkt.Head{Level: 100004, Timestamp: time.Now().Add(-time.Minute)}
+	idx.syncedHead = synced
+	idx.cursor = 0
+
+	if err := idx.Poll(context.Background()); err == nil {
+		t.Fatal("Poll() error = nil, want the insert error")
+	}
+
+	if idx.syncedHead != synced {
+		t.Errorf("syncedHead = %+v, want unchanged after a failed insert", idx.syncedHead)
+	}
+	if got := testutil.ToFloat64(metrics.LagLevels.WithLabelValues(db.DelegationsIndexer)); got != 5 {
+		t.Errorf("lag_levels = %v, want 5", got)
+	}
+}
+
+func TestPoll_FetchesAppliedDelegations(t *testing.T) {
+	delegations := tzktmock.Synthetic(10, time.Now(), 1)
+	mock := tzktmock.New(append(delegations[:8:8], delegations[9]), tzktmock.Options{})
+	// A failed delegation did not change the delegate
+	mock.AppendFailed(delegations[8])
+	var statuses []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/operations/delegations" {
+			statuses = append(statuses, r.URL.Query().Get("status"))
+		}
+		mock.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	idx := NewIndexer(nil, srv.URL+"/v1/operations/delegations", config.Default().Indexer)
+	var inserted []models.Delegation
+	idx.source.Insert = func(_ context.Context, _ *pgxpool.Pool, batch []models.Delegation) error {
+		inserted = append(inserted, batch...)
+		return nil
+	}
+	idx.saveState = func(context.Context, db.IndexerState) error { return nil }
+	idx.saveChain = func(context.Context, []models.Protocol, []models.Cycle) error { return nil }
+	idx.cursor = delegations[6].ID
+
+	if err := idx.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+
+	if len(statuses) != 1 || statuses[0] != "applied" {
+		t.Errorf("delegations requests with status %q, want a single one with applied", statuses)
+	}
+	if len(inserted) != 2 || inserted[0] != delegations[7] || inserted[1] != delegations[9] {
+		t.Errorf("inserted = %+v, want the 2 applied delegations after the cursor", inserted)
+	}
+}
+
+func TestPoll_Spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	idx := newMockIndexer(t, tzktmock.Options{})
+	if err := idx.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+
+	spans := recorder.Ended()
+	var root trace.SpanContext
+	for _, s := range spans {
+		if s.Name() == "Indexer.Poll" {
+			root = s.SpanContext()
+		}
+	}
+	if !root.IsValid() {
+		t.Fatal("no Indexer.Poll span recorded")
+	}
+
+	// TzKT requests and JSON decoding are children of the poll span
+	var decode, httpGet int
+	for _, s := range spans {
+		if s.Parent().SpanID() != root.SpanID() {
+			continue
+		}
+		switch s.Name() {
+		case "tzkt.decode":
+			decode++
+		case "HTTP GET":
+			httpGet++
+		}
+	}
+	// The first poll also syncs the protocols and cycles
+	if decode != 1 || httpGet != 4 {
+		t.Errorf("got %d decode and %d HTTP GET child spans, want 1 and 4 (head, protocols, cycles and delegations)", decode, httpGet)
+	}
+}
+
+func TestPoll_SyncsChainOncePerCycle(t *testing.T) {
+	idx := newMockIndexer(t, tzktmock.Options{})
+	var syncs int
+	var synced []models.Cycle
+	idx.saveChain = func(_ context.Context, protocols []models.Protocol, cycles []models.Cycle) error {
+		syncs++
+		synced = cycles
+		if len(protocols) != 1 {
+			t.Errorf("got %d protocols, want 1", len(protocols))
+		}
+		return nil
+	}
+
+	for range 2 {
+		if err := idx.Poll(context.Background()); err != nil {
+			t.Fatalf("Poll() error = %v", err)
+		}
+	}
+	if syncs != 1 {
+		t.Fatalf("synced %d times, want once: the head did not leave its cycle", syncs)
+	}
+
+	head := idx.syncedHead.Level
+	last := synced[len(synced)-1]
+	if idx.chainSyncedUntil < head || idx.chainSyncedUntil-head >= tzktmock.BlocksPerCycle || idx.chainSyncedUntil >= last.LastLevel {
+		t.Errorf("chainSyncedUntil = %d, want the end of the cycle of head %d", idx.chainSyncedUntil, head)
+	}
+}
+
+func TestPoll_Staking(t *testing.T) {
+	ops := tzktmock.SyntheticStaking(10, time.Now(), 1)
+	mock := tzktmock.New(tzktmock.Synthetic(10, time.Now(), 1), tzktmock.Options{})
+	mock.AppendStaking(ops...)
+	var statuses []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/operations/staking" {
+			statuses = append(statuses, r.URL.Query().Get("status"))
+		}
+		mock.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	// The staking indexer derives its endpoint from the delegations one
+	idx := NewStakingIndexer(nil, srv.URL+"/v1/operations/delegations", config.Default().Indexer)
+	var inserted []models.StakingOp
+	idx.source.Insert = func(_ context.Context, _ *pgxpool.Pool, batch []models.StakingOp) error {
+		inserted = append(inserted, batch...)
+		return nil
+	}
+	var state db.IndexerState
+	idx.saveState = func(_ context.Context, s db.IndexerState) error {
+		state = s
+		return nil
+	}
+	idx.cursor = ops[5].ID
+
+	if err := idx.Poll(context.Background()); err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+
+	// Failed operations are left out
+	if len(statuses) != 1 || statuses[0] != "applied" {
+		t.Errorf("staking requests with status %q, want a single one with applied", statuses)
+	}
+	if len(inserted) != 4 || inserted[0] != ops[6] || inserted[3] != ops[9] {
+		t.Errorf("inserted = %+v, want the 4 operations after the cursor", inserted)
+	}
+	if idx.cursor != ops[9].ID {
+		t.Errorf("cursor = %d, want %d", idx.cursor, ops[9].ID)
+	}
+	if state.Name != db.StakingIndexer || state.Cursor != ops[9].ID || !state.Ingested {
+		t.Errorf("saved state = %+v, want the staking cursor %d", state, ops[9].ID)
+	}
+	if idx.chainSyncedUntil != 0 {
+		t.Errorf("chainSyncedUntil = %d, want no chain sync from the staking indexer", idx.chainSyncedUntil)
+	}
+}
@@ -0,0 +1,52 @@
+package indexer
+
+import (
+	"context"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Operation is a TzKT operation, indexed in the order of its id
+type Operation interface {
+	OperationID() int64
+}
+
+// Source describes a kind of TzKT operation to the Indexer: how it is named and stored
+type Source[T Operation] struct {
+	// Name is the indexer_state row of the live indexer, and labels its logs and metrics
+	Name string
+	// Query is appended to the query of every TzKT request, e.g. to leave out failed operations
+	Query string
+	// Insert writes the operations of a poll, skipping those already stored
+	Insert func(ctx context.Context, pool *pgxpool.Pool, ops []T) error
+	// Copy writes the operations of a backfill page, none of them being stored yet
+	Copy func(ctx context.Context, pool *pgxpool.Pool, ops []T) error
+	// MaxID and MinID return the number of stored operations and their highest or lowest id
+	MaxID func(ctx context.Context, pool *pgxpool.Pool) (count, maxID int64, err error)
+	MinID func(ctx context.Context, pool *pgxpool.Pool) (count, minID int64, err error)
+}
+
+// Delegations stores the applied delegations in the delegations table. Failed, backtracked
+// and skipped ones did not change the delegate, so they must not reach the current state,
+// rollups or baker history derived from the table.
+var Delegations = Source[models.Delegation]{
+	Name:   db.DelegationsIndexer,
+	Query:  "&status=applied",
+	Insert: db.BulkInsertDelegations,
+	Copy:   db.CopyInsertDelegations,
+	MaxID:  db.GetMaxID,
+	MinID:  db.GetMinID,
+}
+
+// Staking stores the applied staking operations in the staking_ops table. Failed ones
+// (e.g. staking more than the balance) moved no tez and are not fetched.
+var Staking = Source[models.StakingOp]{
+	Name:   db.StakingIndexer,
+	Query:  "&status=applied",
+	Insert: db.BulkInsertStakingOps,
+	Copy:   db.CopyInsertStakingOps,
+	MaxID:  db.GetStakingMaxID,
+	MinID:  db.GetStakingMinID,
+}
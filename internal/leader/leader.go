@@ -0,0 +1,121 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// IndexerLease is the name of the lease held by the polling indexer replica
+const IndexerLease = "indexer"
+
+// Elector runs a function only while it holds a lease row in the database.
+// The leader renews the lease every ttl/3; standbys try to take it over at the
+// same pace, so a dead leader is replaced within about ttl + ttl/3.
+type Elector struct {
+	pool   *pgxpool.Pool
+	name   string
+	holder string
+	ttl    time.Duration
+}
+
+// New creates an elector competing for the named lease as holder
+func New(pool *pgxpool.Pool, name, holder string, ttl time.Duration) *Elector {
+	return &Elector{
+		pool:   pool,
+		name:   name,
+		holder: holder,
+		ttl:    ttl,
+	}
+}
+
+// DefaultHolder identifies this process as hostname-pid
+func DefaultHolder() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// Run blocks until ctx is cancelled. Whenever this instance becomes leader it calls lead
+// with a context fenced on the lease (see db.WithLease), cancelled with cause db.ErrLeaseLost
+// as soon as leadership is lost. When ctx is cancelled, the lease is still renewed until
+// lead returns, so in-flight writes can finish, then released so a standby can take over immediately.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context) error) error {
+	interval := e.ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		acquired, err := db.TryAcquireLease(ctx, e.pool, e.name, e.holder, e.ttl)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Failed to acquire lease", "lease", e.name, "error", err)
+		}
+
+		if acquired {
+			slog.InfoContext(ctx, "Acquired lease, now leader", "lease", e.name, "holder", e.holder)
+			if err := e.lead(ctx, interval, lead); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// lead runs fn while renewing the lease, and releases the lease once fn returns
+func (e *Elector) lead(ctx context.Context, interval time.Duration, fn func(ctx context.Context) error) error {
+	leaderCtx, cancel := context.WithCancelCause(db.WithLease(ctx, e.name, e.holder))
+	defer cancel(nil)
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- fn(leaderCtx)
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Renewals outlive ctx: on shutdown fn may still be writing until it returns
+	renewCtx := context.WithoutCancel(ctx)
+
+	var err error
+loop:
+	for {
+		select {
+		case err = <-errChan:
+			break loop
+		case <-ticker.C:
+			attemptCtx, attemptCancel := context.WithTimeout(renewCtx, interval)
+			renewed, renewErr := db.TryAcquireLease(attemptCtx, e.pool, e.name, e.holder, e.ttl)
+			attemptCancel()
+			if renewErr != nil || !renewed {
+				slog.WarnContext(ctx, "Lost lease, stepping down", "lease", e.name, "renewed", renewed, "error", renewErr)
+				cancel(db.ErrLeaseLost)
+				err = <-errChan
+				break loop
+			}
+		}
+	}
+
+	// Release with a fresh context: ctx may already be cancelled on shutdown
+	releaseCtx, releaseCancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer releaseCancel()
+	if releaseErr := db.ReleaseLease(releaseCtx, e.pool, e.name, e.holder); releaseErr != nil {
+		slog.Error("Failed to release lease", "lease", e.name, "error", releaseErr)
+	} else {
+		slog.Info("Released lease", "lease", e.name, "holder", e.holder)
+	}
+
+	return err
+}
@@ -0,0 +1,63 @@
+package leader
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/dbtest"
+)
+
+func TestIntegration_StandbyTakesOver(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ttl := 600 * time.Millisecond
+
+	var leading atomic.Int32
+	lead := func(name string, became chan<- string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			if leading.Add(1) > 1 {
+				t.Errorf("%s became leader while another replica was leading", name)
+			}
+			became <- name
+			<-ctx.Done()
+			leading.Add(-1)
+			return nil
+		}
+	}
+
+	became := make(chan string, 2)
+	ctxA, cancelA := context.WithCancel(context.Background())
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+
+	go New(pool, IndexerLease, "a", ttl).Run(ctxA, lead("a", became))
+	if got := <-became; got != "a" {
+		t.Fatalf("first leader = %s, want a", got)
+	}
+	go New(pool, IndexerLease, "b", ttl).Run(ctxB, lead("b", became))
+
+	// b stays standby while a renews its lease
+	select {
+	case name := <-became:
+		t.Fatalf("%s became leader while a holds the lease", name)
+	case <-time.After(2 * ttl):
+	}
+
+	lease, err := db.GetLease(context.Background(), pool, IndexerLease)
+	if err != nil || lease == nil || lease.Holder != "a" {
+		t.Fatalf("GetLease() = %+v, %v, want holder a", lease, err)
+	}
+
+	// a shuts down and releases the lease, b takes over
+	cancelA()
+	select {
+	case got := <-became:
+		if got != "b" {
+			t.Fatalf("new leader = %s, want b", got)
+		}
+	case <-time.After(3 * ttl):
+		t.Fatal("standby did not take over")
+	}
+}
@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		level   string
+		wantErr bool
+	}{
+		{name: "text info", format: "text", level: "info"},
+		{name: "json debug", format: "json", level: "debug"},
+		{name: "upper case", format: "JSON", level: "WARN"},
+		{name: "invalid format", format: "xml", level: "info", wantErr: true},
+		{name: "invalid level", format: "text", level: "verbose", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(&bytes.Buffer{}, tt.format, tt.level)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNew_LevelFiltering(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "text", "warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Info("hidden")
+	logger.Warn("shown")
+
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "shown") {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+}
+
+func TestNew_RequestIDFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithRequestID(context.Background(), "abc123")
+	logger.With("component", "api").InfoContext(ctx, "query failed", "rows", 3)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("output is not JSON: %v", err)
+	}
+	if record["request_id"] != "abc123" || record["component"] != "api" || record["rows"] != float64(3) {
+		t.Errorf("record = %v, want request_id, component and rows fields", record)
+	}
+}
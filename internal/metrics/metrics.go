@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "delegated"
+
+// Indexer metrics, labelled by indexer ("delegations" or "staking"). The mode label is "poll" for
+// live indexing and "backfill" for historical data.
+var (
+	Polls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "indexer",
+		Name:      "polls_total",
+		Help:      "Number of polls, by result (success or error).",
+	}, []string{"indexer", "result"})
+
+	FetchedRows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "indexer",
+		Name:      "fetched_rows_total",
+		Help:      "Number of operations fetched from TzKT.",
+	}, []string{"indexer", "mode"})
+
+	InsertedRows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "indexer",
+		Name:      "inserted_rows_total",
+		Help:      "Number of operations written to the database.",
+	}, []string{"indexer", "mode"})
+
+	FetchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "indexer",
+		Name:      "fetch_duration_seconds",
+		Help:      "Duration of TzKT requests, including retries.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 10),
+	}, []string{"indexer"})
+
+	InsertDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "indexer",
+		Name:      "insert_duration_seconds",
+		Help:      "Duration of database batch inserts.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"indexer", "mode"})
+
+	Cursor = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "indexer",
+		Name:      "cursor",
+		Help:      "Highest operation id indexed by the live indexer.",
+	}, []string{"indexer"})
+
+	LastSuccessfulPoll = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "indexer",
+		Name:      "last_successful_poll_timestamp_seconds",
+		Help:      "Unix time of the last successful poll, alert when it stops moving.",
+	}, []string{"indexer"})
+
+	LagLevels = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "indexer",
+		Name:      "lag_levels",
+		Help:      "Number of blocks between the TzKT head and the last head the indexer fully caught up with.",
+	}, []string{"indexer"})
+
+	LagSeconds = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "indexer",
+		Name:      "lag_seconds",
+		Help:      "Seconds between the TzKT head and the last head the indexer fully caught up with.",
+	}, []string{"indexer"})
+)
+
+// API metrics, labelled by route template (e.g. /xtz/delegations) rather than raw path
+var (
+	APIRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "api",
+		Name:      "requests_total",
+		Help:      "Number of API requests.",
+	}, []string{"route", "method", "status"})
+
+	APIRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "api",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of API requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+)
+
+// Handler serves the metrics of the default registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// WebhookDeliveries counts webhook delivery attempts by result: delivered, failed (retried later) or dead (dead-lettered)
+var WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "webhooks",
+	Name:      "deliveries_total",
+	Help:      "Number of webhook delivery attempts, by result (delivered, failed or dead).",
+}, []string{"result"})
+
+// Event sink metrics, labelled by sink name
+var (
+	SinkPublishedRows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sink",
+		Name:      "published_rows_total",
+		Help:      "Number of delegations published to the sink.",
+	}, []string{"sink"})
+
+	SinkErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sink",
+		Name:      "errors_total",
+		Help:      "Number of failed publications, retried later.",
+	}, []string{"sink"})
+
+	SinkCursor = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "sink",
+		Name:      "cursor",
+		Help:      "Id of the last delegation published to the sink.",
+	}, []string{"sink"})
+)
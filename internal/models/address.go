@@ -0,0 +1,17 @@
+package models
+
+import "strings"
+
+// IsAddress reports whether s looks like a Tezos account address: 36 characters
+// starting with tz1, tz2, tz3, tz4 (implicit accounts) or KT1 (contracts)
+func IsAddress(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for _, prefix := range []string{"tz1", "tz2", "tz3", "tz4", "KT1"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
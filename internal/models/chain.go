@@ -0,0 +1,70 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Cycle is a Tezos cycle: the blocks from FirstLevel to LastLevel
+type Cycle struct {
+	Index      int32     `json:"index"`
+	FirstLevel int32     `json:"firstLevel"`
+	LastLevel  int32     `json:"lastLevel"`
+	StartTime  time.Time `json:"startTime"`
+	EndTime    time.Time `json:"endTime"`
+}
+
+// Protocol is a Tezos protocol, active from FirstLevel to LastLevel
+type Protocol struct {
+	Code  int32
+	Hash  string
+	Alias string
+	// FirstLevel is the level of the first block of the protocol
+	FirstLevel int32
+	// LastLevel is nil for the current protocol
+	LastLevel *int32
+	// FirstCycle is the first cycle run entirely under the protocol
+	FirstCycle     int32
+	BlocksPerCycle int32
+}
+
+// tzktProtocol is the response structure from TzKT API
+type tzktProtocol struct {
+	Code       int32  `json:"code"`
+	Hash       string `json:"hash"`
+	FirstLevel int32  `json:"firstLevel"`
+	LastLevel  *int32 `json:"lastLevel"`
+	FirstCycle int32  `json:"firstCycle"`
+	Constants  struct {
+		BlocksPerCycle int32 `json:"blocksPerCycle"`
+	} `json:"constants"`
+	Metadata *struct {
+		Alias string `json:"alias"`
+	} `json:"metadata"`
+}
+
+// UnmarshalJSON custom unmarshaling to handle nested constants.blocksPerCycle and metadata.alias
+func (p *Protocol) UnmarshalJSON(data []byte) error {
+	var t tzktProtocol
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+
+	p.Code = t.Code
+	p.Hash = t.Hash
+	p.FirstLevel = t.FirstLevel
+	p.LastLevel = t.LastLevel
+	p.FirstCycle = t.FirstCycle
+	p.BlocksPerCycle = t.Constants.BlocksPerCycle
+	p.Alias = ""
+	if t.Metadata != nil {
+		p.Alias = t.Metadata.Alias
+	}
+
+	return nil
+}
+
+// IsProtocolHash reports whether s looks like a Tezos protocol hash: 51 characters starting with P
+func IsProtocolHash(s string) bool {
+	return len(s) == 51 && s[0] == 'P'
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestProtocol_UnmarshalJSON(t *testing.T) {
+	lastLevel := int32(5726208)
+
+	tests := []struct {
+		name string
+		json string
+		want Protocol
+	}{
+		{
+			name: "past protocol",
+			json: `{
+				"code": 18,
+				"hash": "ProxfordYmVfjWnRcgjWH36fW6PArwqykTFzotUxRs6gmTcZDuH",
+				"firstLevel": 5070849,
+				"firstCycle": 703,
+				"firstCycleLevel": 5070849,
+				"lastLevel": 5726208,
+				"constants": {"blocksPerCycle": 16384},
+				"metadata": {"docs": "https://tezos.gitlab.io/oxford/", "alias": "Oxford 2"}
+			}`,
+			want: Protocol{
+				Code:           18,
+				Hash:           "ProxfordYmVfjWnRcgjWH36fW6PArwqykTFzotUxRs6gmTcZDuH",
+				Alias:          "Oxford 2",
+				FirstLevel:     5070849,
+				LastLevel:      &lastLevel,
+				FirstCycle:     703,
+				BlocksPerCycle: 16384,
+			},
+		},
+		{
+			name: "current protocol without metadata",
+			json: `{
+				"code": 19,
+				"hash": "PtParisBxoLz5gzMmn3d9WBQNoPSZakgnkMC2VNuQ3KXfUtUQeZ",
+				"firstLevel": 5726209,
+				"firstCycle": 743,
+				"constants": {"blocksPerCycle": 24576}
+			}`,
+			want: Protocol{
+				Code:           19,
+				Hash:           "PtParisBxoLz5gzMmn3d9WBQNoPSZakgnkMC2VNuQ3KXfUtUQeZ",
+				FirstLevel:     5726209,
+				FirstCycle:     743,
+				BlocksPerCycle: 24576,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Protocol
+			if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+				t.Fatalf("UnmarshalJSON() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UnmarshalJSON() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 )
 
@@ -11,6 +12,10 @@ type Delegation struct {
 	Timestamp time.Time `json:"timestamp"`
 	Amount    int64     `json:"amount"`
 	Level     int32     `json:"level"`
+	// Baker is the new delegate, empty for an undelegation
+	Baker string `json:"-"`
+	// PrevBaker is the previous delegate, empty for a first delegation
+	PrevBaker string `json:"-" db:"prev_baker"`
 }
 
 // tzktDelegation is the response structure from TzKT API
@@ -22,9 +27,15 @@ type tzktDelegation struct {
 	Sender    struct {
 		Address string `json:"address"`
 	} `json:"sender"`
+	NewDelegate *struct {
+		Address string `json:"address"`
+	} `json:"newDelegate"`
+	PrevDelegate *struct {
+		Address string `json:"address"`
+	} `json:"prevDelegate"`
 }
 
-// UnmarshalJSON custom unmarshaling to handle nested sender.address
+// UnmarshalJSON custom unmarshaling to handle nested sender.address, newDelegate.address and prevDelegate.address
 func (d *Delegation) UnmarshalJSON(data []byte) error {
 	var t tzktDelegation
 	if err := json.Unmarshal(data, &t); err != nil {
@@ -36,6 +47,40 @@ func (d *Delegation) UnmarshalJSON(data []byte) error {
 	d.Timestamp = t.Timestamp
 	d.Amount = t.Amount
 	d.Level = t.Level
+	d.Baker = ""
+	if t.NewDelegate != nil {
+		d.Baker = t.NewDelegate.Address
+	}
+	d.PrevBaker = ""
+	if t.PrevDelegate != nil {
+		d.PrevBaker = t.PrevDelegate.Address
+	}
+
+	return nil
+}
 
+// OperationID returns the TzKT id of the operation
+func (d Delegation) OperationID() int64 {
+	return d.ID
+}
+
+// Validate checks that a delegation read from outside TzKT (e.g. an imported file) fits the delegations table
+func (d Delegation) Validate() error {
+	switch {
+	case d.ID <= 0:
+		return fmt.Errorf("id must be positive")
+	case !IsAddress(d.Delegator):
+		return fmt.Errorf("delegator %q is not a Tezos address", d.Delegator)
+	case d.Baker != "" && !IsAddress(d.Baker):
+		return fmt.Errorf("baker %q is not a Tezos address", d.Baker)
+	case d.PrevBaker != "" && !IsAddress(d.PrevBaker):
+		return fmt.Errorf("previous baker %q is not a Tezos address", d.PrevBaker)
+	case d.Timestamp.IsZero():
+		return fmt.Errorf("timestamp is missing")
+	case d.Amount < 0:
+		return fmt.Errorf("amount must not be negative")
+	case d.Level <= 0:
+		return fmt.Errorf("level must be positive")
+	}
 	return nil
 }
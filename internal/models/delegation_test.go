@@ -33,6 +33,59 @@ func TestDelegation_UnmarshalJSON(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "delegation to a baker",
+			json: `{
+				"id": 123,
+				"level": 456,
+				"timestamp": "2022-05-05T06:29:14Z",
+				"amount": 98765,
+				"sender": {
+					"address": "tz1a1SAaXRt9yoGMx29rh9FsBF4UzmvojdTL"
+				},
+				"newDelegate": {
+					"alias": "Everstake",
+					"address": "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM"
+				}
+			}`,
+			want: Delegation{
+				ID:        123,
+				Delegator: "tz1a1SAaXRt9yoGMx29rh9FsBF4UzmvojdTL",
+				Timestamp: time.Date(2022, 5, 5, 6, 29, 14, 0, time.UTC),
+				Amount:    98765,
+				Level:     456,
+				Baker:     "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM",
+			},
+			wantErr: false,
+		},
+		{
+			name: "redelegation",
+			json: `{
+				"id": 123,
+				"level": 456,
+				"timestamp": "2022-05-05T06:29:14Z",
+				"amount": 98765,
+				"sender": {
+					"address": "tz1a1SAaXRt9yoGMx29rh9FsBF4UzmvojdTL"
+				},
+				"prevDelegate": {
+					"address": "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM"
+				},
+				"newDelegate": {
+					"address": "tz3RDC3Jdn4j15J7bBHZd29EUee9gVB1CxD9"
+				}
+			}`,
+			want: Delegation{
+				ID:        123,
+				Delegator: "tz1a1SAaXRt9yoGMx29rh9FsBF4UzmvojdTL",
+				Timestamp: time.Date(2022, 5, 5, 6, 29, 14, 0, time.UTC),
+				Amount:    98765,
+				Level:     456,
+				Baker:     "tz3RDC3Jdn4j15J7bBHZd29EUee9gVB1CxD9",
+				PrevBaker: "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM",
+			},
+			wantErr: false,
+		},
 		{
 			name: "missing sender address field",
 			json: `{
@@ -111,3 +164,41 @@ func TestDelegation_UnmarshalJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestDelegation_Validate(t *testing.T) {
+	valid := Delegation{
+		ID:        123,
+		Delegator: "tz1a1SAaXRt9yoGMx29rh9FsBF4UzmvojdTL",
+		Baker:     "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM",
+		Timestamp: time.Date(2022, 5, 5, 6, 29, 14, 0, time.UTC),
+		Amount:    98765,
+		Level:     456,
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(d *Delegation)
+		wantErr bool
+	}{
+		{name: "valid", modify: func(d *Delegation) {}},
+		{name: "undelegation", modify: func(d *Delegation) { d.Baker = "" }},
+		{name: "contract delegator", modify: func(d *Delegation) { d.Delegator = "KT1PWx2mnDueood7fEmfbBDKx1D9BAnnXitn" }},
+		{name: "zero id", modify: func(d *Delegation) { d.ID = 0 }, wantErr: true},
+		{name: "short delegator", modify: func(d *Delegation) { d.Delegator = "tz1a1SAaXRt9" }, wantErr: true},
+		{name: "unknown prefix", modify: func(d *Delegation) { d.Delegator = "xx1a1SAaXRt9yoGMx29rh9FsBF4UzmvojdTL" }, wantErr: true},
+		{name: "invalid baker", modify: func(d *Delegation) { d.Baker = "baker" }, wantErr: true},
+		{name: "invalid previous baker", modify: func(d *Delegation) { d.PrevBaker = "baker" }, wantErr: true},
+		{name: "missing timestamp", modify: func(d *Delegation) { d.Timestamp = time.Time{} }, wantErr: true},
+		{name: "negative amount", modify: func(d *Delegation) { d.Amount = -1 }, wantErr: true},
+		{name: "zero level", modify: func(d *Delegation) { d.Level = 0 }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := valid
+			tt.modify(&d)
+			if err := d.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
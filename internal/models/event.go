@@ -0,0 +1,31 @@
+package models
+
+import (
+	"strconv"
+	"time"
+)
+
+// Event is the JSON representation of a delegation pushed to other services (webhooks, sinks).
+// Like the API, amounts and levels are strings so that consumers do not lose precision.
+type Event struct {
+	ID        int64  `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Amount    string `json:"amount"`
+	Delegator string `json:"delegator"`
+	Baker     string `json:"baker"`
+	PrevBaker string `json:"prev_baker"`
+	Level     string `json:"level"`
+}
+
+// NewEvent formats a delegation as an Event
+func NewEvent(d Delegation) Event {
+	return Event{
+		ID:        d.ID,
+		Timestamp: d.Timestamp.Format(time.RFC3339),
+		Amount:    strconv.FormatInt(d.Amount, 10),
+		Delegator: d.Delegator,
+		Baker:     d.Baker,
+		PrevBaker: d.PrevBaker,
+		Level:     strconv.FormatInt(int64(d.Level), 10),
+	}
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Staking actions: staking tez with the baker, unstaking them, and finalizing an unstake once
+// the unstaked tez are unfrozen
+const (
+	StakingActionStake    = "stake"
+	StakingActionUnstake  = "unstake"
+	StakingActionFinalize = "finalize"
+)
+
+// StakingOp is a staking operation of a staker (the sender) with its baker
+type StakingOp struct {
+	ID        int64     `json:"id"`
+	Staker    string    `json:"-"`
+	Baker     string    `json:"-"`
+	Action    string    `json:"action"`
+	Timestamp time.Time `json:"timestamp"`
+	// Amount is the amount actually staked, unstaked or finalized, in mutez
+	Amount int64 `json:"amount"`
+	Level  int32 `json:"level"`
+}
+
+// tzktStakingOp is the response structure from TzKT API
+type tzktStakingOp struct {
+	ID        int64     `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Level     int32     `json:"level"`
+	Action    string    `json:"action"`
+	Amount    *int64    `json:"amount"`
+	Sender    struct {
+		Address string `json:"address"`
+	} `json:"sender"`
+	Baker *struct {
+		Address string `json:"address"`
+	} `json:"baker"`
+}
+
+// UnmarshalJSON custom unmarshaling to handle nested sender.address and baker.address.
+// Failed operations, which the indexer does not fetch, have no amount.
+func (s *StakingOp) UnmarshalJSON(data []byte) error {
+	var t tzktStakingOp
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+
+	s.ID = t.ID
+	s.Staker = t.Sender.Address
+	s.Action = t.Action
+	s.Timestamp = t.Timestamp
+	s.Level = t.Level
+	s.Amount = 0
+	if t.Amount != nil {
+		s.Amount = *t.Amount
+	}
+	s.Baker = ""
+	if t.Baker != nil {
+		s.Baker = t.Baker.Address
+	}
+
+	return nil
+}
+
+// OperationID returns the TzKT id of the operation
+func (s StakingOp) OperationID() int64 {
+	return s.ID
+}
+
+// IsStakingAction reports whether action is stake, unstake or finalize
+func IsStakingAction(action string) bool {
+	switch action {
+	case StakingActionStake, StakingActionUnstake, StakingActionFinalize:
+		return true
+	}
+	return false
+}
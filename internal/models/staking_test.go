@@ -0,0 +1,89 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestStakingOp_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want StakingOp
+	}{
+		{
+			name: "stake with a baker",
+			json: `{
+				"type": "staking",
+				"id": 123,
+				"level": 456,
+				"timestamp": "2024-06-05T06:29:14Z",
+				"sender": {"address": "tz1a1SAaXRt9yoGMx29rh9FsBF4UzmvojdTL"},
+				"baker": {"alias": "Everstake", "address": "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM"},
+				"action": "stake",
+				"amount": 98765,
+				"status": "applied"
+			}`,
+			want: StakingOp{
+				ID:        123,
+				Staker:    "tz1a1SAaXRt9yoGMx29rh9FsBF4UzmvojdTL",
+				Baker:     "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM",
+				Action:    StakingActionStake,
+				Timestamp: time.Date(2024, 6, 5, 6, 29, 14, 0, time.UTC),
+				Amount:    98765,
+				Level:     456,
+			},
+		},
+		{
+			name: "failed finalize without amount nor baker",
+			json: `{
+				"id": 124,
+				"level": 457,
+				"timestamp": "2024-06-05T06:29:22Z",
+				"sender": {"address": "tz1a1SAaXRt9yoGMx29rh9FsBF4UzmvojdTL"},
+				"action": "finalize",
+				"amount": null,
+				"status": "failed"
+			}`,
+			want: StakingOp{
+				ID:        124,
+				Staker:    "tz1a1SAaXRt9yoGMx29rh9FsBF4UzmvojdTL",
+				Action:    StakingActionFinalize,
+				Timestamp: time.Date(2024, 6, 5, 6, 29, 22, 0, time.UTC),
+				Level:     457,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got StakingOp
+			if err := json.Unmarshal([]byte(tt.json), &got); err != nil {
+				t.Fatalf("UnmarshalJSON() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("UnmarshalJSON() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsStakingAction(t *testing.T) {
+	tests := []struct {
+		action string
+		want   bool
+	}{
+		{StakingActionStake, true},
+		{StakingActionUnstake, true},
+		{StakingActionFinalize, true},
+		{"delegate", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsStakingAction(tt.action); got != tt.want {
+			t.Errorf("IsStakingAction(%q) = %v, want %v", tt.action, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package seed
+
+import (
+	"crypto/sha256"
+	"math/big"
+	"math/rand"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// Base58check prefixes of Tezos addresses (20-byte hash payload)
+var (
+	prefixTz1 = []byte{6, 161, 159}
+	prefixTz2 = []byte{6, 161, 161}
+	prefixTz3 = []byte{6, 161, 164}
+	prefixKT1 = []byte{2, 90, 121}
+)
+
+// RandomAddress returns a well-formed Tezos address with a random hash.
+// The mix roughly follows mainnet: mostly tz1, some KT1 (pre-Babylon originated accounts), few tz2/tz3.
+func RandomAddress(rnd *rand.Rand) string {
+	var prefix []byte
+	switch p := rnd.Float64(); {
+	case p < 0.82:
+		prefix = prefixTz1
+	case p < 0.92:
+		prefix = prefixKT1
+	case p < 0.97:
+		prefix = prefixTz2
+	default:
+		prefix = prefixTz3
+	}
+
+	hash := make([]byte, 20)
+	rnd.Read(hash)
+	return base58Check(prefix, hash)
+}
+
+// RandomImplicitAddress returns a well-formed tz1, tz2 or tz3 address, as used by bakers
+func RandomImplicitAddress(rnd *rand.Rand) string {
+	prefix := prefixTz1
+	switch p := rnd.Float64(); {
+	case p >= 0.95:
+		prefix = prefixTz3
+	case p >= 0.85:
+		prefix = prefixTz2
+	}
+
+	hash := make([]byte, 20)
+	rnd.Read(hash)
+	return base58Check(prefix, hash)
+}
+
+// base58Check encodes prefix+payload with a 4-byte double-SHA256 checksum
+func base58Check(prefix, payload []byte) string {
+	data := make([]byte, 0, len(prefix)+len(payload)+4)
+	data = append(data, prefix...)
+	data = append(data, payload...)
+
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	data = append(data, second[:4]...)
+
+	return base58Encode(data)
+}
+
+func base58Encode(data []byte) string {
+	n := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+
+	// Leading zero bytes are encoded as '1'
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		out = append(out, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
@@ -0,0 +1,190 @@
+package seed
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/models"
+)
+
+// blockTimes lists the mainnet minimal block time by protocol activation date
+var blockTimes = []struct {
+	since     time.Time
+	blockTime time.Duration
+}{
+	{time.Date(2018, 6, 30, 0, 0, 0, 0, time.UTC), 60 * time.Second}, // Betanet
+	{time.Date(2021, 8, 6, 0, 0, 0, 0, time.UTC), 30 * time.Second},  // Granada
+	{time.Date(2023, 3, 29, 0, 0, 0, 0, time.UTC), 15 * time.Second}, // Mumbai
+	{time.Date(2024, 6, 4, 0, 0, 0, 0, time.UTC), 10 * time.Second},  // Paris
+	{time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC), 8 * time.Second},  // Quebec
+}
+
+// blockTimeAt returns the block time in effect at t
+func blockTimeAt(t time.Time) time.Duration {
+	bt := blockTimes[0].blockTime
+	for _, b := range blockTimes {
+		if t.Before(b.since) {
+			break
+		}
+		bt = b.blockTime
+	}
+	return bt
+}
+
+// Options configures the synthetic delegation generator
+type Options struct {
+	// Rows is the number of delegations to generate
+	Rows int
+	// From is the timestamp of the first delegation
+	From time.Time
+	// To is the approximate timestamp of the last delegation, used to space delegations out
+	To time.Time
+	// StartID is the id of the first delegation
+	StartID int64
+	// StartLevel is the level of the first delegation
+	StartLevel int32
+	// Seed seeds the random source
+	Seed int64
+}
+
+// StartAfter makes the generated delegations follow an existing one in id, level and time: ids
+// and levels continue from it, and From moves to its next block if it is earlier. Otherwise the
+// generated delegations would have higher ids than the existing ones but older timestamps and
+// levels, and replace them as the latest delegations. It fails when To is not after the new From.
+func (o *Options) StartAfter(id int64, level int32, timestamp time.Time) error {
+	o.StartID = id + 1
+	o.StartLevel = level + 1
+	if next := timestamp.UTC().Add(blockTimeAt(timestamp)); o.From.Before(next) {
+		o.From = next
+	}
+	if !o.To.After(o.From) {
+		return fmt.Errorf("to %s is not after the first delegation at %s", o.To.Format(time.RFC3339), o.From.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Generator produces realistic-looking delegations: plausible addresses reused
+// with a Zipf distribution, Pareto-distributed amounts, increasing ids and levels,
+// and timestamps aligned on the block time of the era.
+type Generator struct {
+	rnd       *rand.Rand
+	zipf      *rand.Zipf
+	addresses []string
+	bakerZipf *rand.Zipf
+	bakers    []string
+	meanGap   time.Duration
+
+	// delegates is the current baker of each delegator
+	delegates map[string]string
+
+	remaining int
+	id        int64
+	level     int32
+	timestamp time.Time
+}
+
+// NewGenerator creates a generator for the given options
+func NewGenerator(opts Options) *Generator {
+	rnd := rand.New(rand.NewSource(opts.Seed))
+
+	// Roughly one distinct delegator every three delegations
+	addresses := make([]string, opts.Rows/3+1)
+	for i := range addresses {
+		addresses[i] = RandomAddress(rnd)
+	}
+
+	// A few hundred bakers attract most delegations
+	bakers := make([]string, min(opts.Rows/50+1, 400))
+	for i := range bakers {
+		bakers[i] = RandomImplicitAddress(rnd)
+	}
+
+	meanGap := time.Minute
+	if opts.Rows > 0 && opts.To.After(opts.From) {
+		meanGap = opts.To.Sub(opts.From) / time.Duration(opts.Rows)
+	}
+
+	startID := opts.StartID
+	if startID <= 0 {
+		startID = 1
+	}
+	startLevel := opts.StartLevel
+	if startLevel <= 0 {
+		startLevel = 1
+	}
+
+	return &Generator{
+		rnd:       rnd,
+		zipf:      rand.NewZipf(rnd, 1.1, 1, uint64(len(addresses)-1)),
+		addresses: addresses,
+		bakerZipf: rand.NewZipf(rnd, 1.3, 1, uint64(len(bakers)-1)),
+		bakers:    bakers,
+		delegates: make(map[string]string),
+		meanGap:   meanGap,
+		remaining: opts.Rows,
+		id:        startID,
+		level:     startLevel,
+		timestamp: opts.From.UTC().Truncate(time.Second),
+	}
+}
+
+// Next returns the next delegation, or false once Rows delegations were generated
+func (g *Generator) Next() (models.Delegation, bool) {
+	if g.remaining <= 0 {
+		return models.Delegation{}, false
+	}
+	g.remaining--
+
+	d := models.Delegation{
+		ID:        g.id,
+		Delegator: g.addresses[g.zipf.Uint64()],
+		Timestamp: g.timestamp,
+		Amount:    g.amount(),
+		Level:     g.level,
+		Baker:     g.baker(),
+	}
+	d.PrevBaker = g.delegates[d.Delegator]
+	g.delegates[d.Delegator] = d.Baker
+
+	g.advance()
+	return d, true
+}
+
+// baker picks the new delegate; about 2% of delegations are undelegations, without a baker
+func (g *Generator) baker() string {
+	if g.rnd.Float64() < 0.02 {
+		return ""
+	}
+	return g.bakers[g.bakerZipf.Uint64()]
+}
+
+// amount draws a Pareto-distributed balance in mutez (alpha 1.16, i.e. 80/20, minimum 1 tez)
+func (g *Generator) amount() int64 {
+	const (
+		alpha   = 1.16
+		minimum = 1_000_000
+		maximum = 50_000_000_000_000 // 50M tez
+	)
+	v := minimum / math.Pow(1-g.rnd.Float64(), 1/alpha)
+	if v > maximum {
+		v = maximum
+	}
+	return int64(v)
+}
+
+// advance moves to the block of the next delegation. The gap is exponentially
+// distributed around the mean, rounded to whole blocks, so several delegations
+// can share a block.
+func (g *Generator) advance() {
+	blockTime := blockTimeAt(g.timestamp)
+	gap := time.Duration(g.rnd.ExpFloat64() * float64(g.meanGap))
+	blocks := int64(gap / blockTime)
+
+	g.level += int32(blocks)
+	g.timestamp = g.timestamp.Add(time.Duration(blocks) * blockTime)
+
+	// Operation ids are global counters: every block carries other operations too
+	g.id += 1 + blocks*int64(1+g.rnd.Intn(40))
+}
@@ -0,0 +1,171 @@
+package seed
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"math/big"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// base58CheckDecode is the inverse of base58Check, used to validate generated addresses
+func base58CheckDecode(t *testing.T, s string) []byte {
+	t.Helper()
+
+	n := new(big.Int)
+	for _, c := range s {
+		idx := strings.IndexRune(base58Alphabet, c)
+		if idx < 0 {
+			t.Fatalf("invalid base58 character %q in %s", c, s)
+		}
+		n.Mul(n, big.NewInt(58))
+		n.Add(n, big.NewInt(int64(idx)))
+	}
+
+	data := n.Bytes()
+	if len(data) < 4 {
+		t.Fatalf("decoded %s too short", s)
+	}
+	payload, checksum := data[:len(data)-4], data[len(data)-4:]
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	if !bytes.Equal(second[:4], checksum) {
+		t.Fatalf("bad checksum for %s", s)
+	}
+	return payload
+}
+
+func TestRandomAddress(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	prefixes := map[string][]byte{
+		"tz1": prefixTz1,
+		"tz2": prefixTz2,
+		"tz3": prefixTz3,
+		"KT1": prefixKT1,
+	}
+
+	for i := 0; i < 200; i++ {
+		addr := RandomAddress(rnd)
+		if len(addr) != 36 {
+			t.Fatalf("address %s has length %d, want 36", addr, len(addr))
+		}
+
+		prefix, ok := prefixes[addr[:3]]
+		if !ok {
+			t.Fatalf("address %s has unexpected prefix", addr)
+		}
+
+		payload := base58CheckDecode(t, addr)
+		if !bytes.HasPrefix(payload, prefix) || len(payload) != len(prefix)+20 {
+			t.Errorf("address %s decodes to unexpected payload %x", addr, payload)
+		}
+	}
+}
+
+func TestBlockTimeAt(t *testing.T) {
+	tests := []struct {
+		at   time.Time
+		want time.Duration
+	}{
+		{time.Date(2018, 7, 1, 0, 0, 0, 0, time.UTC), 60 * time.Second},
+		{time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), 30 * time.Second},
+		{time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC), 15 * time.Second},
+		{time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC), 10 * time.Second},
+		{time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), 8 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := blockTimeAt(tt.at); got != tt.want {
+			t.Errorf("blockTimeAt(%v) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	from := time.Date(2018, 6, 30, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	gen := NewGenerator(Options{Rows: 5000, From: from, To: to, StartID: 100, StartLevel: 10, Seed: 3})
+
+	var prev *struct {
+		id    int64
+		level int32
+		ts    time.Time
+	}
+	count := 0
+	for {
+		d, ok := gen.Next()
+		if !ok {
+			break
+		}
+		count++
+
+		if d.Amount < 1_000_000 {
+			t.Errorf("amount %d below minimum", d.Amount)
+		}
+		if prev != nil {
+			if d.ID <= prev.id {
+				t.Fatalf("id %d not after %d", d.ID, prev.id)
+			}
+			if d.Level < prev.level || d.Timestamp.Before(prev.ts) {
+				t.Fatalf("level/timestamp went backwards at id %d", d.ID)
+			}
+			if d.Level == prev.level && !d.Timestamp.Equal(prev.ts) {
+				t.Fatalf("same level %d with different timestamps", d.Level)
+			}
+		} else if d.ID != 100 || d.Level != 10 || !d.Timestamp.Equal(from) {
+			t.Fatalf("first delegation = %+v, want id 100, level 10 at %v", d, from)
+		}
+		prev = &struct {
+			id    int64
+			level int32
+			ts    time.Time
+		}{d.ID, d.Level, d.Timestamp}
+	}
+
+	if count != 5000 {
+		t.Fatalf("generated %d rows, want 5000", count)
+	}
+
+	// The last delegation should land reasonably close to To
+	if diff := prev.ts.Sub(to); diff > 180*24*time.Hour || diff < -180*24*time.Hour {
+		t.Errorf("last timestamp %v too far from %v", prev.ts, to)
+	}
+}
+
+func TestOptions_StartAfter(t *testing.T) {
+	latest := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		wantFrom time.Time
+		wantErr  bool
+	}{
+		{name: "from moves after the latest delegation", from: time.Date(2018, 6, 30, 0, 0, 0, 0, time.UTC), to: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), wantFrom: latest.Add(8 * time.Second)},
+		{name: "later from is kept", from: time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC), to: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), wantFrom: time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{name: "to before the latest delegation", from: time.Date(2018, 6, 30, 0, 0, 0, 0, time.UTC), to: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Options{Rows: 10, From: tt.from, To: tt.to}
+			err := opts.StartAfter(500, 7000, latest)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("StartAfter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if opts.StartID != 501 || opts.StartLevel != 7001 || !opts.From.Equal(tt.wantFrom) {
+				t.Errorf("options = %+v, want id 501, level 7001 from %v", opts, tt.wantFrom)
+			}
+			d, _ := NewGenerator(opts).Next()
+			if d.ID != 501 || d.Level != 7001 || !d.Timestamp.After(latest) {
+				t.Errorf("first delegation = %+v, want it after the latest one", d)
+			}
+		})
+	}
+}
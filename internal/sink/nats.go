@@ -0,0 +1,85 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// ackTimeout bounds the wait for JetStream to acknowledge a batch
+const ackTimeout = 10 * time.Second
+
+// NATS publishes one message per delegation on a subject through JetStream: a batch is only
+// accepted once the stream bound to the subject acknowledged every message, so nothing is
+// lost when no stream captures it. The delegation id is set as Nats-Msg-Id, so the stream
+// drops redelivered messages within its duplicate window.
+type NATS struct {
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	subject string
+}
+
+// NewNATS connects to the NATS server at url. The connection is retried in the background
+// when the server is unavailable: publications fail until it is established.
+func NewNATS(url, subject string) (*NATS, error) {
+	conn, err := nats.Connect(url,
+		nats.Name("delegated"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+	return &NATS{conn: conn, js: js, subject: subject}, nil
+}
+
+// Publish sends the batch asynchronously, then waits for JetStream to acknowledge every message
+func (s *NATS) Publish(ctx context.Context, delegations []models.Delegation) error {
+	if !s.conn.IsConnected() {
+		return fmt.Errorf("not connected to NATS (%s)", s.conn.Status())
+	}
+
+	acks := make([]jetstream.PubAckFuture, 0, len(delegations))
+	for _, d := range delegations {
+		data, err := encode(d)
+		if err != nil {
+			return err
+		}
+		msg := nats.NewMsg(s.subject)
+		msg.Header.Set(nats.MsgIdHdr, strconv.FormatInt(d.ID, 10))
+		msg.Data = data
+		ack, err := s.js.PublishMsgAsync(msg)
+		if err != nil {
+			return fmt.Errorf("failed to publish: %w", err)
+		}
+		acks = append(acks, ack)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ackTimeout)
+	defer cancel()
+	for _, ack := range acks {
+		select {
+		case <-ack.Ok():
+		case err := <-ack.Err():
+			return fmt.Errorf("failed to publish delegation %s: %w", ack.Msg().Header.Get(nats.MsgIdHdr), err)
+		case <-ctx.Done():
+			return fmt.Errorf("failed to get acknowledgements: %w", ctx.Err())
+		}
+	}
+	return nil
+}
+
+// Close flushes pending messages and closes the connection
+func (s *NATS) Close() error {
+	return s.conn.Drain()
+}
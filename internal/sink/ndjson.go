@@ -0,0 +1,61 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/broyeztony/delegated/internal/models"
+)
+
+// NDJSON appends one JSON event per line to a file or stdout
+type NDJSON struct {
+	w    io.Writer
+	file *os.File
+}
+
+// NewNDJSON opens the file at path for appending, or writes to stdout when path is "-"
+func NewNDJSON(path string) (*NDJSON, error) {
+	if path == "-" {
+		return &NDJSON{w: os.Stdout}, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return &NDJSON{w: file, file: file}, nil
+}
+
+// Publish writes the batch and, for a file, syncs it to disk before returning
+func (s *NDJSON) Publish(ctx context.Context, delegations []models.Delegation) error {
+	buf := bufio.NewWriter(s.w)
+	for _, d := range delegations {
+		line, err := encode(d)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("failed to write: %w", err)
+	}
+
+	if s.file != nil {
+		if err := s.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync: %w", err)
+		}
+	}
+	return nil
+}
+
+// Close closes the file
+func (s *NDJSON) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
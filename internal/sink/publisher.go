@@ -0,0 +1,118 @@
+package sink
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/metrics"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// batchSize is the maximum number of delegations per Publish call
+	batchSize = 1000
+	// checkInterval is how often the table is checked for delegations to publish without being notified,
+	// e.g. after a failure or when another process indexes
+	checkInterval = 5 * time.Second
+	// publishLease bounds a Publish call, longer than the NATS ack timeout: once it expires,
+	// another process may claim the sink
+	publishLease = 30 * time.Second
+)
+
+// Publisher feeds a sink with the delegations following its cursor, stored in the database.
+// The cursor only advances after a successful Publish, so every delegation is delivered at least once.
+// Processes publishing to the same sink take turns: one batch is published by a single process,
+// holding a lease on the sink.
+type Publisher struct {
+	pool   *pgxpool.Pool
+	name   string
+	holder string
+	sink   Sink
+	wake   chan struct{}
+}
+
+// NewPublisher creates a publisher for the sink identified by name
+func NewPublisher(pool *pgxpool.Pool, name string, sink Sink) *Publisher {
+	return &Publisher{
+		pool:   pool,
+		name:   name,
+		holder: newHolder(),
+		sink:   sink,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// newHolder returns a random identifier of a publisher for the sink leases
+func newHolder() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Notify wakes the publisher up, without blocking, after the indexer committed new delegations
+func (p *Publisher) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run publishes until ctx is cancelled, then closes the sink. Errors are logged and retried.
+func (p *Publisher) Run(ctx context.Context) error {
+	defer p.sink.Close()
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	initialized := false
+	for {
+		if !initialized {
+			cursor, err := db.InitSinkCursor(ctx, p.pool, p.name)
+			if err == nil {
+				initialized = true
+				metrics.SinkCursor.WithLabelValues(p.name).Set(float64(cursor))
+				slog.InfoContext(ctx, "Sink started", "sink", p.name, "cursor", cursor)
+			} else if ctx.Err() == nil {
+				slog.ErrorContext(ctx, "Failed to initialize sink cursor", "sink", p.name, "error", err)
+			}
+		}
+		if initialized {
+			p.drain(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-p.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain publishes batches until the sink caught up with the table or a batch fails
+func (p *Publisher) drain(ctx context.Context) {
+	for {
+		published, cursor, err := db.PublishNextBatch(ctx, p.pool, p.name, p.holder, batchSize, publishLease, p.sink.Publish)
+		if err != nil {
+			if ctx.Err() == nil {
+				metrics.SinkErrors.WithLabelValues(p.name).Inc()
+				slog.WarnContext(ctx, "Failed to publish to sink", "sink", p.name, "cursor", cursor, "error", err)
+			}
+			return
+		}
+		if published == 0 {
+			return
+		}
+
+		metrics.SinkPublishedRows.WithLabelValues(p.name).Add(float64(published))
+		metrics.SinkCursor.WithLabelValues(p.name).Set(float64(cursor))
+		slog.DebugContext(ctx, "Published to sink", "sink", p.name, "cursor", cursor, "rows", published)
+		if published < batchSize {
+			return
+		}
+	}
+}
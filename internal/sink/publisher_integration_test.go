@@ -0,0 +1,125 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/dbtest"
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// fakeSink records published ids and fails while failing is set
+type fakeSink struct {
+	mu        sync.Mutex
+	failing   bool
+	published []int64
+}
+
+func (s *fakeSink) Publish(_ context.Context, delegations []models.Delegation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failing {
+		return errors.New("unavailable")
+	}
+	for _, d := range delegations {
+		s.published = append(s.published, d.ID)
+	}
+	return nil
+}
+
+func (s *fakeSink) Close() error { return nil }
+
+func (s *fakeSink) ids() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]int64(nil), s.published...)
+}
+
+func insert(t *testing.T, pool *pgxpool.Pool, ids ...int64) {
+	t.Helper()
+	delegations := make([]models.Delegation, 0, len(ids))
+	for _, id := range ids {
+		delegations = append(delegations, models.Delegation{ID: id, Delegator: "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo", Timestamp: time.Now().UTC(), Amount: 1, Level: int32(id)})
+	}
+	if err := db.BulkInsertDelegations(context.Background(), pool, delegations); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIntegration_PublisherDeliversAtLeastOnce(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+
+	// A new sink starts after the delegations already indexed
+	insert(t, pool, 1, 2)
+	cursor, err := db.InitSinkCursor(ctx, pool, "test")
+	if err != nil || cursor != 2 {
+		t.Fatalf("InitSinkCursor() = %d, %v, want 2", cursor, err)
+	}
+
+	s := &fakeSink{failing: true}
+	insert(t, pool, 3, 4)
+
+	// A failed batch does not move the cursor
+	if _, _, err := db.PublishNextBatch(ctx, pool, "test", "a", 10, time.Minute, s.Publish); err == nil {
+		t.Fatal("PublishNextBatch() error = nil, want the sink error")
+	}
+	s.failing = false
+
+	// While another process holds the lease, nothing is published, and the cursor cannot advance
+	// once the lease expired during publish
+	var expired bool
+	if _, _, err := db.PublishNextBatch(ctx, pool, "test", "a", 10, time.Minute, func(ctx context.Context, _ []models.Delegation) error {
+		published, _, err := db.PublishNextBatch(ctx, pool, "test", "b", 10, time.Minute, s.Publish)
+		if err != nil || published != 0 {
+			t.Errorf("PublishNextBatch() while leased = %d, %v, want 0", published, err)
+		}
+		if _, err := pool.Exec(ctx, "UPDATE sink_cursors SET lease_expires_at = now() WHERE name = 'test'"); err != nil {
+			t.Fatal(err)
+		}
+		expired = true
+		return nil
+	}); err == nil || !expired {
+		t.Fatal("PublishNextBatch() error = nil, want the expired lease error")
+	}
+	if got := s.ids(); len(got) != 0 {
+		t.Fatalf("published %v while the sink was leased, want nothing", got)
+	}
+
+	p := NewPublisher(pool, "test", s)
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go p.Run(runCtx)
+
+	waitFor := func(want int) {
+		deadline := time.Now().Add(5 * time.Second)
+		for len(s.ids()) < want {
+			if time.Now().After(deadline) {
+				t.Fatalf("published %v, want %d delegations", s.ids(), want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitFor(2)
+
+	insert(t, pool, 5)
+	p.Notify()
+	waitFor(3)
+
+	if got := s.ids(); len(got) != 3 || got[0] != 3 || got[1] != 4 || got[2] != 5 {
+		t.Errorf("published %v, want [3 4 5]", got)
+	}
+
+	cursors, err := db.ListSinkCursors(ctx, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cursors) != 1 || cursors[0].Cursor != 5 || cursors[0].PublishedAt == nil {
+		t.Errorf("cursors = %+v, want test at 5", cursors)
+	}
+}
@@ -0,0 +1,55 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis appends one entry per delegation to a Redis stream, with fields "id" (the delegation id)
+// and "event" (the JSON event). Stream entry ids are assigned by Redis.
+type Redis struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+// NewRedis creates a client for the Redis server at url (redis://[:password@]host[:port][/db]).
+// When maxLen is positive, the stream is trimmed to about maxLen entries.
+func NewRedis(url, stream string, maxLen int64) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	return &Redis{client: redis.NewClient(opts), stream: stream, maxLen: maxLen}, nil
+}
+
+// Publish appends the batch in a single round trip
+func (s *Redis) Publish(ctx context.Context, delegations []models.Delegation) error {
+	pipe := s.client.Pipeline()
+	for _, d := range delegations {
+		data, err := encode(d)
+		if err != nil {
+			return err
+		}
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: s.stream,
+			MaxLen: s.maxLen,
+			Approx: s.maxLen > 0,
+			Values: []any{"id", strconv.FormatInt(d.ID, 10), "event", data},
+		})
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to append to stream %s: %w", s.stream, err)
+	}
+	return nil
+}
+
+// Close closes the client
+func (s *Redis) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,28 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/broyeztony/delegated/internal/models"
+)
+
+// Sink types
+const (
+	TypeNDJSON = "ndjson"
+	TypeNATS   = "nats"
+	TypeRedis  = "redis"
+)
+
+// Sink is a destination of the delegations committed by the indexer
+type Sink interface {
+	// Publish sends delegations, ordered by id, and returns once the destination has accepted them.
+	// A batch that failed is sent again, possibly partially delivered already: consumers deduplicate by id.
+	Publish(ctx context.Context, delegations []models.Delegation) error
+	Close() error
+}
+
+// encode returns the JSON of the event of a delegation
+func encode(d models.Delegation) ([]byte, error) {
+	return json.Marshal(models.NewEvent(d))
+}
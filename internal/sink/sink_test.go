@@ -0,0 +1,213 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/broyeztony/delegated/internal/models"
+	natsserver "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func testDelegations() []models.Delegation {
+	ts := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	return []models.Delegation{
+		{ID: 1, Delegator: "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo", Baker: "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM", Timestamp: ts, Amount: 125000000, Level: 5000000},
+		{ID: 2, Delegator: "tz1QMwsi5onCV9yR2VJsSCRBDsrSfzrViMss", Timestamp: ts, Amount: 1, Level: 5000001},
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "delegations.ndjson")
+
+	// Batches are appended, including across reopenings
+	for range 2 {
+		s, err := NewNDJSON(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Publish(context.Background(), testDelegations()); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var ids []int64
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event models.Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("line %q is not an event: %v", scanner.Text(), err)
+		}
+		ids = append(ids, event.ID)
+	}
+	if len(ids) != 4 || ids[0] != 1 || ids[3] != 2 {
+		t.Errorf("ids = %v, want [1 2 1 2]", ids)
+	}
+}
+
+// runJetStream starts an embedded NATS server with JetStream enabled and returns its URL
+func runJetStream(t *testing.T) string {
+	t.Helper()
+
+	opts := natsserver.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+	server := natsserver.RunServer(&opts)
+	t.Cleanup(server.Shutdown)
+	return server.ClientURL()
+}
+
+func TestNATS(t *testing.T) {
+	url := runJetStream(t)
+
+	sub, err := nats.Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	js, err := jetstream.New(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	stream, err := js.CreateStream(ctx, jetstream.StreamConfig{Name: "DELEGATIONS", Subjects: []string{"delegations"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewNATS(url, "delegations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Publish(ctx, testDelegations()); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	// A batch published again is deduplicated by its message ids
+	if err := s.Publish(ctx, testDelegations()); err != nil {
+		t.Fatalf("Publish() again error = %v", err)
+	}
+
+	info, err := stream.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := info.State.Msgs, uint64(len(testDelegations())); got != want {
+		t.Errorf("stream holds %d messages, want %d", got, want)
+	}
+
+	for i, want := range testDelegations() {
+		msg, err := stream.GetMsg(ctx, uint64(i+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var event models.Event
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			t.Fatal(err)
+		}
+		if event != models.NewEvent(want) {
+			t.Errorf("event = %+v, want %+v", event, models.NewEvent(want))
+		}
+		if got := msg.Header.Get(nats.MsgIdHdr); got != strconv.FormatInt(want.ID, 10) {
+			t.Errorf("%s = %q, want the delegation id %d", nats.MsgIdHdr, got, want.ID)
+		}
+	}
+}
+
+func TestNATS_FailsWithoutStream(t *testing.T) {
+	s, err := NewNATS(runJetStream(t), "delegations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// Nobody would receive the messages: the batch is not acknowledged
+	if err := s.Publish(context.Background(), testDelegations()); err == nil {
+		t.Errorf("Publish() error = nil without a stream bound to the subject")
+	}
+}
+
+func TestNATS_FailsWhileDisconnected(t *testing.T) {
+	opts := natsserver.DefaultTestOptions
+	opts.Port = -1
+	server := natsserver.RunServer(&opts)
+	url := server.ClientURL()
+	server.Shutdown()
+
+	// The connection is retried in the background instead of failing the startup
+	s, err := NewNATS(url, "delegations")
+	if err != nil {
+		t.Fatalf("NewNATS() error = %v, want a connection retried in the background", err)
+	}
+	defer s.Close()
+
+	if err := s.Publish(context.Background(), testDelegations()); err == nil {
+		t.Errorf("Publish() error = nil while the server is down")
+	}
+}
+
+func TestRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	s, err := NewRedis("redis://"+server.Addr(), "delegations", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if err := s.Publish(context.Background(), testDelegations()); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	entries, err := server.Stream("delegations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("stream has %d entries, want 2", len(entries))
+	}
+	values := entries[0].Values
+	if len(values) != 4 || values[0] != "id" || values[1] != "1" || values[2] != "event" {
+		t.Fatalf("entry = %v, want id and event fields", values)
+	}
+	var event models.Event
+	if err := json.Unmarshal([]byte(values[3]), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event != models.NewEvent(testDelegations()[0]) {
+		t.Errorf("event = %+v, want %+v", event, models.NewEvent(testDelegations()[0]))
+	}
+}
+
+func TestRedis_FailsWhenUnavailable(t *testing.T) {
+	server := miniredis.RunT(t)
+	s, err := NewRedis("redis://"+server.Addr(), "delegations", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	server.Close()
+
+	if err := s.Publish(context.Background(), testDelegations()); err == nil {
+		t.Errorf("Publish() error = nil while Redis is down")
+	}
+}
@@ -0,0 +1,144 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/parquet-go/parquet-go"
+)
+
+// rowGroupSize is the number of rows buffered in memory per Parquet row group
+const rowGroupSize = 250_000
+
+// rfc3339SQL formats the timestamp column (UTC, without time zone) like time.RFC3339
+const rfc3339SQL = `to_char(timestamp, 'YYYY-MM-DD"T"HH24:MI:SS"Z"')`
+
+// Export writes the delegations of r, ordered by id, to w in format and returns the number of rows.
+// CSV and NDJSON are produced by the server with COPY TO; Parquet is encoded here.
+func Export(ctx context.Context, pool *pgxpool.Pool, w io.Writer, format string, r Range) (int64, error) {
+	switch format {
+	case FormatCSV:
+		return copyTo(ctx, pool, w, fmt.Sprintf(
+			"COPY (SELECT id, delegator, %s, amount, level, baker, prev_baker FROM delegations%s ORDER BY id) TO STDOUT WITH (FORMAT csv, HEADER)",
+			rfc3339SQL, r.where()))
+	case FormatNDJSON:
+		// A single-column CSV whose quote and delimiter never occur in JSON outputs the objects verbatim,
+		// where the text format would escape backslashes
+		return copyTo(ctx, pool, w, fmt.Sprintf(
+			`COPY (SELECT json_build_object('id', id, 'delegator', delegator, 'timestamp', %s, 'amount', amount, 'level', level, 'baker', baker, 'prev_baker', prev_baker)
+			FROM delegations%s ORDER BY id) TO STDOUT WITH (FORMAT csv, QUOTE E'\x01', DELIMITER E'\x02')`,
+			rfc3339SQL, r.where()))
+	case FormatParquet:
+		return exportParquet(ctx, pool, w, r)
+	default:
+		return 0, ValidateFormat(format)
+	}
+}
+
+// where returns the SQL condition of r. COPY does not take parameters: the bounds are
+// inlined as literals, formatted from time values only.
+func (r Range) where() string {
+	var conditions []string
+	if !r.From.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("timestamp >= '%s'", r.From.UTC().Format(time.DateTime)))
+	}
+	if !r.To.IsZero() {
+		conditions = append(conditions, fmt.Sprintf("timestamp < '%s'", r.To.UTC().Format(time.DateTime)))
+	}
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+func copyTo(ctx context.Context, pool *pgxpool.Pool, w io.Writer, sql string) (int64, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	tag, err := conn.Conn().PgConn().CopyTo(ctx, w, sql)
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func exportParquet(ctx context.Context, pool *pgxpool.Pool, w io.Writer, r Range) (int64, error) {
+	rows, err := pool.Query(ctx, "SELECT id, delegator, timestamp, amount, level, baker, prev_baker FROM delegations"+r.where()+" ORDER BY id")
+	if err != nil {
+		return 0, fmt.Errorf("failed to query delegations: %w", err)
+	}
+	defer rows.Close()
+
+	pw := NewParquetWriter(w)
+	var count int64
+	for rows.Next() {
+		d, err := pgx.RowToStructByName[models.Delegation](rows)
+		if err != nil {
+			return count, fmt.Errorf("failed to read delegation: %w", err)
+		}
+		if err := pw.Write(d); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("failed to read delegations: %w", err)
+	}
+
+	return count, pw.Close()
+}
+
+// ParquetWriter encodes delegations as a zstd-compressed Parquet file with the schema of Record
+type ParquetWriter struct {
+	w     *parquet.GenericWriter[Record]
+	batch []Record
+}
+
+// NewParquetWriter starts a Parquet file on w
+func NewParquetWriter(w io.Writer) *ParquetWriter {
+	return &ParquetWriter{
+		w: parquet.NewGenericWriter[Record](w,
+			parquet.Compression(&parquet.Zstd),
+			parquet.MaxRowsPerRowGroup(rowGroupSize),
+			parquet.CreatedBy("delegated", "", ""),
+		),
+		batch: make([]Record, 0, 1024),
+	}
+}
+
+// Write adds a delegation
+func (p *ParquetWriter) Write(d models.Delegation) error {
+	p.batch = append(p.batch, NewRecord(d))
+	if len(p.batch) == cap(p.batch) {
+		return p.flush()
+	}
+	return nil
+}
+
+func (p *ParquetWriter) flush() error {
+	if _, err := p.w.Write(p.batch); err != nil {
+		return fmt.Errorf("failed to write parquet rows: %w", err)
+	}
+	p.batch = p.batch[:0]
+	return nil
+}
+
+// Close writes the remaining rows and the file footer
+func (p *ParquetWriter) Close() error {
+	if err := p.flush(); err != nil {
+		return err
+	}
+	if err := p.w.Close(); err != nil {
+		return fmt.Errorf("failed to close parquet file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/dbtest"
+	"github.com/broyeztony/delegated/internal/models"
+)
+
+func TestIntegration_Export(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+
+	delegations := []models.Delegation{
+		{ID: 1, Delegator: "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo", Baker: "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM", Timestamp: date(2024, 2, 29).Add(23 * time.Hour), Amount: 125896, Level: 1},
+		{ID: 2, Delegator: "tz1QMwsi5onCV9yR2VJsSCRBDsrSfzrViMss", Timestamp: date(2024, 3, 1).Add(time.Hour), Amount: 1, Level: 2},
+		{ID: 3, Delegator: "tz1QMwsi5onCV9yR2VJsSCRBDsrSfzrViMss", Timestamp: date(2024, 4, 1), Amount: 2, Level: 3},
+	}
+	if err := db.CopyInsertDelegations(ctx, pool, delegations); err != nil {
+		t.Fatal(err)
+	}
+	march := Range{From: date(2024, 3, 1), To: date(2024, 4, 1)}
+
+	var csv bytes.Buffer
+	rows, err := Export(ctx, pool, &csv, FormatCSV, march)
+	if err != nil || rows != 1 {
+		t.Fatalf("Export(csv) = %d, %v, want 1 row", rows, err)
+	}
+	want := "id,delegator,timestamp,amount,level,baker\n2,tz1QMwsi5onCV9yR2VJsSCRBDsrSfzrViMss,2024-03-01T01:00:00Z,1,2,\n"
+	if csv.String() != want {
+		t.Errorf("csv =\n%s\nwant\n%s", csv.String(), want)
+	}
+
+	var ndjson bytes.Buffer
+	rows, err = Export(ctx, pool, &ndjson, FormatNDJSON, Range{})
+	if err != nil || rows != 3 {
+		t.Fatalf("Export(ndjson) = %d, %v, want 3 rows", rows, err)
+	}
+	lines := strings.Split(strings.TrimSuffix(ndjson.String(), "\n"), "\n")
+	for i, line := range lines {
+		var r Record
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		if r.Delegation() != delegations[i] {
+			t.Errorf("line %d = %+v, want %+v", i, r.Delegation(), delegations[i])
+		}
+	}
+
+	var parquet bytes.Buffer
+	if rows, err = Export(ctx, pool, &parquet, FormatParquet, march); err != nil || rows != 1 {
+		t.Fatalf("Export(parquet) = %d, %v, want 1 row", rows, err)
+	}
+}
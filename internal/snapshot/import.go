@@ -0,0 +1,118 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Policies for delegations whose id is already in the table
+const (
+	// ConflictSkip keeps the existing row
+	ConflictSkip = "skip"
+	// ConflictUpdate overwrites the existing row
+	ConflictUpdate = "update"
+	// ConflictError aborts the import. Rows are copied straight into the table, the fastest path.
+	ConflictError = "error"
+)
+
+// ImportOptions configures Import
+type ImportOptions struct {
+	OnConflict string
+	BatchSize  int
+	// MaxInvalid is the number of invalid rows skipped before the import is aborted
+	MaxInvalid int64
+	// OnBatch is called after each written batch
+	OnBatch func(ImportStats)
+}
+
+// ImportStats counts the rows of an import
+type ImportStats struct {
+	// Read is the number of rows read, including invalid ones
+	Read int64
+	// Written is the number of rows inserted or updated
+	Written int64
+	// Unchanged is the number of valid rows not written: conflicts skipped, identical rows not updated
+	Unchanged int64
+	Invalid   int64
+	// Progress is the fraction of the file read
+	Progress float64
+}
+
+// Import loads the delegations of r in batches. Invalid rows are logged and skipped,
+// up to MaxInvalid of them. Batches written before an error stay written.
+func Import(ctx context.Context, pool *pgxpool.Pool, r Reader, opts ImportOptions) (ImportStats, error) {
+	var stats ImportStats
+	batch := make([]models.Delegation, 0, opts.BatchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		var written int64
+		var err error
+		switch opts.OnConflict {
+		case ConflictError:
+			err = db.CopyInsertDelegations(ctx, pool, batch)
+			written = int64(len(batch))
+		case ConflictSkip, ConflictUpdate:
+			written, err = db.CopyMergeDelegations(ctx, pool, batch, opts.OnConflict == ConflictUpdate)
+		default:
+			err = fmt.Errorf("unknown conflict policy %q", opts.OnConflict)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write batch: %w", err)
+		}
+
+		stats.Written += written
+		stats.Unchanged += int64(len(batch)) - written
+		stats.Progress = r.Progress()
+		batch = batch[:0]
+		if opts.OnBatch != nil {
+			opts.OnBatch(stats)
+		}
+		return nil
+	}
+
+	for {
+		d, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var rowErr *RowError
+		if err != nil && !errors.As(err, &rowErr) {
+			return stats, err
+		}
+
+		stats.Read++
+		if rowErr != nil {
+			stats.Invalid++
+			slog.WarnContext(ctx, "Skipping invalid row", "row", rowErr.Row, "error", rowErr.Err)
+			if stats.Invalid > opts.MaxInvalid {
+				return stats, fmt.Errorf("more than %d invalid rows, last one: %w", opts.MaxInvalid, rowErr)
+			}
+			continue
+		}
+
+		batch = append(batch, d)
+		if len(batch) == opts.BatchSize {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return stats, err
+	}
+	stats.Progress = 1
+	return stats, nil
+}
@@ -0,0 +1,81 @@
+package snapshot
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/dbtest"
+	"github.com/broyeztony/delegated/internal/models"
+)
+
+func TestIntegration_Import(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+
+	existing := models.Delegation{ID: 1, Delegator: testDelegator, Timestamp: date(2024, 3, 5), Amount: 1, Level: 1}
+	if err := db.CopyInsertDelegations(ctx, pool, []models.Delegation{existing}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Delegation 1 changed amount, 2 is new and duplicated, the last row is invalid
+	path := writeFile(t, "delegations.csv", strings.Join([]string{
+		"id,delegator,timestamp,amount,level,baker",
+		"1," + testDelegator + ",2024-03-05T00:00:00Z,5,1,",
+		"2," + testDelegator + ",2024-03-06T00:00:00Z,2,2," + testBaker,
+		"2," + testDelegator + ",2024-03-06T00:00:00Z,2,2," + testBaker,
+		"3," + testDelegator + ",2024-03-07T00:00:00Z,3,0,",
+	}, "\n")+"\n")
+
+	importFile := func(opts ImportOptions) (ImportStats, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		r, err := NewReader(f, FormatCSV)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.BatchSize = 2
+		return Import(ctx, pool, r, opts)
+	}
+	amount := func(id int64) int64 {
+		var amount int64
+		if err := pool.QueryRow(ctx, "SELECT amount FROM delegations WHERE id = $1", id).Scan(&amount); err != nil {
+			t.Fatal(err)
+		}
+		return amount
+	}
+
+	// The first batch is written before the invalid row aborts the import
+	if _, err := importFile(ImportOptions{OnConflict: ConflictSkip}); err == nil {
+		t.Error("expected an error with more invalid rows than --max-invalid")
+	}
+
+	stats, err := importFile(ImportOptions{OnConflict: ConflictSkip, MaxInvalid: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ImportStats{Read: 4, Written: 0, Unchanged: 3, Invalid: 1, Progress: 1}
+	if stats != want {
+		t.Errorf("skip stats = %+v, want %+v", stats, want)
+	}
+	if amount(1) != 1 || amount(2) != 2 {
+		t.Error("skip should keep existing rows")
+	}
+
+	stats, err = importFile(ImportOptions{OnConflict: ConflictUpdate, MaxInvalid: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Written != 1 || amount(1) != 5 {
+		t.Errorf("update stats = %+v, amount = %d, want 1 row written with amount 5", stats, amount(1))
+	}
+
+	if _, err := importFile(ImportOptions{OnConflict: ConflictError, MaxInvalid: 1}); err == nil {
+		t.Error("expected a primary key error")
+	}
+}
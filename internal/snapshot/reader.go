@@ -0,0 +1,278 @@
+package snapshot
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/parquet-go/parquet-go"
+)
+
+// Reader reads the delegations of a snapshot file one at a time
+type Reader interface {
+	// Read returns the next delegation, or io.EOF after the last one.
+	// A *RowError reports a row that could not be parsed or is not a valid delegation: reading can go on with the next row.
+	Read() (models.Delegation, error)
+	// Progress returns the fraction of the file read so far, between 0 and 1
+	Progress() float64
+}
+
+// RowError is an invalid row of a snapshot file
+type RowError struct {
+	// Row is the line number in CSV and NDJSON files, the 1-based row number in Parquet files
+	Row int64
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// NewReader reads a snapshot file in format
+func NewReader(file *os.File, format string) (Reader, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	switch format {
+	case FormatCSV:
+		return newCSVReader(file, info.Size())
+	case FormatNDJSON:
+		return newNDJSONReader(file, info.Size()), nil
+	case FormatParquet:
+		return newParquetReader(file, info.Size())
+	default:
+		return nil, ValidateFormat(format)
+	}
+}
+
+// timestampLayouts are the accepted timestamp formats: RFC 3339 and the text output of Postgres
+// (date and time separated by a space or T, optional fraction and offset). Times without offset are UTC.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+}
+
+// ParseTimestamp parses a timestamp in one of the accepted formats, returned in UTC
+func ParseTimestamp(value string) (time.Time, error) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("timestamp %q is neither RFC 3339 nor a Postgres timestamp", value)
+}
+
+// countingReader counts the bytes read, to report progress
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func fraction(done, total int64) float64 {
+	if total <= 0 {
+		return 1
+	}
+	return min(float64(done)/float64(total), 1)
+}
+
+// csvReader reads CSV files with a header row naming the columns, in any order.
+// The baker and prev_baker columns are optional, for files written before they were indexed.
+type csvReader struct {
+	r       *csv.Reader
+	counter *countingReader
+	size    int64
+	index   map[string]int
+}
+
+func newCSVReader(file io.Reader, size int64) (*csvReader, error) {
+	counter := &countingReader{r: file}
+	r := csv.NewReader(bufio.NewReaderSize(counter, 256<<10))
+	r.ReuseRecord = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[name] = i
+	}
+	for _, name := range Columns {
+		if _, ok := index[name]; !ok && name != "baker" && name != "prev_baker" {
+			return nil, fmt.Errorf("CSV header %v lacks column %s", header, name)
+		}
+	}
+
+	return &csvReader{r: r, counter: counter, size: size, index: index}, nil
+}
+
+func (c *csvReader) Read() (models.Delegation, error) {
+	record, err := c.r.Read()
+	var parseErr *csv.ParseError
+	switch {
+	case errors.As(err, &parseErr):
+		return models.Delegation{}, &RowError{Row: int64(parseErr.Line), Err: parseErr.Err}
+	case err != nil:
+		return models.Delegation{}, err
+	}
+
+	d, err := c.parse(record)
+	if err != nil {
+		line, _ := c.r.FieldPos(0)
+		return models.Delegation{}, &RowError{Row: int64(line), Err: err}
+	}
+	return d, nil
+}
+
+func (c *csvReader) parse(record []string) (models.Delegation, error) {
+	var d models.Delegation
+	var err error
+	if d.ID, err = strconv.ParseInt(record[c.index["id"]], 10, 64); err != nil {
+		return d, fmt.Errorf("invalid id: %w", err)
+	}
+	d.Delegator = record[c.index["delegator"]]
+	if d.Timestamp, err = ParseTimestamp(record[c.index["timestamp"]]); err != nil {
+		return d, err
+	}
+	if d.Amount, err = strconv.ParseInt(record[c.index["amount"]], 10, 64); err != nil {
+		return d, fmt.Errorf("invalid amount: %w", err)
+	}
+	level, err := strconv.ParseInt(record[c.index["level"]], 10, 32)
+	if err != nil {
+		return d, fmt.Errorf("invalid level: %w", err)
+	}
+	d.Level = int32(level)
+	if i, ok := c.index["baker"]; ok {
+		d.Baker = record[i]
+	}
+	if i, ok := c.index["prev_baker"]; ok {
+		d.PrevBaker = record[i]
+	}
+	return d, d.Validate()
+}
+
+func (c *csvReader) Progress() float64 {
+	return fraction(c.counter.n, c.size)
+}
+
+// ndjsonReader reads one JSON object per line with the fields of Record
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	counter *countingReader
+	size    int64
+	line    int64
+}
+
+// ndjsonRecord is a Record with the timestamp parsed by ParseTimestamp
+type ndjsonRecord struct {
+	Record
+	Timestamp string `json:"timestamp"`
+}
+
+func newNDJSONReader(file io.Reader, size int64) *ndjsonReader {
+	counter := &countingReader{r: file}
+	scanner := bufio.NewScanner(counter)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	return &ndjsonReader{scanner: scanner, counter: counter, size: size}
+}
+
+func (n *ndjsonReader) Read() (models.Delegation, error) {
+	for n.scanner.Scan() {
+		n.line++
+		if len(n.scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var r ndjsonRecord
+		if err := json.Unmarshal(n.scanner.Bytes(), &r); err != nil {
+			return models.Delegation{}, &RowError{Row: n.line, Err: err}
+		}
+		timestamp, err := ParseTimestamp(r.Timestamp)
+		if err != nil {
+			return models.Delegation{}, &RowError{Row: n.line, Err: err}
+		}
+		r.Record.Timestamp = timestamp
+		d := r.Delegation()
+		if err := d.Validate(); err != nil {
+			return models.Delegation{}, &RowError{Row: n.line, Err: err}
+		}
+		return d, nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return models.Delegation{}, err
+	}
+	return models.Delegation{}, io.EOF
+}
+
+func (n *ndjsonReader) Progress() float64 {
+	return fraction(n.counter.n, n.size)
+}
+
+// parquetReader reads Parquet files with the schema of Record
+type parquetReader struct {
+	r     *parquet.GenericReader[Record]
+	buf   []Record
+	next  int
+	read  int64
+	total int64
+}
+
+func newParquetReader(file io.ReaderAt, size int64) (*parquetReader, error) {
+	f, err := parquet.OpenFile(file, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Parquet file: %w", err)
+	}
+	r := parquet.NewGenericReader[Record](f)
+	return &parquetReader{r: r, total: r.NumRows()}, nil
+}
+
+func (p *parquetReader) Read() (models.Delegation, error) {
+	if p.next == len(p.buf) {
+		p.buf = p.buf[:cap(p.buf)]
+		if len(p.buf) == 0 {
+			p.buf = make([]Record, 1024)
+		}
+		n, err := p.r.Read(p.buf)
+		p.buf, p.next = p.buf[:n], 0
+		if n == 0 {
+			if err == nil || errors.Is(err, io.EOF) {
+				return models.Delegation{}, io.EOF
+			}
+			return models.Delegation{}, fmt.Errorf("failed to read Parquet rows: %w", err)
+		}
+	}
+
+	d := p.buf[p.next].Delegation()
+	p.next++
+	p.read++
+	if err := d.Validate(); err != nil {
+		return models.Delegation{}, &RowError{Row: p.read, Err: err}
+	}
+	return d, nil
+}
+
+func (p *parquetReader) Progress() float64 {
+	return fraction(p.read, p.total)
+}
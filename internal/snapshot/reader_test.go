@@ -0,0 +1,183 @@
+package snapshot
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/models"
+)
+
+const (
+	testDelegator = "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo"
+	testBaker     = "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM"
+)
+
+// readAll returns the delegations of a snapshot file and the rows of its row errors
+func readAll(t *testing.T, path, format string) ([]models.Delegation, []int64) {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	r, err := NewReader(f, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var delegations []models.Delegation
+	var invalid []int64
+	for {
+		d, err := r.Read()
+		var rowErr *RowError
+		switch {
+		case errors.Is(err, io.EOF):
+			if r.Progress() != 1 {
+				t.Errorf("progress at EOF = %v, want 1", r.Progress())
+			}
+			return delegations, invalid
+		case errors.As(err, &rowErr):
+			invalid = append(invalid, rowErr.Row)
+		case err != nil:
+			t.Fatal(err)
+		default:
+			delegations = append(delegations, d)
+		}
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseTimestamp(t *testing.T) {
+	want := time.Date(2024, 3, 5, 6, 29, 14, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2024-03-05T06:29:14Z", want},
+		{"2024-03-05T08:29:14+02:00", want},
+		{"2024-03-05 06:29:14", want},
+		{"2024-03-05T06:29:14", want},
+		{"2024-03-05 06:29:14.25", want.Add(250 * time.Millisecond)},
+		{"2024-03-05 07:29:14+01", want},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseTimestamp(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Errorf("ParseTimestamp(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseTimestamp("05/03/2024"); err == nil {
+		t.Error("expected an error for an unknown layout")
+	}
+}
+
+func TestReader_CSV(t *testing.T) {
+	// Columns in another order, no baker column, Postgres timestamps and two invalid rows
+	path := writeFile(t, "delegations.csv", `level,amount,timestamp,delegator,id
+5000000,125896,2024-03-05 06:29:14,`+testDelegator+`,1
+5000001,oops,2024-03-05 06:30:00,`+testDelegator+`,2
+5000002,7,2024-03-05 06:31:00,KT1notanaddress,3
+5000003,8,2024-03-05T06:32:00Z,`+testDelegator+`,4
+`)
+
+	delegations, invalid := readAll(t, path, FormatCSV)
+	want := []models.Delegation{
+		{ID: 1, Delegator: testDelegator, Timestamp: time.Date(2024, 3, 5, 6, 29, 14, 0, time.UTC), Amount: 125896, Level: 5000000},
+		{ID: 4, Delegator: testDelegator, Timestamp: time.Date(2024, 3, 5, 6, 32, 0, 0, time.UTC), Amount: 8, Level: 5000003},
+	}
+	if len(delegations) != len(want) {
+		t.Fatalf("read %d delegations, want %d", len(delegations), len(want))
+	}
+	for i := range want {
+		if delegations[i] != want[i] {
+			t.Errorf("delegation %d = %+v, want %+v", i, delegations[i], want[i])
+		}
+	}
+	if len(invalid) != 2 || invalid[0] != 3 || invalid[1] != 4 {
+		t.Errorf("invalid lines = %v, want [3 4]", invalid)
+	}
+}
+
+func TestReader_CSVMissingColumn(t *testing.T) {
+	path := writeFile(t, "delegations.csv", "id,delegator,timestamp,amount\n")
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := NewReader(f, FormatCSV); err == nil {
+		t.Error("expected an error for a header without level")
+	}
+}
+
+func TestReader_NDJSON(t *testing.T) {
+	path := writeFile(t, "delegations.ndjson", `{"id":1,"delegator":"`+testDelegator+`","timestamp":"2024-03-05T06:29:14Z","amount":125896,"level":5000000,"baker":"`+testBaker+`"}
+
+{"id":2,"delegator":"`+testDelegator+`","timestamp":"yesterday","amount":1,"level":5000001}
+{"id":3,"delegator":"`+testDelegator+`","timestamp":"2024-03-05 06:31:00","amount":1,"level":5000002}
+`)
+
+	delegations, invalid := readAll(t, path, FormatNDJSON)
+	if len(delegations) != 2 || delegations[0].Baker != testBaker || delegations[1].ID != 3 {
+		t.Errorf("delegations = %+v", delegations)
+	}
+	if len(invalid) != 1 || invalid[0] != 3 {
+		t.Errorf("invalid lines = %v, want [3]", invalid)
+	}
+}
+
+func TestReader_Parquet(t *testing.T) {
+	delegations := []models.Delegation{
+		{ID: 1, Delegator: testDelegator, Baker: testBaker, Timestamp: time.Date(2024, 3, 5, 6, 29, 14, 0, time.UTC), Amount: 125896, Level: 5000000},
+		{ID: 2, Delegator: testDelegator, Timestamp: time.Date(2024, 3, 5, 6, 30, 0, 0, time.UTC), Amount: -1, Level: 5000001},
+		{ID: 3, Delegator: testDelegator, Timestamp: time.Date(2024, 3, 5, 6, 31, 0, 0, time.UTC), Amount: 9007199254740993, Level: 5000002},
+	}
+
+	path := filepath.Join(t.TempDir(), "delegations.parquet")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewParquetWriter(f)
+	for _, d := range delegations {
+		if err := w.Write(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	got, invalid := readAll(t, path, FormatParquet)
+	if len(got) != 2 || got[0] != delegations[0] || got[1] != delegations[2] {
+		t.Errorf("delegations = %+v", got)
+	}
+	if len(invalid) != 1 || invalid[0] != 2 {
+		t.Errorf("invalid rows = %v, want [2]", invalid)
+	}
+}
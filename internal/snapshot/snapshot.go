@@ -0,0 +1,82 @@
+package snapshot
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/models"
+)
+
+// Snapshot file formats
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+)
+
+// Columns are the columns of CSV snapshots, in order, and the fields of NDJSON and Parquet records
+var Columns = []string{"id", "delegator", "timestamp", "amount", "level", "baker", "prev_baker"}
+
+// ValidateFormat fails unless format is a snapshot format
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatCSV, FormatNDJSON, FormatParquet:
+		return nil
+	}
+	return fmt.Errorf("format must be %s, %s or %s", FormatCSV, FormatNDJSON, FormatParquet)
+}
+
+// Record is a delegation as stored in NDJSON and Parquet snapshots: amounts in mutez,
+// timestamps in UTC (RFC 3339 in NDJSON, milliseconds in Parquet)
+type Record struct {
+	ID        int64     `json:"id" parquet:"id"`
+	Delegator string    `json:"delegator" parquet:"delegator,dict"`
+	Timestamp time.Time `json:"timestamp" parquet:"timestamp,timestamp(millisecond)"`
+	Amount    int64     `json:"amount" parquet:"amount"`
+	Level     int32     `json:"level" parquet:"level"`
+	Baker     string    `json:"baker" parquet:"baker,dict"`
+	PrevBaker string    `json:"prev_baker" parquet:"prev_baker,dict"`
+}
+
+// NewRecord converts a delegation to a record
+func NewRecord(d models.Delegation) Record {
+	return Record{ID: d.ID, Delegator: d.Delegator, Timestamp: d.Timestamp.UTC(), Amount: d.Amount, Level: d.Level, Baker: d.Baker, PrevBaker: d.PrevBaker}
+}
+
+// Delegation converts a record to a delegation
+func (r Record) Delegation() models.Delegation {
+	return models.Delegation{ID: r.ID, Delegator: r.Delegator, Timestamp: r.Timestamp.UTC(), Amount: r.Amount, Level: r.Level, Baker: r.Baker, PrevBaker: r.PrevBaker}
+}
+
+// Range selects delegations with From <= timestamp < To. A zero bound is open.
+type Range struct {
+	From time.Time
+	To   time.Time
+}
+
+// Months splits r into calendar months (UTC), clipped to r. Both bounds must be set.
+func (r Range) Months() []Range {
+	var months []Range
+	start := r.From.UTC()
+	for start.Before(r.To) {
+		next := time.Date(start.Year(), start.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		if next.After(r.To) {
+			next = r.To.UTC()
+		}
+		months = append(months, Range{From: start, To: next})
+		start = next
+	}
+	return months
+}
+
+// PartitionPath returns the file of a month in a hive-style layout:
+// dir/year=YYYY/month=MM/delegations-<from>-<to>.<format>, with from and to as YYYYMMDD (to excluded),
+// so that successive daily exports add files to the month instead of replacing it
+func PartitionPath(dir string, month Range, format string) string {
+	name := fmt.Sprintf("delegations-%s-%s.%s", month.From.Format("20060102"), month.To.Format("20060102"), format)
+	return filepath.Join(dir,
+		fmt.Sprintf("year=%04d", month.From.Year()),
+		fmt.Sprintf("month=%02d", int(month.From.Month())),
+		name)
+}
@@ -0,0 +1,129 @@
+package snapshot
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/parquet-go/parquet-go"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestRange_Months(t *testing.T) {
+	tests := []struct {
+		name string
+		r    Range
+		want []Range
+	}{
+		{
+			name: "one day",
+			r:    Range{From: date(2024, 3, 5), To: date(2024, 3, 6)},
+			want: []Range{{From: date(2024, 3, 5), To: date(2024, 3, 6)}},
+		},
+		{
+			name: "across a year",
+			r:    Range{From: date(2023, 12, 15), To: date(2024, 2, 10)},
+			want: []Range{
+				{From: date(2023, 12, 15), To: date(2024, 1, 1)},
+				{From: date(2024, 1, 1), To: date(2024, 2, 1)},
+				{From: date(2024, 2, 1), To: date(2024, 2, 10)},
+			},
+		},
+		{
+			name: "whole month",
+			r:    Range{From: date(2024, 2, 1), To: date(2024, 3, 1)},
+			want: []Range{{From: date(2024, 2, 1), To: date(2024, 3, 1)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.r.Months()
+			if len(got) != len(tt.want) {
+				t.Fatalf("Months() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].From.Equal(tt.want[i].From) || !got[i].To.Equal(tt.want[i].To) {
+					t.Errorf("Months()[%d] = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPartitionPath(t *testing.T) {
+	got := PartitionPath("/lake", Range{From: date(2024, 3, 5), To: date(2024, 3, 6)}, FormatParquet)
+	want := "/lake/year=2024/month=03/delegations-20240305-20240306.parquet"
+	if got != want {
+		t.Errorf("PartitionPath() = %s, want %s", got, want)
+	}
+}
+
+func TestRange_Where(t *testing.T) {
+	tests := []struct {
+		r    Range
+		want string
+	}{
+		{r: Range{}, want: ""},
+		{r: Range{From: date(2024, 3, 5)}, want: " WHERE timestamp >= '2024-03-05 00:00:00'"},
+		{
+			r:    Range{From: date(2024, 3, 5), To: time.Date(2024, 3, 6, 12, 0, 0, 0, time.FixedZone("CET", 3600))},
+			want: " WHERE timestamp >= '2024-03-05 00:00:00' AND timestamp < '2024-03-06 11:00:00'",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.r.where(); got != tt.want {
+			t.Errorf("where() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	delegations := []models.Delegation{
+		{ID: 1, Delegator: "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo", Baker: "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM", Timestamp: time.Date(2024, 3, 5, 6, 29, 14, 0, time.UTC), Amount: 125896, Level: 5000000},
+		{ID: 2, Delegator: "tz1QMwsi5onCV9yR2VJsSCRBDsrSfzrViMss", Timestamp: time.Date(2024, 3, 5, 6, 30, 0, 0, time.UTC), Amount: 9007199254740993, Level: 5000001},
+	}
+
+	var buf bytes.Buffer
+	w := NewParquetWriter(&buf)
+	for _, d := range delegations {
+		if err := w.Write(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The schema has typed columns
+	file, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, column := range []struct {
+		name    string
+		logical string
+	}{{"amount", "INT(64,true)"}, {"timestamp", "TIMESTAMP(isAdjustedToUTC=true,unit=MILLIS)"}, {"delegator", "STRING"}} {
+		leaf, ok := file.Schema().Lookup(column.name)
+		if !ok {
+			t.Fatalf("column %s missing", column.name)
+		}
+		if got := leaf.Node.Type().String(); got != column.logical {
+			t.Errorf("column %s has type %s, want %s", column.name, got, column.logical)
+		}
+	}
+
+	records := make([]Record, len(delegations)+1)
+	n, _ := parquet.NewGenericReader[Record](bytes.NewReader(buf.Bytes())).Read(records)
+	if n != len(delegations) {
+		t.Fatalf("read %d records, want %d", n, len(delegations))
+	}
+	for i, d := range delegations {
+		if got := records[i].Delegation(); got != d {
+			t.Errorf("record %d = %+v, want %+v", i, got, d)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package stream
+
+import "github.com/broyeztony/delegated/internal/models"
+
+// Filter selects the delegations sent to a subscriber. Zero values match everything.
+type Filter struct {
+	Delegator string
+	MinAmount int64
+}
+
+// Match reports whether d passes the filter
+func (f Filter) Match(d models.Delegation) bool {
+	if f.Delegator != "" && d.Delegator != f.Delegator {
+		return false
+	}
+	return d.Amount >= f.MinAmount
+}
@@ -0,0 +1,85 @@
+package stream
+
+import (
+	"sync"
+
+	"github.com/broyeztony/delegated/internal/models"
+)
+
+// subscriberBuffer is the number of batches a subscriber can lag behind before being dropped
+const subscriberBuffer = 64
+
+// Hub fans out newly indexed delegations to the subscribers of the live feed.
+// A subscriber that does not keep up is dropped (its channel is closed) rather than
+// slowing down the publisher; clients reconnect and resume from their last event id.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[chan []models.Delegation]struct{}
+	closed      bool
+}
+
+// NewHub creates a hub without subscribers
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[chan []models.Delegation]struct{})}
+}
+
+// Subscribe returns a channel receiving every published batch, and a function to unsubscribe.
+// The channel is closed when the subscriber is dropped or the hub is closed.
+func (h *Hub) Subscribe() (<-chan []models.Delegation, func()) {
+	ch := make(chan []models.Delegation, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.closed {
+		close(ch)
+	} else {
+		h.subscribers[ch] = struct{}{}
+	}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subscribers[ch]; ok {
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Publish sends a batch of delegations, ordered by id, to every subscriber without blocking
+func (h *Hub) Publish(delegations []models.Delegation) {
+	if len(delegations) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for ch := range h.subscribers {
+		select {
+		case ch <- delegations:
+		default:
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscribers returns the number of connected subscribers
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers)
+}
+
+// Close disconnects every subscriber, so that long-lived streams end on shutdown
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for ch := range h.subscribers {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
@@ -0,0 +1,101 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/broyeztony/delegated/internal/models"
+)
+
+func batch(ids ...int64) []models.Delegation {
+	delegations := make([]models.Delegation, len(ids))
+	for i, id := range ids {
+		delegations[i] = models.Delegation{ID: id}
+	}
+	return delegations
+}
+
+func TestHub_Publish(t *testing.T) {
+	hub := NewHub()
+	a, unsubscribeA := hub.Subscribe()
+	b, unsubscribeB := hub.Subscribe()
+	defer unsubscribeA()
+
+	hub.Publish(batch(1, 2))
+	hub.Publish(nil)
+
+	for _, ch := range []<-chan []models.Delegation{a, b} {
+		if got := <-ch; len(got) != 2 || got[1].ID != 2 {
+			t.Errorf("received %v, want ids 1 and 2", got)
+		}
+		if len(ch) != 0 {
+			t.Errorf("empty batch was published")
+		}
+	}
+
+	unsubscribeB()
+	unsubscribeB()
+	if _, ok := <-b; ok {
+		t.Error("channel still open after unsubscribe")
+	}
+	if n := hub.Subscribers(); n != 1 {
+		t.Errorf("Subscribers() = %d, want 1", n)
+	}
+}
+
+func TestHub_DropsSlowSubscriber(t *testing.T) {
+	hub := NewHub()
+	slow, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		hub.Publish(batch(int64(i)))
+	}
+
+	received := 0
+	for range slow {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("received %d batches before being dropped, want %d", received, subscriberBuffer)
+	}
+	if n := hub.Subscribers(); n != 0 {
+		t.Errorf("Subscribers() = %d, want 0", n)
+	}
+}
+
+func TestHub_Close(t *testing.T) {
+	hub := NewHub()
+	before, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+
+	hub.Close()
+	after, unsubscribeAfter := hub.Subscribe()
+	defer unsubscribeAfter()
+
+	for _, ch := range []<-chan []models.Delegation{before, after} {
+		if _, ok := <-ch; ok {
+			t.Error("channel still open after Close")
+		}
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	d := models.Delegation{Delegator: "tz1abc", Amount: 500}
+
+	tests := []struct {
+		filter Filter
+		want   bool
+	}{
+		{filter: Filter{}, want: true},
+		{filter: Filter{Delegator: "tz1abc"}, want: true},
+		{filter: Filter{Delegator: "tz1xyz"}, want: false},
+		{filter: Filter{MinAmount: 500}, want: true},
+		{filter: Filter{MinAmount: 501}, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.filter.Match(d); got != tt.want {
+			t.Errorf("%+v.Match() = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
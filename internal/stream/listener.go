@@ -0,0 +1,147 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	// catchUpPageSize is the number of new rows read per query when catching up
+	catchUpPageSize = 1000
+
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = 30 * time.Second
+)
+
+// Listener publishes delegations inserted by an indexer running in another process.
+// It LISTENs on db.DelegationsChannel, where the indexer announces the id range of every
+// committed batch, and reads the new rows once per notification.
+// Notifications sent while disconnected are lost, so it also checks the max id through
+// the primary key index after every (re)connection and after checkInterval without notification.
+type Listener struct {
+	pool          *pgxpool.Pool
+	hub           *Hub
+	checkInterval time.Duration
+	lastID        int64
+	// started is set once the max id at startup is known
+	started atomic.Bool
+}
+
+// NewListener creates a listener publishing to hub
+func NewListener(pool *pgxpool.Pool, hub *Hub, checkInterval time.Duration) *Listener {
+	return &Listener{
+		pool:          pool,
+		hub:           hub,
+		checkInterval: checkInterval,
+	}
+}
+
+// Run listens until ctx is cancelled, reconnecting with exponential backoff.
+// Rows present at startup are not published.
+func (l *Listener) Run(ctx context.Context) {
+	backoff := minReconnectBackoff
+	for {
+		connected, err := l.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = minReconnectBackoff
+		}
+
+		slog.WarnContext(ctx, "Notification listener disconnected, reconnecting", "error", err, "backoff", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, maxReconnectBackoff)
+	}
+}
+
+// listen holds a dedicated connection listening on the channel until it fails or ctx is cancelled.
+// It reports whether LISTEN succeeded.
+func (l *Listener) listen(ctx context.Context) (bool, error) {
+	pooled, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	// The connection keeps its LISTEN state, so it is taken out of the pool and closed afterwards
+	conn := pooled.Hijack()
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{db.DelegationsChannel}.Sanitize()); err != nil {
+		return false, err
+	}
+	slog.InfoContext(ctx, "Listening for new delegations", "channel", db.DelegationsChannel)
+
+	// Catch up on what was committed while disconnected
+	l.catchUp(ctx, 0)
+
+	for {
+		waitCtx, cancel := context.WithTimeout(ctx, l.checkInterval)
+		notification, err := conn.WaitForNotification(waitCtx)
+		cancel()
+
+		switch {
+		case ctx.Err() != nil:
+			return true, nil
+		case errors.Is(err, context.DeadlineExceeded):
+			// No notification for a while: check the max id in case one was missed
+			l.catchUp(ctx, 0)
+		case err != nil:
+			return true, err
+		default:
+			n, err := db.ParseNotification(notification.Payload)
+			if err != nil {
+				slog.WarnContext(ctx, "Invalid notification payload", "payload", notification.Payload, "error", err)
+			}
+			l.catchUp(ctx, n.ToID)
+		}
+	}
+}
+
+// catchUp publishes the rows after the last published id, up to target.
+// A zero target is resolved by reading the current max id.
+func (l *Listener) catchUp(ctx context.Context, target int64) {
+	if err := l.publishUpTo(ctx, target); err != nil && ctx.Err() == nil {
+		slog.WarnContext(ctx, "Failed to read new delegations", "cursor", l.lastID, "error", err)
+	}
+}
+
+func (l *Listener) publishUpTo(ctx context.Context, target int64) error {
+	if target == 0 || !l.started.Load() {
+		maxID, err := db.GetLatestID(ctx, l.pool)
+		if err != nil {
+			return err
+		}
+		if !l.started.Load() {
+			l.lastID = maxID
+			l.started.Store(true)
+			return nil
+		}
+		target = maxID
+	}
+
+	for l.lastID < target {
+		delegations, err := db.GetDelegationsAfter(ctx, l.pool, l.lastID, catchUpPageSize)
+		if err != nil {
+			return err
+		}
+		if len(delegations) == 0 {
+			break
+		}
+
+		l.hub.Publish(delegations)
+		l.lastID = delegations[len(delegations)-1].ID
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package stream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/dbtest"
+	"github.com/broyeztony/delegated/internal/models"
+)
+
+func delegation(id int64) models.Delegation {
+	return models.Delegation{ID: id, Delegator: "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo", Timestamp: time.Now().UTC(), Amount: 1, Level: int32(id)}
+}
+
+func TestIntegration_ListenerPublishesNotifiedBatches(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := db.BulkInsertDelegations(ctx, pool, []models.Delegation{delegation(1)}); err != nil {
+		t.Fatal(err)
+	}
+
+	hub := NewHub()
+	updates, unsubscribe := hub.Subscribe()
+	defer unsubscribe()
+
+	// A long check interval: only the notification can trigger the read
+	listener := NewListener(pool, hub, time.Hour)
+	go listener.Run(ctx)
+
+	// Wait until the listener has read the max id present at startup
+	deadline := time.Now().Add(5 * time.Second)
+	for !listener.started.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("listener did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := db.BulkInsertDelegations(ctx, pool, []models.Delegation{delegation(2), delegation(3)}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-updates:
+		if len(got) != 2 || got[0].ID != 2 || got[1].ID != 3 {
+			t.Errorf("published %v, want ids 2 and 3", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no batch published after NOTIFY")
+	}
+}
@@ -0,0 +1,96 @@
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Component is a long-running part of the process supervised by a Supervisor
+type Component struct {
+	Name string
+	// Run blocks until ctx is cancelled or the component fails.
+	// Returning nil while ctx is still active means the component is done and is not restarted.
+	Run func(ctx context.Context) error
+}
+
+// Supervisor runs components concurrently and restarts the ones that fail
+// (return an error or panic) with exponential backoff
+type Supervisor struct {
+	components []Component
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// New creates a supervisor with the given backoff bounds
+func New(minBackoff, maxBackoff time.Duration, components ...Component) *Supervisor {
+	return &Supervisor{
+		components: components,
+		minBackoff: minBackoff,
+		maxBackoff: maxBackoff,
+	}
+}
+
+// Run starts every component and blocks until ctx is cancelled and all components have returned
+func (s *Supervisor) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, c := range s.components {
+		wg.Add(1)
+		go func(c Component) {
+			defer wg.Done()
+			s.supervise(ctx, c)
+		}(c)
+	}
+	wg.Wait()
+}
+
+// supervise runs a single component, restarting it on failure until ctx is cancelled
+func (s *Supervisor) supervise(ctx context.Context, c Component) {
+	backoff := s.minBackoff
+
+	for {
+		start := time.Now()
+		err := runSafely(ctx, c)
+
+		if ctx.Err() != nil {
+			slog.Info("Component stopped", "component", c.Name)
+			return
+		}
+		if err == nil {
+			slog.Info("Component completed", "component", c.Name)
+			return
+		}
+
+		// A component that ran for a while before failing starts over with the minimum backoff
+		if time.Since(start) > s.maxBackoff {
+			backoff = s.minBackoff
+		}
+
+		slog.Error("Component failed, restarting", "component", c.Name, "error", err, "backoff", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			slog.Info("Component stopped", "component", c.Name)
+			return
+		}
+
+		backoff *= 2
+		if backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+	}
+}
+
+// runSafely runs the component, turning a panic into an error
+func runSafely(ctx context.Context, c Component) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	slog.Info("Component starting", "component", c.Name)
+	return c.Run(ctx)
+}
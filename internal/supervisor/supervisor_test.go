@@ -0,0 +1,92 @@
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSupervisor_RestartsFailingComponent(t *testing.T) {
+	var attempts atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	flaky := Component{
+		Name: "flaky",
+		Run: func(ctx context.Context) error {
+			if attempts.Add(1) < 3 {
+				return errors.New("boom")
+			}
+			cancel()
+			<-ctx.Done()
+			return nil
+		},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		New(time.Millisecond, 10*time.Millisecond, flaky).Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run() did not return")
+	}
+
+	if got := attempts.Load(); got != 3 {
+		t.Errorf("attempts = %d, want 3", got)
+	}
+}
+
+func TestSupervisor_RecoversPanics(t *testing.T) {
+	var attempts atomic.Int32
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	panicky := Component{
+		Name: "panicky",
+		Run: func(ctx context.Context) error {
+			if attempts.Add(1) == 1 {
+				panic("unexpected")
+			}
+			return nil
+		},
+	}
+
+	New(time.Millisecond, 10*time.Millisecond, panicky).Run(ctx)
+
+	if got := attempts.Load(); got != 2 {
+		t.Errorf("attempts = %d, want 2", got)
+	}
+}
+
+func TestSupervisor_CompletedComponentIsNotRestarted(t *testing.T) {
+	var attempts atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+
+	oneShot := Component{
+		Name: "one-shot",
+		Run: func(ctx context.Context) error {
+			attempts.Add(1)
+			return nil
+		},
+	}
+	longRunning := Component{
+		Name: "long-running",
+		Run: func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		},
+	}
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+	New(time.Millisecond, 10*time.Millisecond, oneShot, longRunning).Run(ctx)
+
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+}
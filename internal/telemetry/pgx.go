@@ -0,0 +1,77 @@
+package telemetry
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PgxTracer creates a client span for every query, batch and COPY run through pgx,
+// so SQL timings show up under the span of the caller
+type PgxTracer struct{}
+
+var (
+	_ pgx.QueryTracer    = PgxTracer{}
+	_ pgx.BatchTracer    = PgxTracer{}
+	_ pgx.CopyFromTracer = PgxTracer{}
+)
+
+func startDBSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) context.Context {
+	attrs = append(attrs, semconv.DBSystemPostgreSQL)
+	ctx, _ = Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+	return ctx
+}
+
+func endDBSpan(ctx context.Context, err error, rowsAffected int64) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int64("db.rows_affected", rowsAffected))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// operationName returns the SQL verb used as span name, e.g. "SELECT"
+func operationName(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "query"
+	}
+	return strings.ToUpper(fields[0])
+}
+
+func (PgxTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return startDBSpan(ctx, "db "+operationName(data.SQL), semconv.DBQueryText(data.SQL))
+}
+
+func (PgxTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	endDBSpan(ctx, data.Err, data.CommandTag.RowsAffected())
+}
+
+func (PgxTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchStartData) context.Context {
+	return startDBSpan(ctx, "db batch", attribute.Int("db.batch.size", data.Batch.Len()))
+}
+
+// TraceBatchQuery is a no-op: per-statement spans of large batches add noise without timing information
+func (PgxTracer) TraceBatchQuery(context.Context, *pgx.Conn, pgx.TraceBatchQueryData) {}
+
+func (PgxTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	endDBSpan(ctx, data.Err, 0)
+}
+
+func (PgxTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	return startDBSpan(ctx, "db COPY", semconv.DBCollectionName(data.TableName.Sanitize()))
+}
+
+func (PgxTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	endDBSpan(ctx, data.Err, data.CommandTag.RowsAffected())
+}
@@ -0,0 +1,53 @@
+package tzkt
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/broyeztony/delegated/internal/models"
+)
+
+// maxLimit is the largest page TzKT serves
+const maxLimit = 10000
+
+// CyclesURL derives the /v1/cycles endpoint from the delegations endpoint
+func CyclesURL(delegationsURL string) string {
+	return baseURL(delegationsURL) + "/cycles"
+}
+
+// ProtocolsURL derives the /v1/protocols endpoint from the delegations endpoint
+func ProtocolsURL(delegationsURL string) string {
+	return baseURL(delegationsURL) + "/protocols"
+}
+
+// FetchCycles fetches all the cycles known to TzKT, including the upcoming ones, ordered by index
+func FetchCycles(ctx context.Context, client *http.Client, url string) ([]models.Cycle, error) {
+	var cycles []models.Cycle
+	for {
+		var page []models.Cycle
+		query := "?sort.asc=index&limit=" + strconv.Itoa(maxLimit) + "&offset=" + strconv.Itoa(len(cycles))
+		if err := getJSON(ctx, client, url+query, &page); err != nil {
+			return nil, err
+		}
+		cycles = append(cycles, page...)
+		if len(page) < maxLimit {
+			return cycles, nil
+		}
+	}
+}
+
+// FetchProtocols fetches all the protocols known to TzKT, ordered by first level
+func FetchProtocols(ctx context.Context, client *http.Client, url string) ([]models.Protocol, error) {
+	var protocols []models.Protocol
+	if err := getJSON(ctx, client, url+"?sort.asc=firstLevel&limit="+strconv.Itoa(maxLimit), &protocols); err != nil {
+		return nil, err
+	}
+	for _, p := range protocols {
+		if p.Hash == "" {
+			return nil, fmt.Errorf("protocol %d has no hash", p.Code)
+		}
+	}
+	return protocols, nil
+}
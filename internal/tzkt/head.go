@@ -0,0 +1,75 @@
+package tzkt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Head is the subset of the TzKT chain head we use to measure indexing lag
+type Head struct {
+	Level     int32     `json:"level"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// HeadURL derives the /v1/head endpoint from an operations endpoint,
+// e.g. https://api.tzkt.io/v1/operations/delegations -> https://api.tzkt.io/v1/head
+func HeadURL(operationsURL string) string {
+	return baseURL(operationsURL) + "/head"
+}
+
+// StakingURL derives the staking operations endpoint from the delegations endpoint,
+// e.g. https://api.tzkt.io/v1/operations/delegations -> https://api.tzkt.io/v1/operations/staking
+func StakingURL(delegationsURL string) string {
+	return baseURL(delegationsURL) + "/operations/staking"
+}
+
+// baseURL strips /operations/<type> from an operations endpoint, e.g. https://api.tzkt.io/v1
+func baseURL(operationsURL string) string {
+	base := strings.TrimSuffix(operationsURL, "/")
+	if i := strings.LastIndex(base, "/operations/"); i >= 0 {
+		base = base[:i]
+	}
+	return base
+}
+
+// FetchHead fetches the current chain head from TzKT
+func FetchHead(ctx context.Context, client *http.Client, url string) (*Head, error) {
+	var head Head
+	if err := getJSON(ctx, client, url, &head); err != nil {
+		return nil, err
+	}
+	return &head, nil
+}
+
+// getJSON fetches url and decodes its JSON body into v
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d: %s", response.StatusCode, body)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to unmarshal %s: %w", url, err)
+	}
+
+	return nil
+}
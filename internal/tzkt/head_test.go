@@ -0,0 +1,109 @@
+package tzkt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/broyeztony/delegated/internal/tzktmock"
+)
+
+func TestHeadURL(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"https://api.tzkt.io/v1/operations/delegations", "https://api.tzkt.io/v1/head"},
+		{"https://api.tzkt.io/v1/operations/delegations/", "https://api.tzkt.io/v1/head"},
+		{"http://localhost:8081/v1/operations/delegations", "http://localhost:8081/v1/head"},
+		{"https://api.tzkt.io/v1/operations/staking", "https://api.tzkt.io/v1/head"},
+	}
+
+	for _, tt := range tests {
+		if got := HeadURL(tt.in); got != tt.want {
+			t.Errorf("HeadURL(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFetchHead(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock := tzktmock.New([]models.Delegation{{ID: 1, Level: 42, Timestamp: ts}}, tzktmock.Options{})
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	head, err := FetchHead(context.Background(), http.DefaultClient, srv.URL+"/v1/head")
+	if err != nil {
+		t.Fatalf("FetchHead() error = %v", err)
+	}
+	if head.Level != 42 || !head.Timestamp.Equal(ts) {
+		t.Errorf("head = %+v, want level 42 at %v", head, ts)
+	}
+
+	if _, err := FetchHead(context.Background(), http.DefaultClient, srv.URL+"/v1/missing"); err == nil {
+		t.Error("FetchHead() on a missing endpoint error = nil, want error")
+	}
+}
+
+func TestStakingURL(t *testing.T) {
+	got := StakingURL("https://api.tzkt.io/v1/operations/delegations")
+	if want := "https://api.tzkt.io/v1/operations/staking"; got != want {
+		t.Errorf("StakingURL() = %q, want %q", got, want)
+	}
+}
+
+func TestChainURLs(t *testing.T) {
+	tests := []struct {
+		in            string
+		wantCycles    string
+		wantProtocols string
+	}{
+		{"https://api.tzkt.io/v1/operations/delegations", "https://api.tzkt.io/v1/cycles", "https://api.tzkt.io/v1/protocols"},
+		{"http://localhost:8081/v1/operations/delegations/", "http://localhost:8081/v1/cycles", "http://localhost:8081/v1/protocols"},
+	}
+
+	for _, tt := range tests {
+		if got := CyclesURL(tt.in); got != tt.wantCycles {
+			t.Errorf("CyclesURL(%q) = %q, want %q", tt.in, got, tt.wantCycles)
+		}
+		if got := ProtocolsURL(tt.in); got != tt.wantProtocols {
+			t.Errorf("ProtocolsURL(%q) = %q, want %q", tt.in, got, tt.wantProtocols)
+		}
+	}
+}
+
+func TestFetchCyclesAndProtocols(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	level := int32(3*tzktmock.BlocksPerCycle + 10)
+	mock := tzktmock.New([]models.Delegation{{ID: 1, Level: level, Timestamp: ts}}, tzktmock.Options{})
+	srv := httptest.NewServer(mock)
+	defer srv.Close()
+
+	cycles, err := FetchCycles(context.Background(), http.DefaultClient, srv.URL+"/v1/cycles")
+	if err != nil {
+		t.Fatalf("FetchCycles() error = %v", err)
+	}
+	// Cycles 0 to 3, then the upcoming cycle 4
+	if len(cycles) != 5 {
+		t.Fatalf("got %d cycles, want 5", len(cycles))
+	}
+	for i, c := range cycles {
+		if c.Index != int32(i) || c.LastLevel-c.FirstLevel+1 != tzktmock.BlocksPerCycle {
+			t.Errorf("cycles[%d] = %+v, want index %d of %d blocks", i, c, i, tzktmock.BlocksPerCycle)
+		}
+	}
+	if c := cycles[3]; level < c.FirstLevel || level > c.LastLevel || c.StartTime.After(ts) || c.EndTime.Before(ts) {
+		t.Errorf("cycles[3] = %+v, want it to contain level %d at %v", c, level, ts)
+	}
+
+	protocols, err := FetchProtocols(context.Background(), http.DefaultClient, srv.URL+"/v1/protocols")
+	if err != nil {
+		t.Fatalf("FetchProtocols() error = %v", err)
+	}
+	if len(protocols) != 1 || protocols[0].BlocksPerCycle != tzktmock.BlocksPerCycle || protocols[0].LastLevel != nil {
+		t.Errorf("protocols = %+v, want the current protocol with %d blocks per cycle", protocols, tzktmock.BlocksPerCycle)
+	}
+}
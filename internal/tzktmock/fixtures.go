@@ -0,0 +1,210 @@
+package tzktmock
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/broyeztony/delegated/internal/seed"
+)
+
+// Delegation is the TzKT wire format of a delegation operation
+type Delegation struct {
+	Type         string    `json:"type"`
+	ID           int64     `json:"id"`
+	Level        int32     `json:"level"`
+	Timestamp    time.Time `json:"timestamp"`
+	Sender       Account   `json:"sender"`
+	NewDelegate  *Account  `json:"newDelegate"`
+	PrevDelegate *Account  `json:"prevDelegate"`
+	Amount       int64     `json:"amount"`
+	Status       string    `json:"status"`
+}
+
+// StakingOp is the TzKT wire format of a staking operation
+type StakingOp struct {
+	Type      string    `json:"type"`
+	ID        int64     `json:"id"`
+	Level     int32     `json:"level"`
+	Timestamp time.Time `json:"timestamp"`
+	Sender    Account   `json:"sender"`
+	Baker     *Account  `json:"baker"`
+	Action    string    `json:"action"`
+	Amount    int64     `json:"amount"`
+	Status    string    `json:"status"`
+}
+
+// Account is a TzKT account reference
+type Account struct {
+	Address string `json:"address"`
+}
+
+// Head is the TzKT wire format of the chain head (subset)
+type Head struct {
+	Level     int32     `json:"level"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Cycle is the TzKT wire format of a cycle (subset)
+type Cycle struct {
+	Index      int32     `json:"index"`
+	FirstLevel int32     `json:"firstLevel"`
+	LastLevel  int32     `json:"lastLevel"`
+	StartTime  time.Time `json:"startTime"`
+	EndTime    time.Time `json:"endTime"`
+}
+
+// Protocol is the TzKT wire format of a protocol (subset)
+type Protocol struct {
+	Code       int32             `json:"code"`
+	Hash       string            `json:"hash"`
+	FirstLevel int32             `json:"firstLevel"`
+	FirstCycle int32             `json:"firstCycle"`
+	LastLevel  *int32            `json:"lastLevel,omitempty"`
+	Constants  ProtocolConstants `json:"constants"`
+	Metadata   ProtocolMetadata  `json:"metadata"`
+}
+
+// ProtocolConstants are the constants of a protocol (subset)
+type ProtocolConstants struct {
+	BlocksPerCycle int32 `json:"blocksPerCycle"`
+}
+
+// ProtocolMetadata is the off-chain metadata of a protocol
+type ProtocolMetadata struct {
+	Alias string `json:"alias"`
+}
+
+// FromModel converts a delegation into its TzKT wire format
+func FromModel(d models.Delegation) Delegation {
+	w := Delegation{
+		Type:      "delegation",
+		ID:        d.ID,
+		Level:     d.Level,
+		Timestamp: d.Timestamp.UTC(),
+		Sender:    Account{Address: d.Delegator},
+		Amount:    d.Amount,
+		Status:    statusApplied,
+	}
+	if d.Baker != "" {
+		w.NewDelegate = &Account{Address: d.Baker}
+	}
+	if d.PrevBaker != "" {
+		w.PrevDelegate = &Account{Address: d.PrevBaker}
+	}
+	return w
+}
+
+// FromStakingModel converts a staking operation into its TzKT wire format
+func FromStakingModel(o models.StakingOp) StakingOp {
+	w := StakingOp{
+		Type:      "staking",
+		ID:        o.ID,
+		Level:     o.Level,
+		Timestamp: o.Timestamp.UTC(),
+		Sender:    Account{Address: o.Staker},
+		Action:    o.Action,
+		Amount:    o.Amount,
+		Status:    statusApplied,
+	}
+	if o.Baker != "" {
+		w.Baker = &Account{Address: o.Baker}
+	}
+	return w
+}
+
+// LoadFixtures reads every *.json file in dir and returns the delegations sorted by id.
+// Each file holds a JSON array of delegations in TzKT format, as returned by /v1/operations/delegations.
+func LoadFixtures(dir string) ([]models.Delegation, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var all []models.Delegation
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture %s: %w", file, err)
+		}
+
+		var delegations []models.Delegation
+		if err := json.Unmarshal(data, &delegations); err != nil {
+			return nil, fmt.Errorf("failed to parse fixture %s: %w", file, err)
+		}
+		all = append(all, delegations...)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].ID < all[j].ID
+	})
+
+	return all, nil
+}
+
+// Synthetic generates n delegations ending at end, one every 30 seconds,
+// spread over a pool of delegator addresses
+func Synthetic(n int, end time.Time, randSeed int64) []models.Delegation {
+	rnd := rand.New(rand.NewSource(randSeed))
+
+	addresses := make([]string, n/10+1)
+	for i := range addresses {
+		addresses[i] = seed.RandomAddress(rnd)
+	}
+
+	start := end.Add(-time.Duration(n) * 30 * time.Second)
+	delegations := make([]models.Delegation, n)
+	id := int64(1_000_000)
+	for i := range delegations {
+		id += 1 + rnd.Int63n(50)
+		delegations[i] = models.Delegation{
+			ID:        id,
+			Delegator: addresses[rnd.Intn(len(addresses))],
+			Timestamp: start.Add(time.Duration(i) * 30 * time.Second).UTC().Truncate(time.Second),
+			Amount:    rnd.Int63n(10_000_000_000),
+			Level:     int32(100_000 + i),
+		}
+	}
+
+	return delegations
+}
+
+// SyntheticStaking generates n staking operations ending at end, one every 30 seconds,
+// spread over a pool of stakers and bakers. Actions cycle through stake, unstake and finalize.
+func SyntheticStaking(n int, end time.Time, randSeed int64) []models.StakingOp {
+	rnd := rand.New(rand.NewSource(randSeed))
+
+	stakers := make([]string, n/10+1)
+	for i := range stakers {
+		stakers[i] = seed.RandomAddress(rnd)
+	}
+	bakers := make([]string, n/100+1)
+	for i := range bakers {
+		bakers[i] = seed.RandomAddress(rnd)
+	}
+	actions := []string{models.StakingActionStake, models.StakingActionUnstake, models.StakingActionFinalize}
+
+	start := end.Add(-time.Duration(n) * 30 * time.Second)
+	ops := make([]models.StakingOp, n)
+	id := int64(1_000_000)
+	for i := range ops {
+		id += 1 + rnd.Int63n(50)
+		ops[i] = models.StakingOp{
+			ID:        id,
+			Staker:    stakers[rnd.Intn(len(stakers))],
+			Baker:     bakers[rnd.Intn(len(bakers))],
+			Action:    actions[i%len(actions)],
+			Timestamp: start.Add(time.Duration(i) * 30 * time.Second).UTC().Truncate(time.Second),
+			Amount:    rnd.Int63n(10_000_000_000),
+			Level:     int32(100_000 + i),
+		}
+	}
+
+	return ops
+}
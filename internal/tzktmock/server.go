@@ -0,0 +1,423 @@
+package tzktmock
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/models"
+)
+
+// maxLimit mirrors the upper bound enforced by the real TzKT API
+const maxLimit = 10000
+
+// defaultLimit is what TzKT returns when no limit is given
+const defaultLimit = 100
+
+// statusApplied is the status of the operations that took effect on chain
+const statusApplied = "applied"
+
+// Options controls the fault injection of the mock server
+type Options struct {
+	// Latency is added to every request before it is answered
+	Latency time.Duration
+	// RateLimitRate is the fraction of requests (0..1) answered with 429 Too Many Requests
+	RateLimitRate float64
+	// ErrorRate is the fraction of requests (0..1) answered with 500 Internal Server Error
+	ErrorRate float64
+	// Seed seeds the random source used for fault injection (0 uses the current time)
+	Seed int64
+}
+
+// BlocksPerCycle is the cycle length of the single protocol served by the mock, short enough
+// for the fixtures to span several cycles
+const BlocksPerCycle = 64
+
+// blockTime is the time between blocks used to date the cycles
+const blockTime = 8 * time.Second
+
+// Server implements the subset of the TzKT API used by the indexer:
+//
+//	GET /v1/operations/delegations        (id.gt, id.lt, limit, sort.asc, sort.desc, status)
+//	GET /v1/operations/delegations/count  (id.gt, id.lt, status)
+//	GET /v1/operations/staking            (id.gt, id.lt, limit, sort.asc, sort.desc, status)
+//	GET /v1/head
+//	GET /v1/cycles                        (limit, offset)
+//	GET /v1/protocols
+//
+// A single protocol runs from level 1 in cycles of BlocksPerCycle blocks; cycles are served
+// up to the one after the head, like TzKT serves the upcoming cycles.
+type Server struct {
+	mu          sync.RWMutex
+	delegations []models.Delegation // sorted by id ascending
+	failed      []models.Delegation // failed delegations, sorted by id ascending
+	staking     []models.StakingOp  // sorted by id ascending
+	opts        Options
+	rnd         *rand.Rand
+	rndMu       sync.Mutex
+	mux         *http.ServeMux
+}
+
+// New creates a mock server serving the given delegations
+func New(delegations []models.Delegation, opts Options) *Server {
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	s := &Server{
+		opts: opts,
+		rnd:  rand.New(rand.NewSource(seed)),
+		mux:  http.NewServeMux(),
+	}
+	s.Append(delegations...)
+
+	s.mux.HandleFunc("/v1/operations/delegations", s.handleDelegations)
+	s.mux.HandleFunc("/v1/operations/delegations/count", s.handleCount)
+	s.mux.HandleFunc("/v1/operations/staking", s.handleStaking)
+	s.mux.HandleFunc("/v1/head", s.handleHead)
+	s.mux.HandleFunc("/v1/cycles", s.handleCycles)
+	s.mux.HandleFunc("/v1/protocols", s.handleProtocols)
+
+	return s
+}
+
+// Append adds delegations to the served set, e.g. to simulate new blocks in tests
+func (s *Server) Append(delegations ...models.Delegation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.delegations = append(s.delegations, delegations...)
+	sort.Slice(s.delegations, func(i, j int) bool {
+		return s.delegations[i].ID < s.delegations[j].ID
+	})
+}
+
+// AppendFailed adds failed delegations to the served set: they are served with status
+// failed, and left out by status=applied
+func (s *Server) AppendFailed(delegations ...models.Delegation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failed = append(s.failed, delegations...)
+	sort.Slice(s.failed, func(i, j int) bool {
+		return s.failed[i].ID < s.failed[j].ID
+	})
+}
+
+// AppendStaking adds staking operations to the served set
+func (s *Server) AppendStaking(ops ...models.StakingOp) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.staking = append(s.staking, ops...)
+	sort.Slice(s.staking, func(i, j int) bool {
+		return s.staking[i].ID < s.staking[j].ID
+	})
+}
+
+// Len returns the number of delegations served
+func (s *Server) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.delegations)
+}
+
+// Last returns the delegation with the highest id
+func (s *Server) Last() (models.Delegation, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.delegations) == 0 {
+		return models.Delegation{}, false
+	}
+	return s.delegations[len(s.delegations)-1], true
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.opts.Latency > 0 {
+		select {
+		case <-time.After(s.opts.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	switch roll := s.roll(); {
+	case roll < s.opts.RateLimitRate:
+		w.Header().Set("Retry-After", "1")
+		writeJSON(w, http.StatusTooManyRequests, map[string]any{"code": 429, "errors": "Too many requests"})
+		return
+	case roll < s.opts.RateLimitRate+s.opts.ErrorRate:
+		writeJSON(w, http.StatusInternalServerError, map[string]any{"code": 500, "errors": "Internal server error"})
+		return
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) roll() float64 {
+	s.rndMu.Lock()
+	defer s.rndMu.Unlock()
+	return s.rnd.Float64()
+}
+
+// filter holds the parsed query parameters shared by the operations endpoints
+type filter struct {
+	idGt  int64
+	idLt  int64
+	limit int
+	desc  bool
+	// status is empty (any status), statusApplied or another status (failed, backtracked or skipped)
+	status string
+}
+
+func parseFilter(r *http.Request) (filter, error) {
+	q := r.URL.Query()
+	f := filter{idLt: -1, limit: defaultLimit}
+
+	if v := q.Get("id.gt"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return f, fmt.Errorf("id.gt: the value '%s' is not valid", v)
+		}
+		f.idGt = n
+	}
+
+	if v := q.Get("id.lt"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return f, fmt.Errorf("id.lt: the value '%s' is not valid", v)
+		}
+		f.idLt = n
+	}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxLimit {
+			return f, fmt.Errorf("limit: The field limit must be between 0 and %d.", maxLimit)
+		}
+		f.limit = n
+	}
+
+	if v := q.Get("sort.desc"); v != "" {
+		if v != "id" {
+			return f, fmt.Errorf("sort.desc: sorting by '%s' is not supported", v)
+		}
+		f.desc = true
+	}
+
+	if v := q.Get("sort.asc"); v != "" && v != "id" {
+		return f, fmt.Errorf("sort.asc: sorting by '%s' is not supported", v)
+	}
+
+	if v := q.Get("status"); v != "" {
+		switch v {
+		case statusApplied, "failed", "backtracked", "skipped":
+			f.status = v
+		default:
+			return f, fmt.Errorf("status: the value '%s' is not valid", v)
+		}
+	}
+
+	return f, nil
+}
+
+// match returns the delegations matching the id bounds and status in ascending order
+func (s *Server) match(f filter) []models.Delegation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	switch f.status {
+	case statusApplied:
+		return matchIDs(s.delegations, f)
+	case "":
+		matched := append(matchIDs(s.delegations, f), matchIDs(s.failed, f)...)
+		sort.Slice(matched, func(i, j int) bool {
+			return matched[i].ID < matched[j].ID
+		})
+		return matched
+	default:
+		// Every failed delegation is served with status failed
+		if f.status != "failed" {
+			return nil
+		}
+		return matchIDs(s.failed, f)
+	}
+}
+
+// isFailed reports whether the delegation with the given id was added with AppendFailed
+func (s *Server) isFailed(id int64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := sort.Search(len(s.failed), func(i int) bool { return s.failed[i].ID >= id })
+	return i < len(s.failed) && s.failed[i].ID == id
+}
+
+// matchIDs returns a copy of the operations of ops, sorted by id ascending, matching the id bounds
+func matchIDs[T interface{ OperationID() int64 }](ops []T, f filter) []T {
+	lo := sort.Search(len(ops), func(i int) bool {
+		return ops[i].OperationID() > f.idGt
+	})
+	hi := len(ops)
+	if f.idLt >= 0 {
+		hi = sort.Search(len(ops), func(i int) bool {
+			return ops[i].OperationID() >= f.idLt
+		})
+	}
+	if lo >= hi {
+		return nil
+	}
+
+	out := make([]T, hi-lo)
+	copy(out, ops[lo:hi])
+	return out
+}
+
+// page sorts matched operations as requested and applies the limit
+func page[T any](matched []T, f filter) []T {
+	if f.desc {
+		for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+			matched[i], matched[j] = matched[j], matched[i]
+		}
+	}
+	if len(matched) > f.limit {
+		matched = matched[:f.limit]
+	}
+	return matched
+}
+
+func (s *Server) handleDelegations(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"code": 400, "errors": err.Error()})
+		return
+	}
+
+	matched := page(s.match(f), f)
+	out := make([]Delegation, 0, len(matched))
+	for _, d := range matched {
+		w := FromModel(d)
+		if s.isFailed(d.ID) {
+			w.Status = "failed"
+		}
+		out = append(out, w)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleStaking(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"code": 400, "errors": err.Error()})
+		return
+	}
+
+	// Every served staking operation is applied
+	var matched []models.StakingOp
+	if f.status == "" || f.status == statusApplied {
+		s.mu.RLock()
+		matched = matchIDs(s.staking, f)
+		s.mu.RUnlock()
+	}
+
+	matched = page(matched, f)
+	out := make([]StakingOp, 0, len(matched))
+	for _, o := range matched {
+		out = append(out, FromStakingModel(o))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleCount(w http.ResponseWriter, r *http.Request) {
+	f, err := parseFilter(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"code": 400, "errors": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, len(s.match(f)))
+}
+
+func (s *Server) handleHead(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.head())
+}
+
+// head returns the level and timestamp of the latest delegation, or level 0 now when there is none
+func (s *Server) head() Head {
+	head := Head{Timestamp: time.Now().UTC()}
+	if last, ok := s.Last(); ok {
+		head.Level = last.Level
+		head.Timestamp = last.Timestamp
+	}
+	return head
+}
+
+func (s *Server) handleCycles(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, offset := defaultLimit, 0
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxLimit {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"code": 400, "errors": fmt.Sprintf("limit: The field limit must be between 0 and %d.", maxLimit)})
+			return
+		}
+		limit = n
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"code": 400, "errors": "offset: The value must be a non-negative integer."})
+			return
+		}
+		offset = n
+	}
+
+	// Date the blocks from the head, one every blockTime
+	head := s.head()
+	levelTime := func(level int32) time.Time {
+		return head.Timestamp.Add(time.Duration(level-head.Level) * blockTime)
+	}
+
+	count := int(head.Level/BlocksPerCycle) + 2
+	out := make([]Cycle, 0)
+	for index := offset; index < count && len(out) < limit; index++ {
+		first := int32(index)*BlocksPerCycle + 1
+		last := first + BlocksPerCycle - 1
+		out = append(out, Cycle{
+			Index:      int32(index),
+			FirstLevel: first,
+			LastLevel:  last,
+			StartTime:  levelTime(first),
+			EndTime:    levelTime(last),
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) handleProtocols(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, []Protocol{{
+		Code:       1,
+		Hash:       "PtMockMockMockMockMockMockMockMockMockMockMockMockM",
+		FirstLevel: 1,
+		FirstCycle: 0,
+		Constants:  ProtocolConstants{BlocksPerCycle: BlocksPerCycle},
+		Metadata:   ProtocolMetadata{Alias: "Mock"},
+	}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,313 @@
+package tzktmock
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/models"
+)
+
+func newTestServer(t *testing.T, opts Options) *httptest.Server {
+	t.Helper()
+
+	delegations, err := LoadFixtures("testdata")
+	if err != nil {
+		t.Fatalf("LoadFixtures() error = %v", err)
+	}
+
+	srv := httptest.NewServer(New(delegations, opts))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func getDelegations(t *testing.T, url string) (int, []models.Delegation) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s error = %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+
+	var delegations []models.Delegation
+	if err := json.NewDecoder(resp.Body).Decode(&delegations); err != nil {
+		t.Fatalf("decode error = %v", err)
+	}
+	return resp.StatusCode, delegations
+}
+
+func TestServer_Delegations(t *testing.T) {
+	srv := newTestServer(t, Options{})
+	base := srv.URL + "/v1/operations/delegations"
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantIDs    []int64
+	}{
+		{
+			name:       "latest delegation",
+			query:      "?limit=1&sort.desc=id",
+			wantStatus: http.StatusOK,
+			wantIDs:    []int64{1868562432},
+		},
+		{
+			name:       "newer than cursor ascending",
+			query:      "?id.gt=1649410048&limit=100&sort.asc=id",
+			wantStatus: http.StatusOK,
+			wantIDs:    []int64{1652555776, 1765801984, 1868562432},
+		},
+		{
+			name:       "older than cursor descending",
+			query:      "?id.lt=1765801984&limit=2&sort.desc=id",
+			wantStatus: http.StatusOK,
+			wantIDs:    []int64{1652555776, 1649410048},
+		},
+		{
+			name:       "nothing older than first",
+			query:      "?id.lt=1098907648&sort.desc=id",
+			wantStatus: http.StatusOK,
+			wantIDs:    []int64{},
+		},
+		{
+			name:       "limit too large",
+			query:      "?limit=10001",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unsupported sort field",
+			query:      "?sort.desc=level",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, got := getDelegations(t, base+tt.query)
+
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			if len(got) != len(tt.wantIDs) {
+				t.Fatalf("got %d delegations, want %d", len(got), len(tt.wantIDs))
+			}
+			for i, d := range got {
+				if d.ID != tt.wantIDs[i] {
+					t.Errorf("delegations[%d].ID = %d, want %d", i, d.ID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestServer_DelegationRoundTrip(t *testing.T) {
+	srv := newTestServer(t, Options{})
+
+	_, got := getDelegations(t, srv.URL+"/v1/operations/delegations?limit=1&sort.asc=id")
+	if len(got) != 1 {
+		t.Fatalf("got %d delegations, want 1", len(got))
+	}
+
+	want := models.Delegation{
+		ID:        1098907648,
+		Delegator: "tz1Wit2PqodvPeuRRhdQXmkrtU8e8bRYZecd",
+		Timestamp: time.Date(2018, 6, 30, 19, 30, 27, 0, time.UTC),
+		Amount:    25079312620,
+		Level:     109,
+	}
+	if got[0] != want {
+		t.Errorf("delegation = %+v, want %+v", got[0], want)
+	}
+}
+
+func TestServer_FailedDelegations(t *testing.T) {
+	delegations := Synthetic(3, time.Now(), 1)
+	mock := New([]models.Delegation{delegations[0], delegations[2]}, Options{})
+	mock.AppendFailed(delegations[1])
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		query   string
+		wantIDs []int64
+	}{
+		{query: "", wantIDs: []int64{delegations[0].ID, delegations[1].ID, delegations[2].ID}},
+		{query: "?status=applied", wantIDs: []int64{delegations[0].ID, delegations[2].ID}},
+		{query: "?status=failed", wantIDs: []int64{delegations[1].ID}},
+		{query: "?status=backtracked", wantIDs: []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + "/v1/operations/delegations" + tt.query)
+			if err != nil {
+				t.Fatalf("GET error = %v", err)
+			}
+			defer resp.Body.Close()
+
+			var got []Delegation
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("decode error = %v", err)
+			}
+			if len(got) != len(tt.wantIDs) {
+				t.Fatalf("got %d delegations, want %d", len(got), len(tt.wantIDs))
+			}
+			for i, d := range got {
+				wantStatus := "applied"
+				if d.ID == delegations[1].ID {
+					wantStatus = "failed"
+				}
+				if d.ID != tt.wantIDs[i] || d.Status != wantStatus {
+					t.Errorf("delegations[%d] = %d %s, want %d %s", i, d.ID, d.Status, tt.wantIDs[i], wantStatus)
+				}
+			}
+		})
+	}
+
+	status, _ := getDelegations(t, srv.URL+"/v1/operations/delegations?status=pending")
+	if status != http.StatusBadRequest {
+		t.Errorf("status = %d for an unknown status, want %d", status, http.StatusBadRequest)
+	}
+}
+
+func TestServer_CountAndHead(t *testing.T) {
+	srv := newTestServer(t, Options{})
+
+	resp, err := http.Get(srv.URL + "/v1/operations/delegations/count?id.gt=1649410048")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	json.NewDecoder(resp.Body).Decode(&count)
+	resp.Body.Close()
+	if count != 3 {
+		t.Errorf("count = %d, want 3", count)
+	}
+
+	resp, err = http.Get(srv.URL + "/v1/head")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var head Head
+	json.NewDecoder(resp.Body).Decode(&head)
+	resp.Body.Close()
+	if head.Level != 189 {
+		t.Errorf("head.Level = %d, want 189", head.Level)
+	}
+}
+
+func TestServer_FaultInjection(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       Options
+		wantStatus int
+	}{
+		{name: "rate limited", opts: Options{RateLimitRate: 1}, wantStatus: http.StatusTooManyRequests},
+		{name: "server error", opts: Options{ErrorRate: 1}, wantStatus: http.StatusInternalServerError},
+		{name: "no faults", opts: Options{}, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, tt.opts)
+			status, _ := getDelegations(t, srv.URL+"/v1/operations/delegations")
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestSynthetic(t *testing.T) {
+	end := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	delegations := Synthetic(50, end, 42)
+
+	if len(delegations) != 50 {
+		t.Fatalf("got %d delegations, want 50", len(delegations))
+	}
+	for i := 1; i < len(delegations); i++ {
+		if delegations[i].ID <= delegations[i-1].ID {
+			t.Errorf("ids not increasing at %d: %d <= %d", i, delegations[i].ID, delegations[i-1].ID)
+		}
+		if delegations[i].Level <= delegations[i-1].Level {
+			t.Errorf("levels not increasing at %d", i)
+		}
+		if !delegations[i].Timestamp.After(delegations[i-1].Timestamp) {
+			t.Errorf("timestamps not increasing at %d", i)
+		}
+	}
+	if last := delegations[len(delegations)-1].Timestamp; last.After(end) {
+		t.Errorf("last timestamp %v after end %v", last, end)
+	}
+}
+
+func TestServer_Staking(t *testing.T) {
+	ops := SyntheticStaking(30, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 42)
+	mock := New(nil, Options{})
+	mock.AppendStaking(ops...)
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name    string
+		query   string
+		wantIDs []int64
+	}{
+		{"oldest first", "?limit=2&sort.asc=id", []int64{ops[0].ID, ops[1].ID}},
+		{"latest first", "?limit=2&sort.desc=id", []int64{ops[29].ID, ops[28].ID}},
+		{"after id", "?id.gt=" + strconv.FormatInt(ops[27].ID, 10), []int64{ops[28].ID, ops[29].ID}},
+		{"before id", "?id.lt=" + strconv.FormatInt(ops[1].ID, 10), []int64{ops[0].ID}},
+		{"applied", "?limit=1&status=applied", []int64{ops[0].ID}},
+		{"failed", "?status=failed", []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(srv.URL + "/v1/operations/staking" + tt.query)
+			if err != nil {
+				t.Fatalf("GET error = %v", err)
+			}
+			defer resp.Body.Close()
+
+			var got []models.StakingOp
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("decode error = %v", err)
+			}
+			if len(got) != len(tt.wantIDs) {
+				t.Fatalf("got %d operations, want %d", len(got), len(tt.wantIDs))
+			}
+			for i, o := range got {
+				if o.ID != tt.wantIDs[i] {
+					t.Errorf("operation %d id = %d, want %d", i, o.ID, tt.wantIDs[i])
+				}
+			}
+		})
+	}
+
+	// Operations round-trip through the wire format
+	resp, err := http.Get(srv.URL + "/v1/operations/staking?limit=1")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp.Body.Close()
+	var got []models.StakingOp
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode error = %v", err)
+	}
+	if len(got) != 1 || got[0] != ops[0] {
+		t.Errorf("operation = %+v, want %+v", got, ops[0])
+	}
+}
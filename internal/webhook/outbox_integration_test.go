@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/dbtest"
+	"github.com/broyeztony/delegated/internal/models"
+)
+
+func TestIntegration_OutboxDeliversMatchingDelegations(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+
+	baker := "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM"
+	w, err := db.CreateWebhook(ctx, pool, db.Webhook{URL: "http://example.com", Secret: "secret", Baker: &baker})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delegations := []models.Delegation{
+		{ID: 1, Delegator: "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo", Baker: baker, Timestamp: time.Now().UTC(), Amount: 1, Level: 1},
+		{ID: 2, Delegator: "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo", Baker: "tz1other000000000000000000000000000", Timestamp: time.Now().UTC(), Amount: 1, Level: 2},
+	}
+	if err := db.BulkInsertDelegations(ctx, pool, delegations); err != nil {
+		t.Fatal(err)
+	}
+	// Re-inserting already indexed delegations does not queue them again
+	if err := db.BulkInsertDelegations(ctx, pool, delegations); err != nil {
+		t.Fatal(err)
+	}
+
+	noBackoff := func(int) time.Duration { return 0 }
+	failing := func(context.Context, db.Delivery) error { return errors.New("connection refused") }
+
+	// The first attempt fails and is rescheduled, the second one is dead-lettered
+	var delivered []int64
+	if _, failed, _, err := db.ProcessDeliveries(ctx, pool, 10, 2, time.Minute, noBackoff, failing); err != nil || failed != 1 {
+		t.Fatalf("first attempt: failed = %d, err = %v, want 1 failure", failed, err)
+	}
+	if _, _, dead, err := db.ProcessDeliveries(ctx, pool, 10, 2, time.Minute, noBackoff, failing); err != nil || dead != 1 {
+		t.Fatalf("second attempt: dead = %d, err = %v, want 1 dead letter", dead, err)
+	}
+
+	deadLetters, err := db.ListDeadLetters(ctx, pool, w.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deadLetters) != 1 || deadLetters[0].DelegationID != 1 || deadLetters[0].Attempts != 2 {
+		t.Fatalf("dead letters = %+v, want delegation 1 after 2 attempts", deadLetters)
+	}
+
+	// A retried dead letter is delivered again
+	if err := db.RetryDeadLetter(ctx, pool, w.ID, deadLetters[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	succeeding := func(ctx context.Context, d db.Delivery) error {
+		delivered = append(delivered, d.Delegation.ID)
+		// The delivery is leased while it is attempted: another dispatcher does not claim it
+		twice := func(context.Context, db.Delivery) error {
+			t.Error("delivery claimed twice")
+			return nil
+		}
+		if n, failed, dead, err := db.ProcessDeliveries(ctx, pool, 10, 2, time.Minute, noBackoff, twice); err != nil || n+failed+dead != 0 {
+			t.Errorf("concurrent claim: %d delivered, %d failed, %d dead, err = %v, want none", n, failed, dead, err)
+		}
+		return nil
+	}
+	if n, _, _, err := db.ProcessDeliveries(ctx, pool, 10, 2, time.Minute, noBackoff, succeeding); err != nil || n != 1 {
+		t.Fatalf("retry: delivered = %d, err = %v, want 1", n, err)
+	}
+	if len(delivered) != 1 || delivered[0] != 1 {
+		t.Errorf("delivered = %v, want [1]", delivered)
+	}
+
+	// Nothing is left to deliver
+	if n, failed, dead, err := db.ProcessDeliveries(ctx, pool, 10, 2, time.Minute, noBackoff, succeeding); err != nil || n+failed+dead != 0 {
+		t.Errorf("outbox not empty: %d delivered, %d failed, %d dead, err = %v", n, failed, dead, err)
+	}
+}
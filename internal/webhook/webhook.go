@@ -0,0 +1,161 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/config"
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/metrics"
+	"github.com/broyeztony/delegated/internal/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Headers set on every delivery
+const (
+	HeaderSignature = "X-Delegated-Signature"
+	HeaderTimestamp = "X-Delegated-Timestamp"
+	HeaderDelivery  = "X-Delegated-Delivery"
+)
+
+// Payload is the JSON body POSTed to a webhook
+type Payload struct {
+	WebhookID  int64        `json:"webhook_id"`
+	Delegation models.Event `json:"delegation"`
+}
+
+// NewPayload builds the body of a delivery
+func NewPayload(d db.Delivery) Payload {
+	return Payload{WebhookID: d.WebhookID, Delegation: models.NewEvent(d.Delegation)}
+}
+
+// Sign returns the signature header value of a delivery: "sha256=" followed by the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret. Including the timestamp
+// lets receivers reject replayed deliveries.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header value in constant time
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// NewSecret generates a random signing secret
+func NewSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// claimMargin is added to the request timeout to lease claimed deliveries, leaving time to record
+// their outcomes before another dispatcher may claim them again
+const claimMargin = 30 * time.Second
+
+// Dispatcher delivers the deliveries queued in the outbox
+type Dispatcher struct {
+	pool   *pgxpool.Pool
+	client *http.Client
+	cfg    config.Webhooks
+}
+
+// NewDispatcher creates a dispatcher
+func NewDispatcher(pool *pgxpool.Pool, cfg config.Webhooks) *Dispatcher {
+	return &Dispatcher{
+		pool: pool,
+		client: &http.Client{
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+			Timeout:   cfg.Timeout,
+		},
+		cfg: cfg,
+	}
+}
+
+// Run delivers due deliveries until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain full batches without waiting
+		for {
+			delivered, failed, dead, err := db.ProcessDeliveries(ctx, d.pool, d.cfg.BatchSize, d.cfg.MaxAttempts, d.cfg.Timeout+claimMargin, d.backoff, d.deliver)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				slog.ErrorContext(ctx, "Failed to process webhook deliveries", "error", err)
+				break
+			}
+			metrics.WebhookDeliveries.WithLabelValues("delivered").Add(float64(delivered))
+			metrics.WebhookDeliveries.WithLabelValues("failed").Add(float64(failed))
+			metrics.WebhookDeliveries.WithLabelValues("dead").Add(float64(dead))
+			if delivered+failed+dead < d.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// backoff returns the delay before the next attempt, after attempts failures
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.cfg.MinBackoff
+	for i := 1; i < attempts && delay < d.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.cfg.MaxBackoff)
+}
+
+// deliver POSTs a signed delivery and fails unless the endpoint answers 2xx
+func (d *Dispatcher) deliver(ctx context.Context, delivery db.Delivery) error {
+	body, err := json.Marshal(NewPayload(delivery))
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	timestamp := time.Now().Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, body))
+	request.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		slog.WarnContext(ctx, "Webhook delivery failed", "webhook_id", delivery.WebhookID, "delivery", delivery.ID, "attempt", delivery.Attempts+1, "error", err)
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		slog.WarnContext(ctx, "Webhook delivery rejected", "webhook_id", delivery.WebhookID, "delivery", delivery.ID, "attempt", delivery.Attempts+1, "status", response.StatusCode)
+		return fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	return nil
+}
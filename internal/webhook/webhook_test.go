@@ -0,0 +1,133 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/broyeztony/delegated/internal/config"
+	"github.com/broyeztony/delegated/internal/db"
+	"github.com/broyeztony/delegated/internal/models"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"webhook_id":1}`)
+	signature := Sign("secret", 1700000000, body)
+
+	// echo -n '1700000000.{"webhook_id":1}' | openssl dgst -sha256 -hmac secret
+	want := "sha256=1da84008bff182618f9319dfe8d39c22f6ae263d01ba9b72d2cfd96bc27b42b7"
+	if signature != want {
+		t.Errorf("Sign() = %s, want %s", signature, want)
+	}
+	if !Verify("secret", 1700000000, body, signature) {
+		t.Errorf("Verify() rejected its own signature")
+	}
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      []byte
+	}{
+		{name: "wrong secret", secret: "other", timestamp: 1700000000, body: body},
+		{name: "replayed with another timestamp", secret: "secret", timestamp: 1700000001, body: body},
+		{name: "tampered body", secret: "secret", timestamp: 1700000000, body: []byte(`{"webhook_id":2}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if Verify(tt.secret, tt.timestamp, tt.body, signature) {
+				t.Errorf("Verify() accepted a signature that does not match")
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil, config.Default().Webhooks)
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 5 * time.Second},
+		{attempts: 2, want: 10 * time.Second},
+		{attempts: 4, want: 40 * time.Second},
+		{attempts: 10, want: 42*time.Minute + 40*time.Second},
+		{attempts: 11, want: time.Hour},
+		{attempts: 100, want: time.Hour},
+	}
+	for _, tt := range tests {
+		if got := d.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func testDelivery(url string) db.Delivery {
+	return db.Delivery{
+		ID:        7,
+		WebhookID: 3,
+		URL:       url,
+		Secret:    "secret",
+		Delegation: models.Delegation{
+			ID:        42,
+			Delegator: "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo",
+			Baker:     "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM",
+			Timestamp: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+			Amount:    125000000,
+			Level:     5000000,
+		},
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	var got Payload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if err != nil || !Verify("secret", timestamp, body, r.Header.Get(HeaderSignature)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get(HeaderDelivery) != "7" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.Unmarshal(body, &got)
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(nil, config.Default().Webhooks)
+	if err := d.deliver(context.Background(), testDelivery(srv.URL)); err != nil {
+		t.Fatalf("deliver() error = %v", err)
+	}
+
+	want := models.Event{
+		ID:        42,
+		Timestamp: "2024-03-01T12:00:00Z",
+		Amount:    "125000000",
+		Delegator: "tz1cAuZvhNgybyXdu4x2263CNjaFqHKdd8eo",
+		Baker:     "tz1aRoaRhSpRYvFdyvgWLL6TGyRoGF51wDjM",
+		Level:     "5000000",
+	}
+	if got.WebhookID != 3 || got.Delegation != want {
+		t.Errorf("payload = %+v, want webhook 3 and %+v", got, want)
+	}
+}
+
+func TestDeliver_FailsOnErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher(nil, config.Default().Webhooks)
+	if err := d.deliver(context.Background(), testDelivery(srv.URL)); err == nil {
+		t.Errorf("deliver() error = nil, want an error for a 503")
+	}
+}
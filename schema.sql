@@ -1,3 +1,12 @@
+-- Schema version, checked by the API readiness probe (must match db.SchemaVersion)
+DROP TABLE IF EXISTS schema_version;
+
+CREATE TABLE schema_version (
+    version INTEGER NOT NULL
+);
+
+INSERT INTO schema_version (version) VALUES (9);
+
 -- Drop table if it exists (for idempotency)
 DROP TABLE IF EXISTS delegations;
 
@@ -7,7 +16,180 @@ CREATE TABLE delegations (
     delegator VARCHAR(36) NOT NULL,
     timestamp TIMESTAMP NOT NULL,
     amount BIGINT NOT NULL,
-    level INTEGER NOT NULL
+    level INTEGER NOT NULL,
+    baker VARCHAR(36) NOT NULL DEFAULT '', -- new delegate, empty for an undelegation
+    prev_baker VARCHAR(36) NOT NULL DEFAULT '' -- previous delegate, empty for a first delegation
 );
 
 CREATE INDEX idx_delegations_timestamp ON delegations(timestamp);
+-- Joins and leaves of a baker and latest delegation of a delegator, for the baker endpoints
+CREATE INDEX idx_delegations_baker ON delegations(baker, timestamp);
+CREATE INDEX idx_delegations_prev_baker ON delegations(prev_baker, timestamp);
+CREATE INDEX idx_delegations_delegator ON delegations(delegator, id);
+-- Cycle and protocol filters, translated to level ranges
+CREATE INDEX idx_delegations_level ON delegations(level);
+
+-- Daily rollups of delegations per baker (empty for undelegations), updated in the same transaction
+-- as the inserts and recomputed by `delegated rollups rebuild`: totals, the set of delegators (for
+-- distinct counts over any range of days) and a log-scale histogram of amounts (for medians)
+DROP TABLE IF EXISTS delegation_daily_rollups;
+DROP TABLE IF EXISTS delegation_daily_delegators;
+DROP TABLE IF EXISTS delegation_daily_amounts;
+
+CREATE TABLE delegation_daily_rollups (
+    day DATE NOT NULL,
+    baker VARCHAR(36) NOT NULL,
+    delegations BIGINT NOT NULL,
+    delegators BIGINT NOT NULL,
+    total_amount BIGINT NOT NULL,
+    max_amount BIGINT NOT NULL,
+    PRIMARY KEY (day, baker)
+);
+
+CREATE TABLE delegation_daily_delegators (
+    day DATE NOT NULL,
+    baker VARCHAR(36) NOT NULL,
+    delegator VARCHAR(36) NOT NULL,
+    PRIMARY KEY (day, baker, delegator)
+);
+
+CREATE TABLE delegation_daily_amounts (
+    day DATE NOT NULL,
+    baker VARCHAR(36) NOT NULL,
+    bucket INTEGER NOT NULL, -- 0 for 0 mutez, k for amounts in [1.01^(k-1), 1.01^k)
+    delegations BIGINT NOT NULL,
+    PRIMARY KEY (day, baker, bucket)
+);
+
+-- Current delegation of each delegator: its latest delegation, maintained in the same transaction
+-- as the inserts (a row only replaces an older delegation) and recomputed by
+-- `delegated current-delegations rebuild`. The baker is empty after an undelegation.
+DROP TABLE IF EXISTS current_delegations;
+
+CREATE TABLE current_delegations (
+    delegator VARCHAR(36) PRIMARY KEY,
+    baker VARCHAR(36) NOT NULL,
+    delegation_id BIGINT NOT NULL,
+    level INTEGER NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    amount BIGINT NOT NULL
+);
+
+CREATE INDEX idx_current_delegations_baker ON current_delegations(baker, amount);
+
+-- Staking operations (stake, unstake, finalize) indexed from TzKT like delegations, with their own
+-- cursor (indexer_state row "staking") and backfill
+DROP TABLE IF EXISTS staking_ops;
+
+CREATE TABLE staking_ops (
+    id BIGINT PRIMARY KEY,
+    staker VARCHAR(36) NOT NULL,
+    baker VARCHAR(36) NOT NULL DEFAULT '',
+    action VARCHAR(16) NOT NULL,
+    timestamp TIMESTAMP NOT NULL,
+    amount BIGINT NOT NULL,
+    level INTEGER NOT NULL
+);
+
+CREATE INDEX idx_staking_ops_staker ON staking_ops(staker, id);
+CREATE INDEX idx_staking_ops_baker ON staking_ops(baker, id);
+
+-- Protocols and cycles synced from TzKT by the indexer (or `delegated chain sync`): a delegation
+-- belongs to the cycle and the protocol whose level range contains its level. Cycle lengths
+-- change across protocols, so cycles are stored rather than computed.
+DROP TABLE IF EXISTS protocols;
+DROP TABLE IF EXISTS cycles;
+
+CREATE TABLE protocols (
+    code INTEGER PRIMARY KEY,
+    hash VARCHAR(51) NOT NULL UNIQUE,
+    alias VARCHAR(64) NOT NULL DEFAULT '',
+    first_level INTEGER NOT NULL,
+    last_level INTEGER, -- NULL for the current protocol
+    first_cycle INTEGER NOT NULL,
+    blocks_per_cycle INTEGER NOT NULL
+);
+
+CREATE TABLE cycles (
+    cycle INTEGER PRIMARY KEY,
+    first_level INTEGER NOT NULL,
+    last_level INTEGER NOT NULL,
+    start_time TIMESTAMP NOT NULL,
+    end_time TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_cycles_first_level ON cycles(first_level);
+
+-- Leader election lease for indexer replicas: only the holder of an unexpired lease polls
+DROP TABLE IF EXISTS indexer_leases;
+
+CREATE TABLE indexer_leases (
+    name VARCHAR(64) PRIMARY KEY,
+    holder VARCHAR(128) NOT NULL,
+    acquired_at TIMESTAMPTZ NOT NULL,
+    renewed_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+-- Indexer progress, written after each successful poll and read by the API to report freshness
+DROP TABLE IF EXISTS indexer_state;
+
+CREATE TABLE indexer_state (
+    name VARCHAR(64) PRIMARY KEY,
+    cursor BIGINT NOT NULL,
+    synced_level INTEGER,
+    synced_timestamp TIMESTAMPTZ,
+    last_poll_at TIMESTAMPTZ NOT NULL,
+    last_ingest_at TIMESTAMPTZ
+);
+
+-- Webhook subscriptions, deliveries queued in the same transaction as the delegation insert,
+-- and deliveries that failed too many times
+DROP TABLE IF EXISTS webhook_dead_letters;
+DROP TABLE IF EXISTS webhook_outbox;
+DROP TABLE IF EXISTS webhooks;
+
+CREATE TABLE webhooks (
+    id BIGSERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    delegator VARCHAR(36),
+    baker VARCHAR(36),
+    min_amount BIGINT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE webhook_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    delegation_id BIGINT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (webhook_id, delegation_id)
+);
+
+CREATE INDEX idx_webhook_outbox_next_attempt_at ON webhook_outbox(next_attempt_at);
+
+CREATE TABLE webhook_dead_letters (
+    id BIGINT PRIMARY KEY,
+    webhook_id BIGINT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    delegation_id BIGINT NOT NULL,
+    attempts INTEGER NOT NULL,
+    last_error TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    failed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Position of each event sink: the id of the last delegation it published, and the process
+-- publishing the next batch until its lease expires
+DROP TABLE IF EXISTS sink_cursors;
+
+CREATE TABLE sink_cursors (
+    name VARCHAR(64) PRIMARY KEY,
+    cursor BIGINT NOT NULL,
+    published_at TIMESTAMPTZ,
+    lease_holder VARCHAR(64),
+    lease_expires_at TIMESTAMPTZ
+);